@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+var alarmsCmd = &cobra.Command{
+	Use:     "alarms",
+	Short:   "Lists active alarms like NOSPACE or CORRUPT of the etcd cluster",
+	Long:    `The alarms are queried from the first accessible node of the Etcd group.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     alarmsRun,
+}
+
+func init() {
+	EtcdCmd.AddCommand(alarmsCmd)
+	addEtcdClusterFlags(alarmsCmd.Flags())
+}
+
+func alarmsRun(_ *cobra.Command, _ []string) {
+	pkg.EtcdAlarms(createCommandContext(etcdClusterOpts))
+}
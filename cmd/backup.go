@@ -14,7 +14,9 @@ var etcdBackupOpts = &types.EtcdBackupOpts{}
 var backupCmd = &cobra.Command{
 	Use:     "backup",
 	Short:   "Creates a backup of an etcd cluster",
-	Long:    `Backups are created via etcdctl and stored as an tar.gz archive on the local filesystem`,
+	Long:    `Backups are created via etcdctl and stored as an tar.gz archive on the local filesystem together with a SHA-256 checksum and a metadata file.
+Archives are encrypted in the age format when recipients are given or a passphrase is set in the config or in the
+environment variable KUBESPECTOR_BACKUP_PASSPHRASE.`,
 	PreRunE: util.CheckRequiredFlags,
 	Run:     backupRun,
 }
@@ -22,15 +24,14 @@ var backupCmd = &cobra.Command{
 func init() {
 	EtcdCmd.AddCommand(backupCmd)
 	backupCmd.Flags().StringVarP(&etcdBackupOpts.Output, "output", "o", "", "The target directory for the resulting ZIP file of the backup")
-	backupCmd.Flags().StringVar(&etcdBackupOpts.DataDir, "data-dir", ".", "Working directory of the etcd cluster")
-	backupCmd.Flags().StringVar(&etcdBackupOpts.Endpoint, "endpoint",  "http://127.0.0.1:2379", "The URL of the etcd to use")
-	backupCmd.Flags().BoolVar(&etcdBackupOpts.ClientCertAuth, "secure", false, "Secure etcd communication")
-	backupCmd.Flags().StringVar(&etcdBackupOpts.ClientCertFile, "client-cert",  "", "path to client certificate")
-	backupCmd.Flags().StringVar(&etcdBackupOpts.ClientKeyFile, "client-cert-key",  "", "path to client certificate key")
-	backupCmd.Flags().StringVar(&etcdBackupOpts.CaFile, "ca-cert", "", "path to certificate authority")
+	backupCmd.Flags().StringVar(&etcdBackupOpts.DataDir, "data-dir", "", "Working directory of the etcd cluster, required for api v2")
+	backupCmd.Flags().StringVar(&etcdBackupOpts.Endpoint, "endpoint", "", "The URL of the etcd to use, defaults to the client endpoint of the node the backup runs on")
+	addEtcdTLSFlags(backupCmd.Flags(), &etcdBackupOpts.EtcdOpts)
 	backupCmd.Flags().BoolVar(&etcdBackupOpts.Sudo, "sudo",false, "Run commands as sudo")
-	backupCmd.MarkFlagRequired("endpoint")
-	backupCmd.MarkFlagRequired("data-dir")
+	backupCmd.Flags().StringVar(&etcdBackupOpts.Api, "api", types.ETCD_API_V2, "Version of the etcd api, v2 backs up the data dir, v3 creates a snapshot of the keyspace")
+	backupCmd.Flags().IntVar(&etcdBackupOpts.Keep, "keep", 0, "Number of newest backups to keep in the output directory, older ones are removed")
+	backupCmd.Flags().StringVar(&etcdBackupOpts.KeepWithin, "keep-within", "", "Keep backups younger than this duration, e.g. 14d. Combined with --keep a backup is kept when either applies")
+	backupCmd.Flags().StringSliceVar(&etcdBackupOpts.Recipients, "recipient", []string{}, "Encrypt the archive to this age recipient (age1...), can be repeated. Defaults to Backup.Recipients of the config")
 }
 
 func backupRun(_ *cobra.Command, _ []string) {
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+var etcdBackupsOpts = &types.EtcdBackupsOpts{}
+
+var backupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "Lists and verifies etcd backup archives in a local directory",
+}
+
+var backupsListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "Lists the backup archives together with their metadata",
+	PreRunE: util.CheckRequiredFlags,
+	Run:     backupsListRun,
+}
+
+var backupsVerifyCmd = &cobra.Command{
+	Use:   "verify [archive...]",
+	Short: "Validates backup archives against their SHA-256 checksum",
+	Long: `Without arguments all archives in --dir are verified.
+The command fails when an archive does not match its checksum file or the checksum in its metadata.
+Encrypted archives are decrypted to verify their content when an identity or passphrase is available.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     backupsVerifyRun,
+}
+
+func init() {
+	EtcdCmd.AddCommand(backupsCmd)
+	backupsCmd.AddCommand(backupsListCmd)
+	backupsCmd.AddCommand(backupsVerifyCmd)
+	backupsVerifyCmd.Flags().StringSliceVar(&etcdBackupsOpts.Identities, "identity", []string{}, "age identity file to decrypt encrypted archives, can be repeated. Defaults to Backup.Identities of the config")
+	backupsCmd.PersistentFlags().StringVar(&etcdBackupsOpts.Dir, "dir", "", "Directory of the backup archives, defaults to the directory of the executable")
+}
+
+func backupsListRun(_ *cobra.Command, _ []string) {
+	pkg.BackupsList(createCommandContext(etcdBackupsOpts))
+}
+
+func backupsVerifyRun(_ *cobra.Command, args []string) {
+	etcdBackupsOpts.Archives = args
+	pkg.BackupsVerify(createCommandContext(etcdBackupsOpts))
+}
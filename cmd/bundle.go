@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+	"github.com/spf13/cobra"
+)
+
+var bundleOpts = &types.BundleOpts{}
+
+// bundleCmd represents the bundle command
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Collects diagnostics of the cluster into one archive",
+	Long: `Collects journal excerpts of the configured services, container logs, disk usage, uptime, dmesg, kernel and OS versions,
+	the kubelet configuration and the certificate expiry of every node in the selected groups. Nodes, events and kube-system pods
+	are added with kubectl from the first accessible master. The archive is laid out as <node>/<artifact> with an index.txt.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     bundleRun,
+}
+
+func init() {
+	RootCmd.AddCommand(bundleCmd)
+	bundleCmd.Flags().StringVarP(&bundleOpts.Groups, "groups", "g", types.ALL_GROUPNAME, "Comma-separated list of group names")
+	bundleCmd.Flags().StringVarP(&bundleOpts.Output, "output", "o", "", "Archive to write, defaults to cluster-<date>.tar.gz")
+	bundleCmd.Flags().BoolVar(&bundleOpts.Sudo, "sudo", false, "Run commands as sudo")
+	bundleCmd.Flags().IntVarP(&bundleOpts.Tail, "tail", "t", 1000, "Lines of recent logs to collect per service and container")
+	bundleCmd.Flags().StringVar(&bundleOpts.KubeletConfig, "kubelet-config", "/var/lib/kubelet/config.yaml", "Path of the kubelet config file on the nodes, supports templating")
+}
+
+func bundleRun(_ *cobra.Command, _ []string) {
+	pkg.Bundle(createCommandContext(bundleOpts))
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"os"
+
 	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/ssh"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/mrahbar/kubernetes-inspector/util"
 	"github.com/spf13/cobra"
@@ -13,7 +16,9 @@ var clusterStatusCmd = &cobra.Command{
 	Use:     "cluster-status",
 	Aliases: []string{"cs"},
 	Short:   "Performs various checks on the cluster defined in the configuration file",
-	Long:    `When called without arguments all hosts and checks in configuration will be executed.`,
+	Long: `When called without arguments all hosts and checks in configuration will be executed.
+The exit code reflects the overall verdict: 0 OK, 1 WARN, 2 ERROR, 3 UNKNOWN.
+An unknown group or output format, or --output together with --watch, exits with 3 UNKNOWN as well.`,
 	PreRunE: util.CheckRequiredFlags,
 	Run:     clusterStatusRun,
 }
@@ -21,11 +26,17 @@ var clusterStatusCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(clusterStatusCmd)
 	clusterStatusCmd.Flags().StringVarP(&clusterStatusOpts.Groups, "groups", "g", "", "Comma-separated list of group names")
-	clusterStatusCmd.Flags().StringVarP(&clusterStatusOpts.Checks, "checks", "c", "", "Comma-separated list of checks. E.g. Services,Containers,Certificates,DiskUsage or Kubernetes")
+	clusterStatusCmd.Flags().StringVarP(&clusterStatusOpts.Checks, "checks", "c", "", "Comma-separated list of checks. E.g. Services,Containers,Certificates,DiskUsage, Kubernetes or names of custom checks")
 	clusterStatusCmd.Flags().BoolVar(&clusterStatusOpts.Sudo, "sudo", false, "Run commands as sudo")
 	clusterStatusCmd.Flags().BoolVar(&clusterStatusOpts.SkipStats, "skip-stats", false, "Skip initial node stats")
+	clusterStatusCmd.Flags().StringVarP(&clusterStatusOpts.Output, "output", "o", "", "Print a machine-readable report to stdout. One of: json|yaml")
+	clusterStatusCmd.Flags().DurationVar(&clusterStatusOpts.Watch, "watch", 0, "Re-run the checks on this interval and print only what changed, e.g. 30s. Can not be combined with --output")
 }
 
 func clusterStatusRun(_ *cobra.Command, _ []string) {
-    pkg.ClusterStatus(createCommandContext(clusterStatusOpts))
+    code := pkg.ClusterStatus(createCommandContext(clusterStatusOpts))
+    if code != pkg.EXIT_OK {
+        ssh.CloseConnections()
+        os.Exit(code)
+    }
 }
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+var compactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Compacts the etcd keyspace to the current revision minus --retention",
+	Long: `Compaction discards the history of all revisions older than the target revision.
+The space is only returned to the file system after a defragmentation.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     compactRun,
+}
+
+func init() {
+	EtcdCmd.AddCommand(compactCmd)
+	addEtcdClusterFlags(compactCmd.Flags())
+	compactCmd.Flags().Int64Var(&etcdClusterOpts.Retention, "retention", 10000, "Number of revisions to keep")
+	compactCmd.Flags().BoolVar(&etcdClusterOpts.Physical, "physical", false, "Wait until the compaction is physically applied on all members")
+}
+
+func compactRun(_ *cobra.Command, _ []string) {
+	pkg.EtcdCompact(createCommandContext(etcdClusterOpts))
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+var defragCmd = &cobra.Command{
+	Use:   "defrag",
+	Short: "Defragments every etcd member one at a time, the leader last",
+	Long: `Before each member the health of all members is checked and the defragmentation is aborted when one is unhealthy.
+The db size of every member is reported before and after the defragmentation.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     defragRun,
+}
+
+func init() {
+	EtcdCmd.AddCommand(defragCmd)
+	addEtcdClusterFlags(defragCmd.Flags())
+	defragCmd.Flags().DurationVar(&etcdClusterOpts.CommandTimeout, "command-timeout", 5*time.Minute, "Timeout of etcdctl defrag for a single member")
+}
+
+func defragRun(_ *cobra.Command, _ []string) {
+	pkg.EtcdDefrag(createCommandContext(etcdClusterOpts))
+}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+var etcdClusterOpts = &types.EtcdClusterOpts{}
+
 // etcdCmd represents the etcd command
 var EtcdCmd = &cobra.Command{
 	Use:   "etcd",
@@ -14,3 +18,18 @@ var EtcdCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(EtcdCmd)
 }
+
+// addEtcdTLSFlags registers the TLS flags, files which are not given are taken from the Etcd group of the config
+func addEtcdTLSFlags(flags *pflag.FlagSet, opts *types.EtcdOpts) {
+	flags.BoolVar(&opts.ClientCertAuth, "secure", false, "Secure etcd communication, implied when a client certificate is configured")
+	flags.StringVar(&opts.ClientCertFile, "client-cert", "", "path to client certificate, defaults to Etcd.ClientCertFile of the Etcd group")
+	flags.StringVar(&opts.ClientKeyFile, "client-cert-key", "", "path to client certificate key, defaults to Etcd.ClientKeyFile of the Etcd group")
+	flags.StringVar(&opts.CaFile, "ca-cert", "", "path to certificate authority, defaults to Etcd.CaFile of the Etcd group")
+}
+
+// addEtcdClusterFlags registers the flags of commands which query every node of the Etcd group
+func addEtcdClusterFlags(flags *pflag.FlagSet) {
+	addEtcdTLSFlags(flags, &etcdClusterOpts.EtcdOpts)
+	flags.IntVar(&etcdClusterOpts.ClientPort, "client-port", 0, "Client port of etcd on the nodes, defaults to Etcd.ClientPort of the Etcd group or 2379")
+	flags.BoolVar(&etcdClusterOpts.Sudo, "sudo", false, "Run commands as sudo")
+}
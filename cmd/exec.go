@@ -27,6 +27,8 @@ func init() {
 	execCmd.Flags().StringVarP(&execOpts.TargetArg, "cmd", "c", "", "Command to execute")
 	execCmd.Flags().StringVarP(&execOpts.FileOutput, "file", "o", "", "File to save results of command. Screen output is suppressed")
 	execCmd.Flags().BoolVar(&execOpts.Sudo, "sudo", false, "Run as sudo")
+	execCmd.Flags().IntVar(&execOpts.Parallel, "parallel", 1, "Number of nodes processed concurrently")
+	execCmd.Flags().BoolVar(&execOpts.Buffered, "buffered", false, "Print the output once the command finished instead of streaming it line by line")
 
 	execCmd.MarkFlagRequired("cmd")
 }
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/ssh"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+var healthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Shows health, leader, raft state, db size and alarms of every etcd member",
+	Long: `etcdctl is called on every node of the Etcd group against its own client endpoint.
+Members whose raft index lags behind the leader more than --max-raft-lag are flagged.
+The exit code reflects the overall verdict like cluster-status: 0 OK, 1 WARN, 2 ERROR, 3 UNKNOWN.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     healthRun,
+}
+
+func init() {
+	EtcdCmd.AddCommand(healthCmd)
+	addEtcdClusterFlags(healthCmd.Flags())
+	healthCmd.Flags().Uint64Var(&etcdClusterOpts.MaxRaftLag, "max-raft-lag", 100, "Maximum number of raft entries a member may lag behind the leader")
+}
+
+func healthRun(_ *cobra.Command, _ []string) {
+	code := pkg.EtcdHealth(createCommandContext(etcdClusterOpts))
+	if code != pkg.EXIT_OK {
+		ssh.CloseConnections()
+		os.Exit(code)
+	}
+}
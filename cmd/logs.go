@@ -13,7 +13,8 @@ var logOpts = &types.LogsOpts{}
 var logsCmd = &cobra.Command{
 	Use:     "logs",
 	Short:   "Retrieve logs",
-	Long:    `Retrieve logs from system services, containers or pods`,
+	Long: `Retrieve logs from system services, containers or pods. Services and containers are read on every selected node.
+	Pod logs are retrieved once with kubectl on the first accessible master either for the pod given as element or for all pods matching the selector.`,
 	PreRunE: util.CheckRequiredFlags,
 	Run:     logRun,
 }
@@ -22,15 +23,23 @@ func init() {
 	RootCmd.AddCommand(logsCmd)
 	logsCmd.Flags().StringVarP(&logOpts.GroupArg, "group", "g", "", "Comma-separated list of group names")
 	logsCmd.Flags().StringVarP(&logOpts.NodeArg, "node", "n", "", "Name of target node")
-	logsCmd.Flags().StringVar(&logOpts.TargetArg, "element",  "", "Element to fetch logs from")
+	logsCmd.Flags().StringVar(&logOpts.TargetArg, "element",  "", "Element to fetch logs from, optional for pods selected by label")
 	logsCmd.Flags().StringVar(&logOpts.Type, "type",  "", "Element type either service, container or pod")
-	logsCmd.Flags().StringVarP(&logOpts.FileOutput, "file", "o", "", "File to save results of command. Screen output is suppressed")
+	logsCmd.Flags().StringVarP(&logOpts.FileOutput, "file", "o", "", "File to save results of command, for pods an existing directory receives one file per pod. Screen output is suppressed")
 	logsCmd.Flags().StringVar(&logOpts.Since, "since",  "", "Only return logs after a specific timestamp or relative time")
 	logsCmd.Flags().IntVarP(&logOpts.Tail, "tail", "t", -1, "Lines of recent log file to display. Defaults to -1 with no selector, showing all log lines")
 	logsCmd.Flags().StringArrayVar(&logOpts.ExtraArgs, "extra-arg", []string{}, "Additional command line args to execute")
 	logsCmd.Flags().BoolVar(&logOpts.Sudo, "sudo",false, "Run as sudo")
+	logsCmd.Flags().IntVar(&logOpts.Parallel, "parallel", 1, "Number of nodes processed concurrently")
+	logsCmd.Flags().BoolVar(&logOpts.Follow, "follow", false, "Follow the logs of all selected nodes as one stream ordered by timestamp until interrupted")
+	logsCmd.Flags().BoolVar(&logOpts.Buffered, "buffered", false, "Print the logs once they are fetched instead of streaming them line by line")
+
+	logsCmd.Flags().StringVar(&logOpts.Namespace, "namespace", "default", "Namespace of the pods")
+	logsCmd.Flags().StringVar(&logOpts.Container, "container", "", "Container of the pods")
+	logsCmd.Flags().StringVarP(&logOpts.Selector, "selector", "l", "", "Label selector of the pods, logs of all matching pods are retrieved")
+	logsCmd.Flags().BoolVar(&logOpts.Previous, "previous", false, "Logs of the previous instance of the pod containers")
+	logsCmd.Flags().BoolVar(&logOpts.AllContainers, "all-containers", false, "Logs of all containers of the pods")
 
-	logsCmd.MarkFlagRequired("element")
 	logsCmd.MarkFlagRequired("type")
 }
 
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+var membersCmd = &cobra.Command{
+	Use:     "members",
+	Short:   "Lists the members of the etcd cluster",
+	Long:    `The member list is queried from the first accessible node of the Etcd group.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     membersRun,
+}
+
+func init() {
+	EtcdCmd.AddCommand(membersCmd)
+	addEtcdClusterFlags(membersCmd.Flags())
+}
+
+func membersRun(_ *cobra.Command, _ []string) {
+	pkg.EtcdMembers(createCommandContext(etcdClusterOpts))
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/mrahbar/kubernetes-inspector/util"
 
 	"github.com/mrahbar/kubernetes-inspector/pkg"
@@ -8,14 +10,17 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var restartOpts = &types.GenericOpts{}
+var restartOpts = &types.RestartOpts{}
 
 // restartCmd represents the restart command
 var restartCmd = &cobra.Command{
 	Use:   "restart",
     Short: "Restarts a system service on a target group or node",
 	Long: `Service name is mandatory. Either specify node or group in which the service should be restarted.
-	When a target group is specified all nodes inside that group will be targeted for service restart.`,
+	When a target group is specified all nodes inside that group will be targeted for service restart.
+	With --rolling the nodes are restarted in batches, each node has to report the service active since the restart, pass the
+	health command and, with --wait-ready, a Ready heartbeat of the Kubernetes node sent after the restart before the next
+	batch starts. Kubernetes nodes are matched by their InternalIP. The rollout is aborted on the first failure.`,
 	PreRunE: util.CheckRequiredFlags,
 	Run:     restartRun,
 }
@@ -26,6 +31,14 @@ func init() {
 	restartCmd.Flags().StringVarP(&restartOpts.NodeArg, "node", "n", "", "Name of target node")
 	restartCmd.Flags().StringVarP(&restartOpts.TargetArg, "service", "s", "", "Name of target service")
 	restartCmd.Flags().BoolVar(&restartOpts.Sudo, "sudo", false, "Run commands as sudo")
+	restartCmd.Flags().IntVar(&restartOpts.Parallel, "parallel", 1, "Number of nodes processed concurrently")
+	restartCmd.Flags().BoolVar(&restartOpts.Rolling, "rolling", false, "Restart the nodes batch by batch and wait for each node to become healthy")
+	restartCmd.Flags().IntVar(&restartOpts.BatchSize, "batch-size", 1, "Number of nodes restarted at once in a rolling restart")
+	restartCmd.Flags().DurationVar(&restartOpts.Pause, "pause", 0, "Time to wait between the batches of a rolling restart, e.g. 30s")
+	restartCmd.Flags().DurationVar(&restartOpts.Timeout, "timeout", 5*time.Minute, "Maximum time a node may take to become healthy in a rolling restart")
+	restartCmd.Flags().StringVar(&restartOpts.HealthCmd, "health-cmd", "", "Command which has to succeed on a node after its restart, supports templating")
+	restartCmd.Flags().BoolVar(&restartOpts.WaitReady, "wait-ready", false, "Wait for the Kubernetes node Ready condition after the restart of a node")
+	restartCmd.Flags().DurationVar(&restartOpts.Settle, "settle", 0, "Time a node has to stay healthy after passing the checks of a rolling restart, e.g. 10s")
 	restartCmd.MarkFlagRequired("service")
 }
 
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+var etcdRestoreOpts = &types.EtcdRestoreOpts{}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restores an etcd cluster from a v3 snapshot backup",
+	Long: `The archive is uploaded to every node of the Etcd group and restored with the members taken from the node list.
+All members are stopped before their data directory is replaced and started together afterwards, the restore waits
+until every member reports a healthy endpoint. On failure the commands which undo the completed steps are printed.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     restoreRun,
+}
+
+func init() {
+	EtcdCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().StringVar(&etcdRestoreOpts.Archive, "archive", "", "Backup archive created by etcd backup --api v3")
+	restoreCmd.Flags().StringVar(&etcdRestoreOpts.DataDir, "data-dir", "/var/lib/etcd", "Data directory of etcd on the nodes")
+	restoreCmd.Flags().StringVar(&etcdRestoreOpts.Service, "service", "etcd", "Systemd service of etcd")
+	restoreCmd.Flags().StringVar(&etcdRestoreOpts.StaticPodManifest, "static-pod-manifest", "", "Manifest of etcd when running as static pod, e.g. /etc/kubernetes/manifests/etcd.yaml. Used instead of the service")
+	restoreCmd.Flags().StringVar(&etcdRestoreOpts.PeerScheme, "peer-scheme", "https", "Scheme of the peer urls")
+	restoreCmd.Flags().IntVar(&etcdRestoreOpts.PeerPort, "peer-port", 2380, "Port of the peer urls")
+	restoreCmd.Flags().StringVar(&etcdRestoreOpts.ClusterToken, "initial-cluster-token", "etcd-cluster", "Initial cluster token of the restored cluster")
+	restoreCmd.Flags().BoolVar(&etcdRestoreOpts.Sudo, "sudo", false, "Run commands as sudo")
+	restoreCmd.Flags().BoolVar(&etcdRestoreOpts.Yes, "yes", false, "Confirm that the data of all etcd members is replaced")
+	restoreCmd.Flags().StringSliceVar(&etcdRestoreOpts.Identities, "identity", []string{}, "age identity file to decrypt encrypted archives, can be repeated. Defaults to Backup.Identities of the config")
+	restoreCmd.Flags().DurationVar(&etcdRestoreOpts.Timeout, "timeout", 5*time.Minute, "Maximum time etcd may take to stop and the restored members to become healthy")
+	addEtcdTLSFlags(restoreCmd.Flags(), &etcdRestoreOpts.EtcdOpts)
+	restoreCmd.Flags().IntVar(&etcdRestoreOpts.ClientPort, "client-port", 0, "Client port of etcd on the nodes, defaults to Etcd.ClientPort of the Etcd group or 2379")
+	restoreCmd.MarkFlagRequired("archive")
+}
+
+func restoreRun(_ *cobra.Command, _ []string) {
+	pkg.Restore(createCommandContext(etcdRestoreOpts))
+}
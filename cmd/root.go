@@ -37,7 +37,10 @@ var RootCmd = &cobra.Command{
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute(buildInfos BuildInformation) {
 	BuildInfos = buildInfos
-	if err := RootCmd.Execute(); err != nil {
+	err := RootCmd.Execute()
+	ssh.CloseConnections()
+
+	if err != nil {
 		os.Exit(-1)
 	}
 }
@@ -63,13 +66,12 @@ func initConfig() {
 
 	// If a config file is found, read it in.
 	err := viper.ReadInConfig()
-	if err == nil {
-        integration.PrettyPrint("Loading config file: %s", viper.ConfigFileUsed())
-	} else {
+	if err != nil {
         integration.PrettyPrintCritical("Error loading config file: %s", err.Error())
     }
 
     setLogLevel()
+    printer.PrintDebug("Loading config file: %s", viper.ConfigFileUsed())
 }
 
 func setLogLevel() {
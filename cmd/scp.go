@@ -24,6 +24,7 @@ func init() {
 	scpCmd.Flags().StringVarP(&scpOpts.TargetArg, "direction", "t", "", "Must either be 'up' or 'down' resp. first letter.")
 	scpCmd.Flags().StringVarP(&scpOpts.LocalPath, "localPath", "l", "", "This is the source when direction is 'up' or the target when direction is 'down' ")
 	scpCmd.Flags().StringVarP(&scpOpts.RemotePath, "remotePath", "r", "", "This is the target when direction is 'up' or the source when direction is 'down' ")
+	scpCmd.Flags().IntVar(&scpOpts.Parallel, "parallel", 1, "Number of nodes processed concurrently")
 
 	scpCmd.MarkFlagRequired("direction")
 	scpCmd.MarkFlagRequired("localPath")
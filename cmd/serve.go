@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+	"github.com/spf13/cobra"
+)
+
+var serveOpts = &types.ServeOpts{}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Runs the cluster-status checks periodically and exposes the results as Prometheus metrics",
+	Long: `The checks of cluster-status are performed on every interval, the latest results are served on /metrics.
+When called without groups all groups in configuration will be checked.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     serveRun,
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveOpts.Listen, "listen", ":9100", "Address to serve metrics on")
+	serveCmd.Flags().DurationVar(&serveOpts.Interval, "interval", time.Minute, "Interval between two check runs")
+	serveCmd.Flags().StringVarP(&serveOpts.Groups, "groups", "g", types.ALL_GROUPNAME, "Comma-separated list of group names")
+	serveCmd.Flags().StringVarP(&serveOpts.Checks, "checks", "c", "", "Comma-separated list of checks. E.g. Services,Containers,Certificates,DiskUsage, Kubernetes or names of custom checks")
+	serveCmd.Flags().BoolVar(&serveOpts.Sudo, "sudo", false, "Run commands as sudo")
+	serveCmd.Flags().BoolVar(&serveOpts.SkipStats, "skip-stats", false, "Skip node stats")
+}
+
+func serveRun(_ *cobra.Command, _ []string) {
+	pkg.Serve(createCommandContext(serveOpts))
+}
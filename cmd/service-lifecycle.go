@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+	"github.com/spf13/cobra"
+)
+
+// serviceActionCmds represent the start, enable, disable, mask and unmask commands, the values describe the action
+var serviceActionCmds = map[string]string{
+	"start":   "Start a system service",
+	"enable":  "Enable a system service to start at boot",
+	"disable": "Disable a system service to no longer start at boot",
+	"mask":    "Mask a system service so that it can not be started at all",
+	"unmask":  "Unmask a system service",
+}
+
+var daemonReloadOpts = &types.GenericOpts{}
+
+// daemonReloadCmd represents the daemon-reload command
+var daemonReloadCmd = &cobra.Command{
+	Use:   "daemon-reload",
+	Short: "Reload the systemd manager configuration on a target group or node",
+	Long: `Reloads all unit files after they were changed. Either specify node or group in which systemd should be reloaded.
+	When a target group is specified all nodes inside that group will be targeted.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     daemonReloadRun,
+}
+
+func init() {
+	for action, short := range serviceActionCmds {
+		opts := &types.GenericOpts{}
+		name := action
+		cmd := &cobra.Command{
+			Use:   name,
+			Short: short + " on a target group or node",
+			Long: fmt.Sprintf(`Service name is mandatory. Either specify node or group on which to %s the service.
+	When a target group is specified all nodes inside that group will be targeted.`, name),
+			PreRunE: util.CheckRequiredFlags,
+			Run: func(_ *cobra.Command, _ []string) {
+				pkg.ServiceAction(name, createCommandContext(opts))
+			},
+		}
+
+		ServiceCmd.AddCommand(cmd)
+		cmd.Flags().StringVarP(&opts.GroupArg, "group", "g", "", "Comma-separated list of group names")
+		cmd.Flags().StringVarP(&opts.NodeArg, "node", "n", "", "Name of target node")
+		cmd.Flags().StringVarP(&opts.TargetArg, "service", "s", "", "Name of target service")
+		cmd.Flags().BoolVar(&opts.Sudo, "sudo", false, "Run commands as sudo")
+		cmd.Flags().IntVar(&opts.Parallel, "parallel", 1, "Number of nodes processed concurrently")
+		cmd.MarkFlagRequired("service")
+	}
+
+	ServiceCmd.AddCommand(daemonReloadCmd)
+	daemonReloadCmd.Flags().StringVarP(&daemonReloadOpts.GroupArg, "group", "g", "", "Comma-separated list of group names")
+	daemonReloadCmd.Flags().StringVarP(&daemonReloadOpts.NodeArg, "node", "n", "", "Name of target node")
+	daemonReloadCmd.Flags().BoolVar(&daemonReloadOpts.Sudo, "sudo", false, "Run commands as sudo")
+	daemonReloadCmd.Flags().IntVar(&daemonReloadOpts.Parallel, "parallel", 1, "Number of nodes processed concurrently")
+}
+
+func daemonReloadRun(_ *cobra.Command, _ []string) {
+	pkg.DaemonReload(createCommandContext(daemonReloadOpts))
+}
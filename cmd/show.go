@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/mrahbar/kubernetes-inspector/pkg"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+	"github.com/spf13/cobra"
+)
+
+var showOpts = &types.GenericOpts{}
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Compare the properties of a system service across a target group or nodes",
+	Long: `Service name is mandatory. Prints ActiveEnterTimestamp, NRestarts, MainPID, MemoryCurrent and ExecStart of the service
+	on every selected node in one table. Nodes whose ExecStart differs from the majority are highlighted.`,
+	PreRunE: util.CheckRequiredFlags,
+	Run:     showRun,
+}
+
+func init() {
+	ServiceCmd.AddCommand(showCmd)
+	showCmd.Flags().StringVarP(&showOpts.GroupArg, "group", "g", "", "Comma-separated list of group names")
+	showCmd.Flags().StringVarP(&showOpts.NodeArg, "node", "n", "", "Name of target node")
+	showCmd.Flags().StringVarP(&showOpts.TargetArg, "service", "s", "", "Name of target service")
+	showCmd.Flags().BoolVar(&showOpts.Sudo, "sudo", false, "Run commands as sudo")
+	showCmd.MarkFlagRequired("service")
+}
+
+func showRun(_ *cobra.Command, _ []string) {
+	pkg.ServiceShow(createCommandContext(showOpts))
+}
@@ -26,6 +26,7 @@ func init() {
 	statusCmd.Flags().StringVarP(&statusOpts.NodeArg, "node", "n", "", "Name of target node")
 	statusCmd.Flags().StringVarP(&statusOpts.TargetArg, "service", "s", "", "Name of target service")
 	statusCmd.Flags().BoolVar(&statusOpts.Sudo, "sudo", false, "Run commands as sudo")
+	statusCmd.Flags().IntVar(&statusOpts.Parallel, "parallel", 1, "Number of nodes processed concurrently")
 	statusCmd.MarkFlagRequired("service")
 }
 
@@ -26,6 +26,7 @@ func init() {
 	stopCmd.Flags().StringVarP(&stopOpts.NodeArg, "node", "n", "", "Name of target node")
 	stopCmd.Flags().StringVarP(&stopOpts.TargetArg, "service", "s", "", "Name of target service")
 	stopCmd.Flags().BoolVar(&stopOpts.Sudo, "sudo", false, "Run commands as sudo")
+	stopCmd.Flags().IntVar(&stopOpts.Parallel, "parallel", 1, "Number of nodes processed concurrently")
 	stopCmd.MarkFlagRequired("service")
 }
 
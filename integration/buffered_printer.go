@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"sync"
+)
+
+// flushLock serializes the replay of all BufferedLogWriter instances onto their targets
+var flushLock sync.Mutex
+
+// BufferedLogWriter records print calls and replays them on the target LogWriter when Flush is called.
+// It is used to print the output of concurrently processed nodes as atomic blocks.
+type BufferedLogWriter struct {
+	target LogWriter
+	mu     sync.Mutex
+	calls  []func(LogWriter)
+}
+
+func NewBufferedLogWriter(target LogWriter) *BufferedLogWriter {
+	return &BufferedLogWriter{target: target}
+}
+
+// Flush replays all recorded calls on the target LogWriter and resets the buffer
+func (b *BufferedLogWriter) Flush() {
+	b.mu.Lock()
+	calls := b.calls
+	b.calls = nil
+	b.mu.Unlock()
+
+	flushLock.Lock()
+	defer flushLock.Unlock()
+	for _, call := range calls {
+		call(b.target)
+	}
+}
+
+// Run records call to be run with the target on Flush. Output files are written this way so that
+// concurrently processed nodes append to them in the same order as they print.
+func (b *BufferedLogWriter) Run(call func(LogWriter)) {
+	b.record(call)
+}
+
+func (b *BufferedLogWriter) record(call func(LogWriter)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = append(b.calls, call)
+}
+
+func (b *BufferedLogWriter) PrintNewLine() {
+	b.record(func(w LogWriter) { w.PrintNewLine() })
+}
+
+func (b *BufferedLogWriter) PrintHeader(msg string, padding byte) {
+	b.record(func(w LogWriter) { w.PrintHeader(msg, padding) })
+}
+
+func (b *BufferedLogWriter) Print(msg string, a ...interface{}) {
+	b.record(func(w LogWriter) { w.Print(msg, a...) })
+}
+
+// PrintCritical is not buffered, everything recorded so far is flushed before the target terminates the program
+func (b *BufferedLogWriter) PrintCritical(msg string, a ...interface{}) {
+	b.Flush()
+	flushLock.Lock()
+	defer flushLock.Unlock()
+	b.target.PrintCritical(msg, a...)
+}
+
+func (b *BufferedLogWriter) PrintErr(msg string, a ...interface{}) {
+	b.record(func(w LogWriter) { w.PrintErr(msg, a...) })
+}
+
+func (b *BufferedLogWriter) PrintWarn(msg string, a ...interface{}) {
+	b.record(func(w LogWriter) { w.PrintWarn(msg, a...) })
+}
+
+func (b *BufferedLogWriter) PrintIgnored(msg string, a ...interface{}) {
+	b.record(func(w LogWriter) { w.PrintIgnored(msg, a...) })
+}
+
+func (b *BufferedLogWriter) PrintOk(msg string, a ...interface{}) {
+	b.record(func(w LogWriter) { w.PrintOk(msg, a...) })
+}
+
+func (b *BufferedLogWriter) PrintInfo(msg string, a ...interface{}) {
+	b.record(func(w LogWriter) { w.PrintInfo(msg, a...) })
+}
+
+func (b *BufferedLogWriter) PrintDebug(msg string, a ...interface{}) {
+	b.record(func(w LogWriter) { w.PrintDebug(msg, a...) })
+}
+
+func (b *BufferedLogWriter) PrintTrace(msg string, a ...interface{}) {
+	b.record(func(w LogWriter) { w.PrintTrace(msg, a...) })
+}
+
+func (b *BufferedLogWriter) PrintUnknown(msg string, a ...interface{}) {
+	b.record(func(w LogWriter) { w.PrintUnknown(msg, a...) })
+}
+
+func (b *BufferedLogWriter) PrintSkipped(msg string, a ...interface{}) {
+	b.record(func(w LogWriter) { w.PrintSkipped(msg, a...) })
+}
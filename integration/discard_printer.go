@@ -0,0 +1,38 @@
+package integration
+
+// DiscardLogWriter drops all print calls. Only PrintCritical is forwarded to the target, which terminates the program.
+type DiscardLogWriter struct {
+	target LogWriter
+}
+
+func NewDiscardLogWriter(target LogWriter) *DiscardLogWriter {
+	return &DiscardLogWriter{target: target}
+}
+
+func (d *DiscardLogWriter) PrintNewLine() {}
+
+func (d *DiscardLogWriter) PrintHeader(msg string, padding byte) {}
+
+func (d *DiscardLogWriter) Print(msg string, a ...interface{}) {}
+
+func (d *DiscardLogWriter) PrintCritical(msg string, a ...interface{}) {
+	d.target.PrintCritical(msg, a...)
+}
+
+func (d *DiscardLogWriter) PrintErr(msg string, a ...interface{}) {}
+
+func (d *DiscardLogWriter) PrintWarn(msg string, a ...interface{}) {}
+
+func (d *DiscardLogWriter) PrintIgnored(msg string, a ...interface{}) {}
+
+func (d *DiscardLogWriter) PrintOk(msg string, a ...interface{}) {}
+
+func (d *DiscardLogWriter) PrintInfo(msg string, a ...interface{}) {}
+
+func (d *DiscardLogWriter) PrintDebug(msg string, a ...interface{}) {}
+
+func (d *DiscardLogWriter) PrintTrace(msg string, a ...interface{}) {}
+
+func (d *DiscardLogWriter) PrintUnknown(msg string, a ...interface{}) {}
+
+func (d *DiscardLogWriter) PrintSkipped(msg string, a ...interface{}) {}
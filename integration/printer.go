@@ -30,6 +30,11 @@ const tabWidth = 124
 
 var out io.Writer = os.Stdout
 
+// SetOutput redirects all printed messages to w, e.g. to keep stdout free for machine-readable output
+func SetOutput(w io.Writer) {
+	out = w
+}
+
 var Green = color.New(color.FgGreen)
 var Red = color.New(color.FgRed)
 var Orange = color.New(color.FgRed, color.FgYellow)
@@ -145,8 +150,7 @@ func (p *Printer) PrintNewLine() {
 func (p *Printer) PrintHeader(msg string, padding byte) {
 	w := tabwriter.NewWriter(out, tabWidth, 0, 0, padding, 0)
 	fmt.Fprintln(w, "")
-	format := msg + " \t\n"
-	fmt.Fprintf(w, format)
+	fmt.Fprint(w, msg+" \t\n")
 	w.Flush()
 }
 
@@ -181,7 +185,6 @@ func printMsg(msg, status string, a ...interface{}) {
 
 	var msgBuffer bytes.Buffer
 	fmt.Fprintf(&msgBuffer, msg, a...)
-	//TODO remove % only
 	carriageReturnSplits := strings.Split(msgBuffer.String(), "\n")
 	msg = ""
 	for _, cr := range carriageReturnSplits {
@@ -194,7 +197,7 @@ func printMsg(msg, status string, a ...interface{}) {
 	}
 
 	// print message
-	fmt.Fprintf(w, strings.TrimFunc(msg, func(r rune) bool { return r == ' ' })+"\t")
+	fmt.Fprint(w, strings.TrimFunc(msg, func(r rune) bool { return r == ' ' })+"\t")
 
 	// print status
 	if status != noType {
@@ -0,0 +1,307 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+const etcdBackupPrefix = "etcd-backup-"
+const etcdBackupSuffix = ".tar.gz"
+const etcdBackupTimeLayout = "2006-01-02T15-04-05"
+
+// backupArchive is a backup archive found in a backup directory, the time is taken from its name
+type backupArchive struct {
+	Path string
+	Time time.Time
+}
+
+func etcdBackupArchiveName(t time.Time) string {
+	return etcdBackupPrefix + t.Format(etcdBackupTimeLayout) + etcdBackupSuffix
+}
+
+func etcdBackupChecksumFile(archive string) string {
+	return archive + ".sha256"
+}
+
+// listBackupArchives returns the backup archives in dir, newest first
+func listBackupArchives(dir string) ([]backupArchive, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	archives := []backupArchive{}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if t, ok := parseBackupArchiveTime(file.Name()); ok {
+			archives = append(archives, backupArchive{Path: filepath.Join(dir, file.Name()), Time: t})
+		}
+	}
+
+	sortBackupArchives(archives)
+	return archives, nil
+}
+
+// parseBackupArchiveTime returns the time of a plain or encrypted archive name, false for other files
+func parseBackupArchiveTime(name string) (time.Time, bool) {
+	plainName := strings.TrimSuffix(name, etcdBackupEncryptedSuffix)
+	if !strings.HasPrefix(plainName, etcdBackupPrefix) || !strings.HasSuffix(plainName, etcdBackupSuffix) {
+		return time.Time{}, false
+	}
+
+	timestamp := strings.TrimSuffix(strings.TrimPrefix(plainName, etcdBackupPrefix), etcdBackupSuffix)
+	t, err := time.ParseInLocation(etcdBackupTimeLayout, timestamp, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func sortBackupArchives(archives []backupArchive) {
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].Time.After(archives[j].Time)
+	})
+}
+
+// expiredBackups returns the archives which are neither among the newest keep archives nor younger than keepWithin.
+// A policy which is zero is not applied, without any policy nothing expires. Archives must be sorted newest first.
+func expiredBackups(archives []backupArchive, keep int, keepWithin time.Duration, now time.Time) []backupArchive {
+	expired := []backupArchive{}
+	if keep <= 0 && keepWithin <= 0 {
+		return expired
+	}
+
+	for i, archive := range archives {
+		if keep > 0 && i < keep {
+			continue
+		}
+		if keepWithin > 0 && now.Sub(archive.Time) <= keepWithin {
+			continue
+		}
+		expired = append(expired, archive)
+	}
+	return expired
+}
+
+func pruneBackups(dir string, keep int, keepWithin time.Duration, now time.Time) {
+	archives, err := listBackupArchives(dir)
+	if err != nil {
+		printer.PrintErr("Error listing backups in %s: %s", dir, err)
+		return
+	}
+
+	expired := expiredBackups(archives, keep, keepWithin, now)
+	for _, archive := range expired {
+		removed := true
+		for _, file := range []string{archive.Path, etcdBackupChecksumFile(archive.Path), etcdBackupMetadataFile(archive.Path)} {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				printer.PrintErr("Error removing %s: %s", file, err)
+				removed = false
+			}
+		}
+		if removed {
+			printer.PrintInfo("Removed expired backup %s", archive.Path)
+		}
+	}
+
+	printer.PrintOk("Retention applied, %d of %d backups kept", len(archives)-len(expired), len(archives))
+}
+
+func fileSha256(file string) (string, int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// writeBackupChecksum writes the SHA-256 of the archive in the format of sha256sum
+func writeBackupChecksum(archive string) (string, int64, error) {
+	checksum, size, err := fileSha256(archive)
+	if err != nil {
+		return "", 0, err
+	}
+
+	content := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(archive))
+	return checksum, size, ioutil.WriteFile(etcdBackupChecksumFile(archive), []byte(content), 0644)
+}
+
+func readBackupChecksum(archive string) (string, error) {
+	data, err := ioutil.ReadFile(etcdBackupChecksumFile(archive))
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum file %s is empty", etcdBackupChecksumFile(archive))
+	}
+	return fields[0], nil
+}
+
+func readBackupMetadata(archive string) (*types.EtcdBackupMetadata, error) {
+	data, err := ioutil.ReadFile(etcdBackupMetadataFile(archive))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &types.EtcdBackupMetadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// verifyBackupArchive compares the archive with its checksum file and the checksum of its metadata.
+// Encrypted archives are additionally authenticated when an identity is given.
+func verifyBackupArchive(archive string, identities []age.Identity) (string, string) {
+	actual, _, err := fileSha256(archive)
+	if err != nil {
+		return types.STATUS_ERROR, fmt.Sprintf("not readable: %s", err)
+	}
+
+	expected, err := readBackupChecksum(archive)
+	if os.IsNotExist(err) {
+		return types.STATUS_WARN, "no checksum file"
+	} else if err != nil {
+		return types.STATUS_ERROR, err.Error()
+	}
+
+	if actual != expected {
+		return types.STATUS_ERROR, fmt.Sprintf("checksum mismatch, expected %s got %s", expected, actual)
+	}
+
+	if metadata, err := readBackupMetadata(archive); err == nil && metadata.Sha256 != "" && metadata.Sha256 != actual {
+		return types.STATUS_ERROR, fmt.Sprintf("checksum differs from metadata, expected %s got %s", metadata.Sha256, actual)
+	}
+
+	if isEncryptedBackup(archive) {
+		if len(identities) == 0 {
+			return types.STATUS_OK, "checksum valid, encrypted content not verified without identity"
+		}
+		if err := verifyEncryptedBackup(archive, identities); err != nil {
+			return types.STATUS_ERROR, fmt.Sprintf("decryption failed: %s", err)
+		}
+		return types.STATUS_OK, "checksum valid, decrypted successfully"
+	}
+
+	return types.STATUS_OK, "checksum valid"
+}
+
+func initBackups(cmdParams *types.CommandContext) *types.EtcdBackupsOpts {
+	initParams(cmdParams)
+	opts := cmdParams.Opts.(*types.EtcdBackupsOpts)
+
+	if opts.Dir == "" {
+		ex, err := util.GetExecutablePath()
+		if err != nil {
+			printer.PrintCritical("Could not get current executable path: %s", err)
+		}
+		opts.Dir = ex
+	}
+
+	return opts
+}
+
+func BackupsList(cmdParams *types.CommandContext) {
+	opts := initBackups(cmdParams)
+
+	archives, err := listBackupArchives(opts.Dir)
+	if err != nil {
+		printer.PrintCritical("Error listing backups in %s: %s", opts.Dir, err)
+	}
+	if len(archives) == 0 {
+		printer.PrintInfo("No backups found in %s", opts.Dir)
+		return
+	}
+
+	rows := [][]string{}
+	for _, archive := range archives {
+		row := []string{filepath.Base(archive.Path), archive.Time.Format("2006-01-02 15:04:05"), "-", "-", "-", "-", "-", "-", "no"}
+
+		if info, err := os.Stat(archive.Path); err == nil {
+			row[2] = formatBytes(info.Size())
+		}
+		if metadata, err := readBackupMetadata(archive.Path); err == nil {
+			row[3] = metadata.Api
+			row[4] = metadata.Node
+			if metadata.Version != "" {
+				row[5] = metadata.Version
+			}
+			if metadata.Revision != 0 {
+				row[6] = fmt.Sprintf("%d", metadata.Revision)
+			}
+		}
+		if _, err := os.Stat(etcdBackupChecksumFile(archive.Path)); err == nil {
+			row[7] = "yes"
+		}
+		if isEncryptedBackup(archive.Path) {
+			row[8] = "yes"
+		}
+
+		rows = append(rows, row)
+	}
+
+	printTable([]string{"ARCHIVE", "TIME", "SIZE", "API", "NODE", "VERSION", "REVISION", "CHECKSUM", "ENCRYPTED"}, rows, nil)
+}
+
+func BackupsVerify(cmdParams *types.CommandContext) {
+	opts := initBackups(cmdParams)
+
+	paths := opts.Archives
+	if len(paths) == 0 {
+		archives, err := listBackupArchives(opts.Dir)
+		if err != nil {
+			printer.PrintCritical("Error listing backups in %s: %s", opts.Dir, err)
+		}
+		for _, archive := range archives {
+			paths = append(paths, archive.Path)
+		}
+	}
+	if len(paths) == 0 {
+		printer.PrintInfo("No backups found in %s", opts.Dir)
+		return
+	}
+
+	identities, err := backupIdentities(opts.Identities)
+	if err != nil {
+		printer.PrintCritical("Error reading identities: %s", err)
+	}
+
+	failed := 0
+	for _, archive := range paths {
+		status, msg := verifyBackupArchive(archive, identities)
+		printStatus(status, fmt.Sprintf("%s: %s", filepath.Base(archive), msg))
+		if status == types.STATUS_ERROR {
+			failed++
+		}
+	}
+
+	printer.PrintNewLine()
+	if failed > 0 {
+		printer.PrintCritical("%d of %d backups failed verification", failed, len(paths))
+	}
+	printer.PrintOk("%d backups verified", len(paths))
+}
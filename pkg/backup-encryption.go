@@ -0,0 +1,191 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Backup archives are encrypted in the age v1 format (https://age-encryption.org/v1), they can also be
+// decrypted with the age command line tool using the same identity or passphrase.
+
+const BACKUP_PASSPHRASE_ENV = "KUBESPECTOR_BACKUP_PASSPHRASE"
+
+const etcdBackupEncryptedSuffix = ".age"
+
+// ageScryptWorkFactor is the scrypt work factor of passphrase encrypted archives, 2^18 as used by the age tool
+var ageScryptWorkFactor = 18
+
+// readAgeIdentityFile reads all secret keys of an identity file as written by age-keygen, comments are skipped
+func readAgeIdentityFile(file string) ([]age.Identity, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return identities, nil
+}
+
+func isEncryptedBackup(archive string) bool {
+	return strings.HasSuffix(archive, etcdBackupEncryptedSuffix)
+}
+
+// backupPassphrase returns the passphrase of the environment, falling back to the config
+func backupPassphrase() string {
+	if passphrase := os.Getenv(BACKUP_PASSPHRASE_ENV); passphrase != "" {
+		return passphrase
+	}
+	return config.Backup.Passphrase
+}
+
+// backupRecipients returns the recipients to encrypt backups to, none when encryption is not configured
+func backupRecipients(recipients []string) ([]age.Recipient, error) {
+	if len(recipients) == 0 {
+		recipients = config.Backup.Recipients
+	}
+
+	result := []age.Recipient{}
+	for _, recipient := range recipients {
+		r, err := age.ParseX25519Recipient(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %s: %s", recipient, err)
+		}
+		result = append(result, r)
+	}
+
+	if passphrase := backupPassphrase(); passphrase != "" {
+		if len(result) > 0 {
+			return nil, errors.New("a passphrase can not be combined with recipients")
+		}
+		r, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		r.SetWorkFactor(ageScryptWorkFactor)
+		result = append(result, r)
+	}
+
+	return result, nil
+}
+
+// backupIdentities returns the identities to decrypt backups with from the identity files and the passphrase
+func backupIdentities(identityFiles []string) ([]age.Identity, error) {
+	if len(identityFiles) == 0 {
+		identityFiles = config.Backup.Identities
+	}
+
+	identities := []age.Identity{}
+	for _, file := range identityFiles {
+		fileIdentities, err := readAgeIdentityFile(file)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, fileIdentities...)
+	}
+
+	if passphrase := backupPassphrase(); passphrase != "" {
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, nil
+}
+
+// encryptBackup encrypts the archive to encrypted, which is removed again if the encryption fails
+func encryptBackup(archive string, encrypted string, recipients []age.Recipient) error {
+	in, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(encrypted, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := ageEncrypt(out, in, recipients); err != nil {
+		out.Close()
+		os.Remove(encrypted)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(encrypted)
+		return err
+	}
+
+	return nil
+}
+
+func ageEncrypt(dst io.Writer, src io.Reader, recipients []age.Recipient) error {
+	w, err := age.Encrypt(dst, recipients...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// decryptBackup decrypts the archive to a temporary file which the caller has to remove
+func decryptBackup(archive string, identities []age.Identity) (string, error) {
+	in, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile("", "etcd-backup")
+	if err != nil {
+		return "", err
+	}
+
+	if err := ageDecrypt(out, in, identities); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+
+	return out.Name(), nil
+}
+
+func ageDecrypt(dst io.Writer, src io.Reader, identities []age.Identity) error {
+	if len(identities) == 0 {
+		return errors.New("no identity given")
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, r)
+	return err
+}
+
+// verifyEncryptedBackup authenticates the whole payload of the archive without keeping the plaintext
+func verifyEncryptedBackup(archive string, identities []age.Identity) error {
+	in, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return ageDecrypt(ioutil.Discard, in, identities)
+}
@@ -0,0 +1,174 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "bytes"
+    "os"
+    "path/filepath"
+    "strings"
+    "io/ioutil"
+    "filippo.io/age"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
+)
+
+func generateAgeKey(t *testing.T) (string, string) {
+    identity, err := age.GenerateX25519Identity()
+    assert.Nil(t, err)
+    return identity.String(), identity.Recipient().String()
+}
+
+func TestAge_IdentityFile(t *testing.T) {
+    dir, err := ioutil.TempDir("", "age")
+    assert.Nil(t, err)
+    defer os.RemoveAll(dir)
+
+    file := filepath.Join(dir, "identity.txt")
+    assert.Nil(t, ioutil.WriteFile(file, []byte("# created: 2018-03-01\n"+
+        "AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX\n"), 0600))
+    identities, err := readAgeIdentityFile(file)
+    assert.Nil(t, err)
+    assert.Len(t, identities, 1)
+
+    assert.Nil(t, ioutil.WriteFile(file, []byte("AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEY\n"), 0600))
+    _, err = readAgeIdentityFile(file)
+    assert.NotNil(t, err)
+
+    assert.Nil(t, ioutil.WriteFile(file, []byte("# no keys\n"), 0600))
+    _, err = readAgeIdentityFile(file)
+    assert.NotNil(t, err)
+}
+
+func TestAge_Recipients(t *testing.T) {
+    _, _, cmdContext := defaultContext()
+    _, recipient := generateAgeKey(t)
+    cmdContext.Config.Backup.Recipients = []string{recipient}
+    initParams(cmdContext)
+
+    recipients, err := backupRecipients(nil)
+    assert.Nil(t, err)
+    assert.Len(t, recipients, 1)
+
+    _, err = backupRecipients([]string{"age1invalid"})
+    assert.NotNil(t, err)
+
+    config.Backup.Passphrase = "pass"
+    _, err = backupRecipients(nil)
+    assert.EqualError(t, err, "a passphrase can not be combined with recipients")
+}
+
+func TestAge_EncryptDecrypt(t *testing.T) {
+    identity, recipient := generateAgeKey(t)
+    _, otherRecipient := generateAgeKey(t)
+
+    r, err := age.ParseX25519Recipient(recipient)
+    assert.Nil(t, err)
+    other, err := age.ParseX25519Recipient(otherRecipient)
+    assert.Nil(t, err)
+    i, err := age.ParseX25519Identity(identity)
+    assert.Nil(t, err)
+
+    var encrypted bytes.Buffer
+    assert.Nil(t, ageEncrypt(&encrypted, strings.NewReader("secret"), []age.Recipient{other, r}))
+    assert.True(t, strings.HasPrefix(encrypted.String(), "age-encryption.org/v1\n-> X25519 "))
+
+    var decrypted bytes.Buffer
+    assert.Nil(t, ageDecrypt(&decrypted, bytes.NewReader(encrypted.Bytes()), []age.Identity{i}))
+    assert.Equal(t, "secret", decrypted.String())
+
+    tampered := encrypted.Bytes()
+    tampered[len(tampered)-1] ^= 1
+    assert.NotNil(t, ageDecrypt(ioutil.Discard, bytes.NewReader(tampered), []age.Identity{i}))
+
+    encrypted.Reset()
+    assert.Nil(t, ageEncrypt(&encrypted, strings.NewReader("secret"), []age.Recipient{other}))
+    assert.NotNil(t, ageDecrypt(ioutil.Discard, bytes.NewReader(encrypted.Bytes()), []age.Identity{i}))
+    assert.EqualError(t, ageDecrypt(ioutil.Discard, bytes.NewReader(encrypted.Bytes()), nil), "no identity given")
+}
+
+func TestAge_Passphrase(t *testing.T) {
+    workFactor := ageScryptWorkFactor
+    ageScryptWorkFactor = 10
+    defer func() { ageScryptWorkFactor = workFactor }()
+
+    _, _, cmdContext := defaultContext()
+    cmdContext.Config.Backup.Passphrase = "pass"
+    initParams(cmdContext)
+
+    recipients, err := backupRecipients(nil)
+    assert.Nil(t, err)
+    var encrypted bytes.Buffer
+    assert.Nil(t, ageEncrypt(&encrypted, strings.NewReader("secret"), recipients))
+    assert.True(t, strings.HasPrefix(encrypted.String(), "age-encryption.org/v1\n-> scrypt "))
+
+    identities, err := backupIdentities(nil)
+    assert.Nil(t, err)
+    var decrypted bytes.Buffer
+    assert.Nil(t, ageDecrypt(&decrypted, bytes.NewReader(encrypted.Bytes()), identities))
+    assert.Equal(t, "secret", decrypted.String())
+
+    wrong, err := age.NewScryptIdentity("wrong")
+    assert.Nil(t, err)
+    assert.NotNil(t, ageDecrypt(ioutil.Discard, bytes.NewReader(encrypted.Bytes()), []age.Identity{wrong}))
+}
+
+func TestBackup_Encrypted(t *testing.T) {
+    identity, recipient := generateAgeKey(t)
+    _, context, _, dir := etcdContext(t)
+    defer os.RemoveAll(dir)
+    context.Opts = &types.EtcdBackupOpts{
+        Output:     dir,
+        Api:        types.ETCD_API_V3,
+        Recipients: []string{recipient},
+        EtcdOpts:   types.EtcdOpts{Endpoint: "http://127.0.0.1:2379"},
+    }
+
+    // the plain archive is only downloaded into a private temporary file
+    var plain string
+    context.CommandExecutor.(*sshTest.MockExecutor).MockDownloadFile = func(remotePath string, localPath string) error {
+        plain = localPath
+        info, err := os.Stat(localPath)
+        assert.Nil(t, err)
+        assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+        return ioutil.WriteFile(localPath, []byte("archive"), 0644)
+    }
+
+    Backup(context)
+
+    assert.NotEqual(t, dir, filepath.Dir(plain))
+    _, err := os.Stat(plain)
+    assert.True(t, os.IsNotExist(err))
+
+    output := context.Opts.(*types.EtcdBackupOpts).Output
+    assert.True(t, strings.HasSuffix(output, ".tar.gz.age"))
+    _, err = os.Stat(strings.TrimSuffix(output, ".age"))
+    assert.True(t, os.IsNotExist(err))
+
+    metadata, err := readBackupMetadata(output)
+    assert.Nil(t, err)
+    assert.True(t, metadata.Encrypted)
+
+    identityFile := filepath.Join(dir, "identity.txt")
+    assert.Nil(t, ioutil.WriteFile(identityFile, []byte("# created: 2018-03-01\n"+identity+"\n"), 0600))
+    identities, err := readAgeIdentityFile(identityFile)
+    assert.Nil(t, err)
+
+    status, msg := verifyBackupArchive(output, identities)
+    assert.Equal(t, types.STATUS_OK, status)
+    assert.Equal(t, "checksum valid, decrypted successfully", msg)
+
+    status, _ = verifyBackupArchive(output, nil)
+    assert.Equal(t, types.STATUS_OK, status)
+
+    decrypted, err := decryptBackup(output, identities)
+    assert.Nil(t, err)
+    defer os.Remove(decrypted)
+    content, err := ioutil.ReadFile(decrypted)
+    assert.Nil(t, err)
+    assert.Equal(t, "archive", string(content))
+
+    archives, err := listBackupArchives(dir)
+    assert.Nil(t, err)
+    assert.Len(t, archives, 1)
+}
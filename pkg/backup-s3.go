@@ -0,0 +1,286 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	minio "github.com/minio/minio-go"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+const s3DefaultRegion = "us-east-1"
+const s3DefaultPartSize = 16 * 1024 * 1024
+const s3MinPartSize = 5 * 1024 * 1024
+
+// s3IdleTimeout aborts a request when the endpoint neither accepts nor sends data for this long,
+// so a stalled endpoint fails the upload instead of hanging the backup
+var s3IdleTimeout = 2 * time.Minute
+
+// s3Client wraps the minio client with the bucket and prefix backups are stored in
+type s3Client struct {
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	region   string
+	partSize int64
+	core     minio.Core
+}
+
+// newS3Client returns nil when no backup target is configured
+func newS3Client(cfg types.S3Config) (*s3Client, error) {
+	if cfg.Endpoint == "" && cfg.Bucket == "" {
+		return nil, nil
+	}
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("endpoint and bucket are required")
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") ||
+		strings.Trim(endpoint.Path, "/") != "" {
+		return nil, fmt.Errorf("invalid endpoint %s", cfg.Endpoint)
+	}
+
+	c := &s3Client{
+		endpoint: endpoint,
+		bucket:   cfg.Bucket,
+		prefix:   strings.Trim(cfg.Prefix, "/"),
+		region:   cfg.Region,
+		partSize: s3DefaultPartSize,
+	}
+
+	if c.region == "" {
+		c.region = s3DefaultRegion
+	}
+	accessKey := firstNonEmpty(cfg.AccessKey, os.Getenv("AWS_ACCESS_KEY_ID"))
+	secretKey := firstNonEmpty(cfg.SecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("credentials are required, either in the config or in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	if cfg.PartSize != "" {
+		c.partSize, err = util.ParseSize(cfg.PartSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid part size: %s", err)
+		}
+		if c.partSize < s3MinPartSize {
+			return nil, fmt.Errorf("part size %s is smaller than 5M", cfg.PartSize)
+		}
+	}
+
+	client, err := minio.NewWithRegion(endpoint.Host, accessKey, secretKey, endpoint.Scheme == "https", c.region)
+	if err != nil {
+		return nil, err
+	}
+	client.SetCustomTransport(s3Transport())
+	c.core = minio.Core{Client: client}
+
+	return c, nil
+}
+
+// s3Transport bounds every phase of a request, the idle timeout covers a stall while a part is transferred
+func s3Transport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			return &idleTimeoutConn{Conn: conn, timeout: s3IdleTimeout}, nil
+		},
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: s3IdleTimeout,
+		ExpectContinueTimeout: time.Second,
+		DisableCompression:    true,
+	}
+}
+
+// idleTimeoutConn extends the deadline of the connection on every read and write
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *idleTimeoutConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *s3Client) key(name string) string {
+	if c.prefix == "" {
+		return name
+	}
+	return c.prefix + "/" + name
+}
+
+// s3ResponseError prefixes the message of an S3 error response with its code
+func s3ResponseError(err error) error {
+	if response, ok := err.(minio.ErrorResponse); ok && response.Code != "" {
+		return fmt.Errorf("%s: %s", response.Code, response.Message)
+	}
+	return err
+}
+
+func (c *s3Client) putObject(key string, body []byte) error {
+	_, err := c.core.PutObject(c.bucket, key, bytes.NewReader(body), int64(len(body)), "", "", nil, nil)
+	return s3ResponseError(err)
+}
+
+func (c *s3Client) deleteObject(key string) error {
+	return s3ResponseError(c.core.RemoveObject(c.bucket, key))
+}
+
+// listObjects returns all objects whose key starts with the prefix of the client
+func (c *s3Client) listObjects() ([]minio.ObjectInfo, error) {
+	prefix := ""
+	if c.prefix != "" {
+		prefix = c.prefix + "/"
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	objects := []minio.ObjectInfo{}
+	for object := range c.core.Client.ListObjectsV2(c.bucket, prefix, true, done) {
+		if object.Err != nil {
+			return nil, s3ResponseError(object.Err)
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// uploadFile uploads the file in parts of partSize, the upload is aborted on failure
+func (c *s3Client) uploadFile(key string, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	uploadId, err := c.core.NewMultipartUpload(c.bucket, key, minio.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("initiating multipart upload: %s", s3ResponseError(err))
+	}
+
+	parts := []minio.CompletePart{}
+	part := make([]byte, c.partSize)
+	for number := 1; ; number++ {
+		n, readErr := io.ReadFull(f, part)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			c.abortUpload(key, uploadId)
+			return readErr
+		}
+		// An empty file is uploaded as a single empty part
+		if n == 0 && number > 1 {
+			break
+		}
+
+		uploaded, err := c.core.PutObjectPart(c.bucket, key, uploadId, number, bytes.NewReader(part[:n]), int64(n), "", "", nil)
+		if err != nil {
+			c.abortUpload(key, uploadId)
+			return fmt.Errorf("uploading part %d: %s", number, s3ResponseError(err))
+		}
+		parts = append(parts, minio.CompletePart{PartNumber: number, ETag: uploaded.ETag})
+
+		if n < len(part) {
+			break
+		}
+	}
+
+	if _, err := c.core.CompleteMultipartUpload(c.bucket, key, uploadId, parts); err != nil {
+		c.abortUpload(key, uploadId)
+		return fmt.Errorf("completing multipart upload: %s", s3ResponseError(err))
+	}
+
+	return nil
+}
+
+func (c *s3Client) abortUpload(key string, uploadId string) {
+	if err := c.core.AbortMultipartUpload(c.bucket, key, uploadId); err != nil {
+		printer.PrintDebug("Could not abort multipart upload %s: %s", uploadId, s3ResponseError(err))
+	}
+}
+
+// uploadBackup uploads the archive followed by its checksum and metadata
+func uploadBackup(c *s3Client, archive string) error {
+	if err := c.uploadFile(c.key(filepath.Base(archive)), archive); err != nil {
+		return err
+	}
+
+	for _, file := range []string{etcdBackupChecksumFile(archive), etcdBackupMetadataFile(archive)} {
+		data, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := c.putObject(c.key(filepath.Base(file)), data); err != nil {
+			return fmt.Errorf("uploading %s: %s", filepath.Base(file), err)
+		}
+	}
+
+	return nil
+}
+
+// pruneRemoteBackups applies the retention to the archives below the prefix, other objects are left untouched
+func pruneRemoteBackups(c *s3Client, keep int, keepWithin time.Duration, now time.Time) {
+	objects, err := c.listObjects()
+	if err != nil {
+		printer.PrintErr("Error listing remote backups: %s", err)
+		return
+	}
+
+	archives := []backupArchive{}
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, c.key(""))
+		if strings.Contains(name, "/") {
+			continue
+		}
+		if t, ok := parseBackupArchiveTime(name); ok {
+			archives = append(archives, backupArchive{Path: object.Key, Time: t})
+		}
+	}
+	sortBackupArchives(archives)
+
+	expired := expiredBackups(archives, keep, keepWithin, now)
+	for _, archive := range expired {
+		removed := true
+		for _, key := range []string{archive.Path, etcdBackupChecksumFile(archive.Path), etcdBackupMetadataFile(archive.Path)} {
+			if err := c.deleteObject(key); err != nil {
+				printer.PrintErr("Error removing remote object %s: %s", key, err)
+				removed = false
+			}
+		}
+		if removed {
+			printer.PrintInfo("Removed expired remote backup %s", archive.Path)
+		}
+	}
+
+	printer.PrintOk("Remote retention applied, %d of %d backups kept", len(archives)-len(expired), len(archives))
+}
@@ -0,0 +1,244 @@
+package pkg
+
+import (
+    "testing"
+    "bytes"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "net/http"
+    "net/http/httptest"
+    "encoding/xml"
+    "io/ioutil"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "path/filepath"
+)
+
+// fakeS3 is an in-memory S3 compatible server which checks the access key of every request
+type fakeS3 struct {
+    sync.Mutex
+    t       *testing.T
+    bucket  string
+    objects map[string][]byte
+    uploads map[string]map[int][]byte
+    parts   []int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+    s := &fakeS3{t: t, bucket: "backups", objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+    return s, httptest.NewServer(s)
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    s.Lock()
+    defer s.Unlock()
+
+    body, _ := ioutil.ReadAll(r.Body)
+    if r.Header.Get("X-Amz-Decoded-Content-Length") != "" {
+        body = decodeAwsChunked(body)
+    }
+
+    if !strings.Contains(r.Header.Get("Authorization"), "Credential=access/") {
+        w.WriteHeader(http.StatusForbidden)
+        fmt.Fprint(w, "<Error><Code>InvalidAccessKeyId</Code><Message>invalid access key</Message></Error>")
+        return
+    }
+
+    path := strings.TrimPrefix(r.URL.Path, "/")
+    if path != s.bucket && !strings.HasPrefix(path, s.bucket+"/") {
+        w.WriteHeader(http.StatusNotFound)
+        fmt.Fprint(w, "<Error><Code>NoSuchBucket</Code><Message>no such bucket</Message></Error>")
+        return
+    }
+    key := strings.TrimPrefix(strings.TrimPrefix(path, s.bucket), "/")
+    query := r.URL.Query()
+
+    switch {
+    case r.Method == "GET" && key == "":
+        s.list(w, query.Get("prefix"), query.Get("continuation-token"))
+    case r.Method == "POST" && len(query["uploads"]) == 1:
+        uploadId := fmt.Sprintf("upload-%d", len(s.uploads)+1)
+        s.uploads[uploadId] = map[int][]byte{}
+        fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadId)
+    case r.Method == "PUT" && query.Get("uploadId") != "":
+        var number int
+        fmt.Sscanf(query.Get("partNumber"), "%d", &number)
+        s.uploads[query.Get("uploadId")][number] = body
+        s.parts = append(s.parts, len(body))
+        w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+    case r.Method == "POST" && query.Get("uploadId") != "":
+        complete := struct {
+            Parts []struct {
+                PartNumber int
+                ETag       string
+            } `xml:"Part"`
+        }{}
+        xml.Unmarshal(body, &complete)
+        data := []byte{}
+        for _, part := range complete.Parts {
+            assert.Equal(s.t, fmt.Sprintf("etag-%d", part.PartNumber), strings.Trim(part.ETag, `"`))
+            data = append(data, s.uploads[query.Get("uploadId")][part.PartNumber]...)
+        }
+        s.objects[key] = data
+        delete(s.uploads, query.Get("uploadId"))
+        fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key></CompleteMultipartUploadResult>", s.bucket, key)
+    case r.Method == "PUT":
+        s.objects[key] = body
+    case r.Method == "DELETE":
+        delete(s.objects, key)
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        w.WriteHeader(http.StatusBadRequest)
+    }
+}
+
+// decodeAwsChunked strips the chunk headers of a body sent with the streaming signature
+func decodeAwsChunked(body []byte) []byte {
+    data := []byte{}
+    for {
+        header := body[:bytes.Index(body, []byte("\r\n"))]
+        var size int
+        fmt.Sscanf(string(header), "%x;", &size)
+        if size == 0 {
+            return data
+        }
+        start := len(header) + 2
+        data = append(data, body[start:start+size]...)
+        body = body[start+size+2:]
+    }
+}
+
+// list returns two keys per page to exercise continuation
+func (s *fakeS3) list(w http.ResponseWriter, prefix string, token string) {
+    keys := []string{}
+    for key := range s.objects {
+        if strings.HasPrefix(key, prefix) && key > token {
+            keys = append(keys, key)
+        }
+    }
+    sort.Strings(keys)
+
+    fmt.Fprint(w, "<ListBucketResult>")
+    for i, key := range keys {
+        if i == 2 {
+            fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>", keys[1])
+            break
+        }
+        fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", key, len(s.objects[key]))
+    }
+    fmt.Fprint(w, "</ListBucketResult>")
+}
+
+func (s *fakeS3) keys() []string {
+    s.Lock()
+    defer s.Unlock()
+    keys := []string{}
+    for key := range s.objects {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+func TestS3_Config(t *testing.T) {
+    c, err := newS3Client(types.S3Config{})
+    assert.Nil(t, err)
+    assert.Nil(t, c)
+
+    _, err = newS3Client(types.S3Config{Endpoint: "http://127.0.0.1:9000"})
+    assert.NotNil(t, err)
+    _, err = newS3Client(types.S3Config{Endpoint: "127.0.0.1:9000", Bucket: "b", AccessKey: "a", SecretKey: "s"})
+    assert.NotNil(t, err)
+    _, err = newS3Client(types.S3Config{Endpoint: "http://127.0.0.1:9000/s3", Bucket: "b", AccessKey: "a", SecretKey: "s"})
+    assert.NotNil(t, err)
+    _, err = newS3Client(types.S3Config{Endpoint: "http://127.0.0.1:9000", Bucket: "b", AccessKey: "a", SecretKey: "s", PartSize: "1M"})
+    assert.NotNil(t, err)
+
+    c, err = newS3Client(types.S3Config{Endpoint: "http://127.0.0.1:9000", Bucket: "b", Prefix: "/etcd/", AccessKey: "a", SecretKey: "s"})
+    assert.Nil(t, err)
+    assert.Equal(t, s3DefaultRegion, c.region)
+    assert.Equal(t, "etcd/archive", c.key("archive"))
+}
+
+func TestS3_MultipartUploadAndRetention(t *testing.T) {
+    fake, server := newFakeS3(t)
+    defer server.Close()
+    _, _, context := defaultContext()
+    initParams(context)
+
+    c, err := newS3Client(types.S3Config{Endpoint: server.URL, Bucket: "backups", Prefix: "etcd", Region: "eu-central-1",
+        AccessKey: "access", SecretKey: "secret", PartSize: "5M"})
+    assert.Nil(t, err)
+
+    dir, err := ioutil.TempDir("", "etcd-backup")
+    assert.Nil(t, err)
+    defer os.RemoveAll(dir)
+
+    now := time.Date(2018, 3, 20, 12, 0, 0, 0, time.Local)
+    archive := writeTestBackup(t, dir, now)
+    content := make([]byte, 11*1024*1024+3)
+    for i := range content {
+        content[i] = byte(i)
+    }
+    assert.Nil(t, ioutil.WriteFile(archive, content, 0644))
+
+    assert.Nil(t, uploadBackup(c, archive))
+    assert.Equal(t, []int{5 * 1024 * 1024, 5 * 1024 * 1024, 1024*1024 + 3}, fake.parts)
+    assert.Equal(t, content, fake.objects["etcd/"+filepath.Base(archive)])
+    assert.Contains(t, fake.keys(), "etcd/"+filepath.Base(archive)+".sha256")
+    assert.Contains(t, fake.keys(), "etcd/"+filepath.Base(archive)+".meta.json")
+
+    for _, days := range []int{1, 2, 10} {
+        name := etcdBackupArchiveName(now.AddDate(0, 0, -days))
+        fake.objects["etcd/"+name] = []byte{}
+        fake.objects["etcd/"+name+".sha256"] = []byte{}
+    }
+    fake.objects["etcd/other/"+etcdBackupArchiveName(now.AddDate(0, 0, -30))] = []byte{}
+    fake.objects["unrelated"] = []byte{}
+
+    pruneRemoteBackups(c, 2, 0, now)
+
+    assert.Equal(t, []string{
+        "etcd/" + etcdBackupArchiveName(now.AddDate(0, 0, -1)),
+        "etcd/" + etcdBackupArchiveName(now.AddDate(0, 0, -1)) + ".sha256",
+        "etcd/" + filepath.Base(archive),
+        "etcd/" + filepath.Base(archive) + ".meta.json",
+        "etcd/" + filepath.Base(archive) + ".sha256",
+        "etcd/other/" + etcdBackupArchiveName(now.AddDate(0, 0, -30)),
+        "unrelated",
+    }, fake.keys())
+}
+
+func TestS3_AccessError(t *testing.T) {
+    _, server := newFakeS3(t)
+    defer server.Close()
+
+    c, err := newS3Client(types.S3Config{Endpoint: server.URL, Bucket: "backups", Region: "eu-central-1", AccessKey: "wrong", SecretKey: "secret"})
+    assert.Nil(t, err)
+
+    err = c.putObject("key", []byte("data"))
+    assert.EqualError(t, err, "InvalidAccessKeyId: invalid access key")
+}
+
+func TestBackup_Upload(t *testing.T) {
+    fake, server := newFakeS3(t)
+    defer server.Close()
+
+    _, context, _, dir := etcdContext(t)
+    defer os.RemoveAll(dir)
+    context.Config.Backup.S3 = types.S3Config{Endpoint: server.URL, Bucket: "backups", Region: "eu-central-1", AccessKey: "access", SecretKey: "secret"}
+    context.Opts = &types.EtcdBackupOpts{Output: dir, Api: types.ETCD_API_V3, Keep: 1, EtcdOpts: types.EtcdOpts{Endpoint: "http://127.0.0.1:2379"}}
+
+    old := etcdBackupArchiveName(time.Now().AddDate(0, 0, -1))
+    fake.objects[old] = []byte("old")
+
+    Backup(context)
+
+    name := filepath.Base(context.Opts.(*types.EtcdBackupOpts).Output)
+    assert.Equal(t, []string{name, name + ".meta.json", name + ".sha256"}, fake.keys())
+    assert.Equal(t, "archive", string(fake.objects[name]))
+}
@@ -1,18 +1,23 @@
 package pkg
 
 import (
+	"encoding/json"
 	"fmt"
+	"filippo.io/age"
 	"github.com/mrahbar/kubernetes-inspector/ssh"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/mrahbar/kubernetes-inspector/util"
 	"path"
-	"path/filepath"
 	"strings"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"time"
 )
 
 const localBackupDir = "/tmp"
 const localEtcdBackupDir = "/tmp/etcd-backup"
+const etcdSnapshotName = "snapshot.db"
 
 var etcdBackupOpts *types.EtcdBackupOpts
 var archiveName string
@@ -21,6 +26,37 @@ func Backup(cmdParams *types.CommandContext) {
     initParams(cmdParams)
     etcdBackupOpts = cmdParams.Opts.(*types.EtcdBackupOpts)
 
+    if etcdBackupOpts.Api == "" {
+        etcdBackupOpts.Api = types.ETCD_API_V2
+    }
+    if etcdBackupOpts.Api != types.ETCD_API_V2 && etcdBackupOpts.Api != types.ETCD_API_V3 {
+        printer.PrintCritical("Unknown etcd api %s. Valid values: %s, %s", etcdBackupOpts.Api, types.ETCD_API_V2, types.ETCD_API_V3)
+    }
+    if etcdBackupOpts.Api == types.ETCD_API_V2 && etcdBackupOpts.DataDir == "" {
+        printer.PrintCritical("Parameter data-dir is required for etcd api %s", types.ETCD_API_V2)
+    }
+    if etcdBackupOpts.Keep < 0 {
+        printer.PrintCritical("Parameter keep must not be negative")
+    }
+    keepWithin := time.Duration(0)
+    if etcdBackupOpts.KeepWithin != "" {
+        d, err := util.ParseDuration(etcdBackupOpts.KeepWithin)
+        if err != nil {
+            printer.PrintCritical("Parameter keep-within: %s", err)
+        }
+        keepWithin = d
+    }
+
+    recipients, err := backupRecipients(etcdBackupOpts.Recipients)
+    if err != nil {
+        printer.PrintCritical("Invalid backup encryption: %s", err)
+    }
+
+    s3, err := newS3Client(config.Backup.S3)
+    if err != nil {
+        printer.PrintCritical("Invalid backup target: %s", err)
+    }
+
     group := util.FindGroupByName(cmdParams.Config.ClusterGroups, types.ETCD_GROUPNAME)
 
 	if group.Nodes == nil || len(group.Nodes) == 0 {
@@ -34,14 +70,62 @@ func Backup(cmdParams *types.CommandContext) {
 	}
 
 	cmdExecutor.SetNode(node)
+	etcdBackupOpts.EtcdOpts = etcdNodeOpts(etcdBackupOpts.EtcdOpts, 0, node)
+	printer.PrintInfo("Backing up etcd at %s on node %s", etcdBackupOpts.Endpoint, util.ToNodeLabel(node))
     printer.PrintNewLine()
 	initializeOutputFile()
-	backup()
-	transferBackup()
+
+	var snapshotStatus *types.EtcdSnapshotStatus
+	if etcdBackupOpts.Api == types.ETCD_API_V3 {
+		snapshotStatus = snapshotBackup()
+	} else {
+		backup()
+	}
+
+	endpointStatus := queryBackupEndpointStatus()
+
+	if len(recipients) > 0 {
+		encrypted, err := transferEncryptedBackup(recipients)
+		if err != nil {
+			printer.PrintCritical("Error encrypting backup: %s", err)
+		}
+		etcdBackupOpts.Output = encrypted
+		printer.PrintOk("Etcd backup encrypted to %s", encrypted)
+	} else {
+		if err := transferBackup(etcdBackupOpts.Output); err != nil {
+			printer.PrintCritical("%s", err)
+		}
+		printer.PrintOk("Etcd backup is at %s", etcdBackupOpts.Output)
+	}
+
+	checksum, size, err := writeBackupChecksum(etcdBackupOpts.Output)
+	if err != nil {
+		printer.PrintErr("Error writing backup checksum: %s", err)
+	} else {
+		printer.PrintOk("Backup checksum is at %s", etcdBackupChecksumFile(etcdBackupOpts.Output))
+	}
+
+	writeBackupMetadata(node, snapshotStatus, endpointStatus, checksum, size)
+
+	if s3 != nil {
+		printer.PrintInfo("Uploading backup to bucket %s", s3.bucket)
+		if err := uploadBackup(s3, etcdBackupOpts.Output); err != nil {
+			printer.PrintCritical("Error uploading backup, it is only available at %s: %s", etcdBackupOpts.Output, err)
+		}
+		printer.PrintOk("Etcd backup uploaded to %s/%s/%s", s3.endpoint, s3.bucket, s3.key(filepath.Base(etcdBackupOpts.Output)))
+	}
+
+	if etcdBackupOpts.Keep > 0 || keepWithin > 0 {
+		now := time.Now()
+		pruneBackups(filepath.Dir(etcdBackupOpts.Output), etcdBackupOpts.Keep, keepWithin, now)
+		if s3 != nil {
+			pruneRemoteBackups(s3, etcdBackupOpts.Keep, keepWithin, now)
+		}
+	}
 }
 
 func initializeOutputFile() {
-	archiveName = fmt.Sprintf("etcd-backup-%s.tar.gz", strings.Replace(time.Now().Format("2006-01-02T15:04:05"), ":", "-", -1))
+	archiveName = etcdBackupArchiveName(time.Now())
 
 	if etcdBackupOpts.Output == "" {
 		ex, err := util.GetExecutablePath()
@@ -79,28 +163,151 @@ func backup() {
     printer.PrintNewLine()
 }
 
-func transferBackup() {
-    printer.PrintInfo("Creating archive of etcd backup")
-	backupArchive := path.Join(localBackupDir, archiveName)
+func etcdV3Connection() string {
+	return fmt.Sprintf("--endpoints='%s'%s", etcdBackupOpts.Endpoint, etcdTLSArgs(etcdBackupOpts.EtcdOpts))
+}
+
+// queryBackupEndpointStatus returns version and revision of the backed up endpoint for the metadata, nil when unavailable
+func queryBackupEndpointStatus() *types.EtcdEndpointStatus {
+	statusCmd := fmt.Sprintf("env ETCDCTL_API=3 etcdctl %s endpoint status --write-out=json", etcdV3Connection())
+	sshOut, err := cmdExecutor.PerformCmd(statusCmd, etcdBackupOpts.Sudo)
+	if err != nil {
+		printer.PrintWarn("Could not query version and revision of %s: %s", etcdBackupOpts.Endpoint, err)
+		return nil
+	}
+
+	statuses := []types.EtcdEndpointStatus{}
+	if err := json.Unmarshal([]byte(sshOut.Stdout), &statuses); err != nil || len(statuses) == 0 {
+		printer.PrintWarn("Could not parse endpoint status of %s: %s", etcdBackupOpts.Endpoint, sshOut.Stdout)
+		return nil
+	}
+	return &statuses[0]
+}
+
+// snapshotBackup saves the v3 keyspace via etcdctl snapshot save and verifies the snapshot afterwards
+func snapshotBackup() *types.EtcdSnapshotStatus {
+	etcdConnection := etcdV3Connection()
+
+	printer.PrintInfo("Start snapshot backup process")
+	snapshotFile := path.Join(localEtcdBackupDir, etcdSnapshotName)
+	cmdExecutor.PerformCmd(fmt.Sprintf("rm -rf %s", localEtcdBackupDir), etcdBackupOpts.Sudo)
+	cmdExecutor.PerformCmd(fmt.Sprintf("mkdir -p -m 700 %s", localEtcdBackupDir), etcdBackupOpts.Sudo)
+
+	// env is used as sudo may refuse to set variables itself
+	snapshotCmd := fmt.Sprintf("env ETCDCTL_API=3 etcdctl %s snapshot save %s", etcdConnection, snapshotFile)
+	_, err := cmdExecutor.PerformCmd(snapshotCmd, etcdBackupOpts.Sudo)
+	if err != nil {
+		printer.PrintCritical("Error trying to create etcd snapshot: %s", err)
+	}
+
+	statusCmd := fmt.Sprintf("env ETCDCTL_API=3 etcdctl snapshot status %s --write-out=json", snapshotFile)
+	sshOut, err := cmdExecutor.PerformCmd(statusCmd, etcdBackupOpts.Sudo)
+	if err != nil {
+		printer.PrintCritical("Error trying to verify etcd snapshot: %s", err)
+	}
+
+	status := &types.EtcdSnapshotStatus{}
+	if err := json.Unmarshal([]byte(sshOut.Stdout), status); err != nil {
+		printer.PrintCritical("Error parsing etcd snapshot status: %s", err)
+	}
+
+	// The snapshot contains every Secret of the cluster, only the SSH user which downloads it may access it.
+	// $(id -un) is expanded before sudo and thus names the SSH user.
+	restrictCmd := fmt.Sprintf("chown -R \"$(id -un)\" %s && chmod -R go-rwx %s", localEtcdBackupDir, localEtcdBackupDir)
+	if _, err := cmdExecutor.PerformCmd(restrictCmd, etcdBackupOpts.Sudo); err != nil {
+		printer.PrintCritical("Error restricting access to etcd snapshot: %s", err)
+	}
+	printer.PrintOk("Snapshot created and verified - Hash: %d Revision: %d Keys: %d Size: %d bytes",
+		status.Hash, status.Revision, status.TotalKey, status.TotalSize)
+	printer.PrintNewLine()
+
+	return status
+}
+
+// writeBackupMetadata stores details of the backup as JSON next to the archive
+func writeBackupMetadata(node types.Node, snapshotStatus *types.EtcdSnapshotStatus, endpointStatus *types.EtcdEndpointStatus,
+	checksum string, size int64) {
+	metadata := types.EtcdBackupMetadata{
+		Archive:   filepath.Base(etcdBackupOpts.Output),
+		Time:      time.Now(),
+		Node:      util.ToNodeLabel(node),
+		Endpoint:  etcdBackupOpts.Endpoint,
+		Api:       etcdBackupOpts.Api,
+		Size:      size,
+		Sha256:    checksum,
+		Encrypted: isEncryptedBackup(etcdBackupOpts.Output),
+		Snapshot:  snapshotStatus,
+	}
+	if endpointStatus != nil {
+		metadata.Version = endpointStatus.Status.Version
+		metadata.Revision = endpointStatus.Status.Header.Revision
+	}
+	// The snapshot revision is exact, the endpoint may have moved on in the meantime
+	if snapshotStatus != nil {
+		metadata.Revision = snapshotStatus.Revision
+	}
 
-	archiveCmd := fmt.Sprintf("tar -czvf %s -C %s .", backupArchive, localEtcdBackupDir)
-    _, err := cmdExecutor.PerformCmd(archiveCmd, etcdBackupOpts.Sudo)
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(etcdBackupMetadataFile(etcdBackupOpts.Output), data, 0644)
+	}
 
 	if err != nil {
-        printer.PrintCritical("Error trying to archive backup etcd: %s", err)
+		printer.PrintErr("Error writing backup metadata: %s", err)
 	} else {
-        cmdExecutor.DeleteRemoteFile(localEtcdBackupDir)
+		printer.PrintOk("Backup metadata is at %s", etcdBackupMetadataFile(etcdBackupOpts.Output))
+	}
+}
 
-        printer.PrintInfo("Transferring archive")
-        printer.PrintNewLine()
+func etcdBackupMetadataFile(archive string) string {
+	return archive + ".meta.json"
+}
 
-        err = cmdExecutor.DownloadFile(backupArchive, etcdBackupOpts.Output)
-        cmdExecutor.DeleteRemoteFile(backupArchive)
+// transferBackup archives the backup into a new directory only accessible by the ssh user and downloads it to localPath.
+// The backup directory was handed over to the ssh user, tar thus runs without sudo and creates the archive under umask 077.
+func transferBackup(localPath string) error {
+	printer.PrintInfo("Creating archive of etcd backup")
+	sshOut, err := cmdExecutor.PerformCmd(fmt.Sprintf("mktemp -d %s/etcd-backup-archive.XXXXXX", localBackupDir), false)
+	archiveDir := strings.TrimSpace(sshOut.Stdout)
+	if err == nil && archiveDir == "" {
+		err = fmt.Errorf("mktemp returned no directory")
+	}
+	if err != nil {
+		return fmt.Errorf("Error creating archive directory: %s", err)
+	}
+	defer cmdExecutor.PerformCmd(fmt.Sprintf("rm -rf %s", archiveDir), false)
 
-		if err != nil {
-            printer.PrintCritical("Error trying transfer backup archive: %s", err)
-		} else {
-            printer.PrintOk("Etcd backup is at %s", etcdBackupOpts.Output)
-		}
+	backupArchive := path.Join(archiveDir, archiveName)
+	archiveCmd := fmt.Sprintf("umask 077 && tar -czf %s -C %s .", backupArchive, localEtcdBackupDir)
+	_, err = cmdExecutor.PerformCmd(archiveCmd, false)
+	cmdExecutor.PerformCmd(fmt.Sprintf("rm -rf %s", localEtcdBackupDir), etcdBackupOpts.Sudo)
+	if err != nil {
+		return fmt.Errorf("Error trying to archive backup etcd: %s", err)
 	}
+
+	printer.PrintInfo("Transferring archive")
+	printer.PrintNewLine()
+
+	if err := cmdExecutor.DownloadFile(backupArchive, localPath); err != nil {
+		return fmt.Errorf("Error trying transfer backup archive: %s", err)
+	}
+	return nil
+}
+
+// transferEncryptedBackup downloads the plain archive into a temporary file only accessible by the current user,
+// which is removed once the archive is encrypted to the output
+func transferEncryptedBackup(recipients []age.Recipient) (string, error) {
+	plain, err := ioutil.TempFile("", "etcd-backup")
+	if err != nil {
+		return "", err
+	}
+	plain.Close()
+	defer os.Remove(plain.Name())
+
+	if err := transferBackup(plain.Name()); err != nil {
+		return "", err
+	}
+
+	encrypted := etcdBackupOpts.Output + etcdBackupEncryptedSuffix
+	return encrypted, encryptBackup(plain.Name(), encrypted, recipients)
 }
@@ -0,0 +1,202 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "github.com/bouk/monkey"
+    "os"
+    "bytes"
+    "strings"
+    "io/ioutil"
+    "encoding/json"
+    "path/filepath"
+    "time"
+)
+
+func etcdContext(t *testing.T) (*bytes.Buffer, *types.CommandContext, *[]string, string) {
+    mockExecutor, outBuffer, context := defaultContext()
+    context.Config.ClusterGroups = append(context.Config.ClusterGroups, types.ClusterGroup{
+        Name:  types.ETCD_GROUPNAME,
+        Nodes: []types.Node{{Host: "etcd1", IP: "10.0.0.1"}},
+    })
+
+    commands := &[]string{}
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        *commands = append(*commands, command)
+        if strings.Contains(command, "endpoint status") {
+            return &types.SSHOutput{Stdout: `[{"Endpoint":"https://127.0.0.1:2379","Status":{"header":{"member_id":1,"revision":14},"version":"3.3.10"}}]`}, nil
+        }
+        if strings.HasPrefix(command, "mktemp -d") {
+            return &types.SSHOutput{Stdout: "/tmp/etcd-backup-archive.a1b2c3\n"}, nil
+        }
+        if strings.Contains(command, "snapshot status") {
+            return &types.SSHOutput{Stdout: `{"hash":3937476497,"revision":12,"totalKey":15,"totalSize":20480}`}, nil
+        }
+        return &types.SSHOutput{}, nil
+    }
+    mockExecutor.MockDownloadFile = func(remotePath string, localPath string) error {
+        *commands = append(*commands, "download "+remotePath)
+        return ioutil.WriteFile(localPath, []byte("archive"), 0644)
+    }
+
+    dir, err := ioutil.TempDir("", "etcd-backup")
+    assert.Nil(t, err)
+
+    return outBuffer, context, commands, dir
+}
+
+func TestBackup_UnknownApi(t *testing.T) {
+    outBuffer, context, _, dir := etcdContext(t)
+    defer os.RemoveAll(dir)
+    context.Opts = &types.EtcdBackupOpts{Output: dir, Api: "v4"}
+
+    osExitCalled := false
+    patch := monkey.Patch(os.Exit, func(int) {
+        osExitCalled = true
+        panic("exit")
+    })
+    defer patch.Unpatch()
+
+    assert.Panics(t, func() { Backup(context) })
+    assert.True(t, osExitCalled)
+    assert.Contains(t, outBuffer.String(), "Unknown etcd api v4")
+}
+
+func TestBackup_SnapshotV3(t *testing.T) {
+    _, context, commands, dir := etcdContext(t)
+    defer os.RemoveAll(dir)
+    context.Opts = &types.EtcdBackupOpts{
+        Output: dir,
+        Api:    types.ETCD_API_V3,
+        EtcdOpts: types.EtcdOpts{
+            Endpoint:       "https://127.0.0.1:2379",
+            ClientCertAuth: true,
+            CaFile:         "/etc/etcd/ca.crt",
+            ClientCertFile: "/etc/etcd/client.crt",
+            ClientKeyFile:  "/etc/etcd/client.key",
+        },
+    }
+
+    Backup(context)
+
+    all := strings.Join(*commands, "\n")
+    assert.Contains(t, all, "env ETCDCTL_API=3 etcdctl --endpoints='https://127.0.0.1:2379' --cacert=/etc/etcd/ca.crt "+
+        "--cert=/etc/etcd/client.crt --key=/etc/etcd/client.key snapshot save /tmp/etcd-backup/snapshot.db")
+    assert.Contains(t, all, "env ETCDCTL_API=3 etcdctl snapshot status /tmp/etcd-backup/snapshot.db --write-out=json")
+    assert.Contains(t, all, "mkdir -p -m 700 /tmp/etcd-backup")
+    assert.Contains(t, all, "chown -R \"$(id -un)\" /tmp/etcd-backup && chmod -R go-rwx /tmp/etcd-backup")
+    assert.NotContains(t, all, "chmod -R 777")
+    assert.Contains(t, all, "mktemp -d /tmp/etcd-backup-archive.XXXXXX")
+    assert.Contains(t, all, "umask 077 && tar -czf /tmp/etcd-backup-archive.a1b2c3/"+archiveName+" -C /tmp/etcd-backup .")
+    assert.Contains(t, all, "download /tmp/etcd-backup-archive.a1b2c3/"+archiveName)
+    assert.Contains(t, all, "rm -rf /tmp/etcd-backup-archive.a1b2c3")
+    assert.NotContains(t, all, "--data-dir")
+
+    output := context.Opts.(*types.EtcdBackupOpts).Output
+    data, err := ioutil.ReadFile(output + ".meta.json")
+    assert.Nil(t, err)
+
+    metadata := types.EtcdBackupMetadata{}
+    assert.Nil(t, json.Unmarshal(data, &metadata))
+    assert.Equal(t, types.ETCD_API_V3, metadata.Api)
+    assert.Equal(t, "etcd1 (10.0.0.1)", metadata.Node)
+    assert.Equal(t, uint32(3937476497), metadata.Snapshot.Hash)
+    assert.Equal(t, int64(12), metadata.Snapshot.Revision)
+    assert.Equal(t, 15, metadata.Snapshot.TotalKey)
+    assert.Equal(t, "3.3.10", metadata.Version)
+    assert.Equal(t, int64(12), metadata.Revision)
+    assert.Equal(t, int64(7), metadata.Size)
+
+    actual, _, err := fileSha256(output)
+    assert.Nil(t, err)
+    assert.Equal(t, actual, metadata.Sha256)
+    sidecar, err := ioutil.ReadFile(output + ".sha256")
+    assert.Nil(t, err)
+    assert.Equal(t, actual+"  "+filepath.Base(output)+"\n", string(sidecar))
+}
+
+func writeTestBackup(t *testing.T, dir string, backupTime time.Time) string {
+    archive := filepath.Join(dir, etcdBackupArchiveName(backupTime))
+    assert.Nil(t, ioutil.WriteFile(archive, []byte(backupTime.String()), 0644))
+    _, _, err := writeBackupChecksum(archive)
+    assert.Nil(t, err)
+    assert.Nil(t, ioutil.WriteFile(etcdBackupMetadataFile(archive), []byte("{}"), 0644))
+    return archive
+}
+
+func TestBackup_Retention(t *testing.T) {
+    dir, err := ioutil.TempDir("", "etcd-backup")
+    assert.Nil(t, err)
+    defer os.RemoveAll(dir)
+
+    now := time.Date(2018, 3, 20, 12, 0, 0, 0, time.Local)
+    archives := []string{}
+    for _, days := range []int{0, 1, 2, 10, 20} {
+        archives = append(archives, writeTestBackup(t, dir, now.AddDate(0, 0, -days)))
+    }
+    assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "other.tar.gz"), []byte{}, 0644))
+
+    listed, err := listBackupArchives(dir)
+    assert.Nil(t, err)
+    assert.Len(t, listed, 5)
+    assert.Equal(t, archives[0], listed[0].Path)
+
+    assert.Len(t, expiredBackups(listed, 2, 0, now), 3)
+    assert.Len(t, expiredBackups(listed, 0, 14*24*time.Hour, now), 1)
+    assert.Len(t, expiredBackups(listed, 1, 36*time.Hour, now), 3)
+    assert.Empty(t, expiredBackups(listed, 0, 0, now))
+
+    _, _, context := defaultContext()
+    initParams(context)
+    pruneBackups(dir, 3, 0, now)
+
+    for i, archive := range archives {
+        for _, file := range []string{archive, archive + ".sha256", archive + ".meta.json"} {
+            _, err := os.Stat(file)
+            assert.Equal(t, i >= 3, os.IsNotExist(err), file)
+        }
+    }
+    _, err = os.Stat(filepath.Join(dir, "other.tar.gz"))
+    assert.Nil(t, err)
+}
+
+func TestBackupsVerify(t *testing.T) {
+    dir, err := ioutil.TempDir("", "etcd-backup")
+    assert.Nil(t, err)
+    defer os.RemoveAll(dir)
+
+    now := time.Date(2018, 3, 20, 12, 0, 0, 0, time.Local)
+    valid := writeTestBackup(t, dir, now)
+    corrupt := writeTestBackup(t, dir, now.Add(-time.Hour))
+    assert.Nil(t, ioutil.WriteFile(corrupt, []byte("corrupt"), 0644))
+    missing := filepath.Join(dir, etcdBackupArchiveName(now.Add(-2*time.Hour)))
+    assert.Nil(t, ioutil.WriteFile(missing, []byte("no checksum"), 0644))
+
+    status, _ := verifyBackupArchive(valid, nil)
+    assert.Equal(t, types.STATUS_OK, status)
+    status, msg := verifyBackupArchive(corrupt, nil)
+    assert.Equal(t, types.STATUS_ERROR, status)
+    assert.Contains(t, msg, "checksum mismatch")
+    status, _ = verifyBackupArchive(missing, nil)
+    assert.Equal(t, types.STATUS_WARN, status)
+
+    _, outBuffer, context := defaultContext()
+    context.Opts = &types.EtcdBackupsOpts{Dir: dir}
+
+    osExitCalled := false
+    patch := monkey.Patch(os.Exit, func(int) {
+        osExitCalled = true
+        panic("exit")
+    })
+    defer patch.Unpatch()
+
+    assert.Panics(t, func() { BackupsVerify(context) })
+    assert.True(t, osExitCalled)
+    assert.Contains(t, outBuffer.String(), "1 of 3 backups failed verification")
+
+    _, outBuffer, context = defaultContext()
+    context.Opts = &types.EtcdBackupsOpts{Dir: dir, Archives: []string{valid}}
+    BackupsVerify(context)
+    assert.Contains(t, outBuffer.String(), "1 backups verified")
+}
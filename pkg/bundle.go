@@ -0,0 +1,313 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/ssh"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+const bundleIndexFile = "index.txt"
+
+var bundleOpts = &types.BundleOpts{}
+
+// bundleArtifact is one file of the support bundle together with the command which produced it
+type bundleArtifact struct {
+	path    string
+	command string
+	data    []byte
+	err     error
+}
+
+// Bundle collects diagnostics of all nodes in the selected groups and of the cluster into one archive,
+// laid out as <node>/<artifact> with an index of every artifact and the command which produced it.
+// Artifacts which can not be collected are listed in the index with their error instead of aborting the bundle.
+func Bundle(cmdParams *types.CommandContext) {
+	initParams(cmdParams)
+	bundleOpts = cmdParams.Opts.(*types.BundleOpts)
+	// certificates are read like in the cluster status
+	clusterStatusOpts = &types.ClusterStatusOpts{Sudo: bundleOpts.Sudo}
+
+	output := bundleOpts.Output
+	if output == "" {
+		output = fmt.Sprintf("cluster-%s.tar.gz", time.Now().Format(etcdBackupTimeLayout))
+	}
+
+	groups := bundleGroups()
+	nodes := []types.Node{}
+	for _, g := range groups {
+		for _, n := range util.FindGroupByName(config.ClusterGroups, g).Nodes {
+			if util.IsNodeAddressValid(n) && !util.NodeInArray(nodes, n) {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	if len(nodes) == 0 {
+		printer.PrintCritical("No node in current selection")
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Host < nodes[j].Host
+	})
+
+	printer.PrintHeader(fmt.Sprintf("Collecting support bundle of %d nodes in groups: %s", len(nodes), strings.Join(groups, " ")), '=')
+
+	artifacts := []bundleArtifact{}
+	for _, node := range nodes {
+		printer.PrintNewLine()
+		printer.Print("On node %s:", util.ToNodeLabel(node))
+		artifacts = append(artifacts, collectNodeArtifacts(node, groups)...)
+	}
+
+	printer.PrintNewLine()
+	printer.Print("On cluster:")
+	artifacts = append(artifacts, collectClusterArtifacts()...)
+
+	if err := writeBundle(output, artifacts); err != nil {
+		printer.PrintCritical("Failed to write support bundle %s: %s", output, err)
+	}
+
+	failed := 0
+	for _, artifact := range artifacts {
+		if artifact.err != nil {
+			failed++
+		}
+	}
+
+	printer.PrintNewLine()
+	if failed > 0 {
+		printer.PrintWarn("%d of %d artifacts could not be collected, see %s in the bundle", failed, len(artifacts), bundleIndexFile)
+	}
+	printer.PrintOk("Support bundle written to %s", output)
+}
+
+func bundleGroups() []string {
+	groups := []string{}
+	if bundleOpts.Groups == "" || strings.EqualFold(bundleOpts.Groups, types.ALL_GROUPNAME) {
+		for _, group := range config.ClusterGroups {
+			groups = append(groups, group.Name)
+		}
+	} else {
+		groups = strings.Split(bundleOpts.Groups, ",")
+	}
+
+	return groups
+}
+
+// collectNodeArtifacts collects the system information of node and the logs and certificates configured by those of
+// the selected groups which contain the node
+func collectNodeArtifacts(node types.Node, groups []string) []bundleArtifact {
+	executor := cmdExecutor.ForNode(node)
+	dir := firstNonEmpty(node.Host, node.IP)
+
+	commands := [][]string{
+		{"uptime", "uptime"},
+		{"df", "df -h"},
+		{"dmesg", "dmesg"},
+		{"kernel", "uname -a"},
+		{"os-release", "cat /etc/os-release"},
+		{"kubelet.service", "systemctl cat kubelet"},
+	}
+	if bundleOpts.KubeletConfig != "" {
+		commands = append(commands, []string{"kubelet-config", fmt.Sprintf("cat %s", parseTemplate(bundleOpts.KubeletConfig, node))})
+	}
+
+	services := []string{}
+	containers := []string{}
+	certificates := []types.Certificate{}
+	for _, g := range groups {
+		group := util.FindGroupByName(config.ClusterGroups, g)
+		if !util.NodeInArray(group.Nodes, node) {
+			continue
+		}
+
+		for _, service := range group.Services {
+			if !util.ElementInArray(services, service) {
+				services = append(services, service)
+			}
+		}
+		for _, container := range group.Containers {
+			if !util.ElementInArray(containers, container) {
+				containers = append(containers, container)
+			}
+		}
+		for _, cert := range group.Certificates {
+			cert.Path = parseTemplate(cert.Path, node)
+			cert.Key = parseTemplate(cert.Key, node)
+			cert.CA = parseTemplate(firstNonEmpty(cert.CA, group.CertificateCA), node)
+			cert.Warning = firstNonEmpty(cert.Warning, group.CertificateWarning)
+			cert.Critical = firstNonEmpty(cert.Critical, group.CertificateCritical)
+			certificates = append(certificates, cert)
+		}
+	}
+
+	logOpts := &types.LogsOpts{Tail: bundleOpts.Tail, GenericOpts: types.GenericOpts{Sudo: bundleOpts.Sudo}}
+	for _, service := range services {
+		commands = append(commands, []string{"services/" + service + ".log", serviceLogsCommand(service, logOpts)})
+	}
+
+	artifacts := []bundleArtifact{}
+	for _, command := range commands {
+		artifacts = append(artifacts, collectArtifact(executor, dir+"/"+command[0], command[1]))
+	}
+
+	if len(containers) > 0 {
+		runtime, runtimeErr := containerRuntimeFor(nodeContainerRuntime(node), executor, bundleOpts.Sudo)
+		for _, container := range containers {
+			path := dir + "/containers/" + container + ".log"
+			command, err := "", runtimeErr
+			if err == nil {
+				command, err = runtime.LogsCommand(container, logOpts, executor)
+			}
+			if err != nil {
+				printer.PrintWarn("Error collecting %s: %s", path, err)
+				artifacts = append(artifacts, bundleArtifact{path: path, err: err})
+				continue
+			}
+			// container runtimes print the stderr of the container to stderr
+			artifacts = append(artifacts, collectArtifact(executor, path, command+" 2>&1"))
+		}
+	}
+
+	if len(certificates) > 0 {
+		artifacts = append(artifacts, certificateArtifact(executor, dir+"/certificates", certificates))
+	}
+
+	printer.PrintOk("Collected %d artifacts", len(artifacts))
+	return artifacts
+}
+
+// collectClusterArtifacts dumps the nodes, events and kube-system pods with kubectl on the first accessible master
+func collectClusterArtifacts() []bundleArtifact {
+	group := util.FindGroupByName(config.ClusterGroups, types.MASTER_GROUPNAME)
+	if group.Nodes == nil || len(group.Nodes) == 0 {
+		printer.PrintWarn("No host configured for group [%s], cluster artifacts are skipped", types.MASTER_GROUPNAME)
+		return nil
+	}
+
+	node := ssh.GetFirstAccessibleNode(config.Ssh.LocalOn, cmdExecutor, group.Nodes)
+	if !util.IsNodeAddressValid(node) {
+		printer.PrintWarn("No master available, cluster artifacts are skipped")
+		return nil
+	}
+	executor := cmdExecutor.ForNode(node)
+
+	artifacts := []bundleArtifact{
+		collectArtifact(executor, "cluster/nodes", "kubectl get nodes --output=wide"),
+		collectArtifact(executor, "cluster/events", "kubectl get events --all-namespaces --sort-by=.lastTimestamp"),
+		collectArtifact(executor, "cluster/kube-system-pods", "kubectl get pods --namespace=kube-system --output=wide"),
+	}
+
+	printer.PrintOk("Collected %d artifacts on node %s", len(artifacts), util.ToNodeLabel(node))
+	return artifacts
+}
+
+func collectArtifact(executor types.CommandExecutor, path string, command string) bundleArtifact {
+	artifact := bundleArtifact{path: path, command: command}
+
+	sshOut, err := executor.PerformCmd(command, bundleOpts.Sudo)
+	if sshOut != nil {
+		artifact.data = []byte(sshOut.Stdout + "\n")
+		if sshOut.Stderr != "" {
+			artifact.data = append(artifact.data, []byte(sshOut.Stderr+"\n")...)
+		}
+	}
+	if err != nil {
+		artifact.err = err
+		printer.PrintWarn("Error collecting %s: %s", path, err)
+	}
+
+	return artifact
+}
+
+// certificateArtifact lists the expiry of the certificates, it fails only when none of them could be read
+func certificateArtifact(executor types.CommandExecutor, path string, certificates []types.Certificate) bundleArtifact {
+	artifact := bundleArtifact{path: path}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tNOT AFTER\tCERTIFICATE\tMESSAGE")
+
+	read := 0
+	for _, cert := range certificates {
+		result := checkCertificate(executor, cert)
+		notAfter := "-"
+		if t, ok := result.Values["notAfter"].(time.Time); ok {
+			notAfter = t.Format(time.RFC3339)
+			read++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Status, notAfter, cert.Path, result.Message)
+	}
+	w.Flush()
+
+	artifact.data = buf.Bytes()
+	if read == 0 {
+		artifact.err = fmt.Errorf("none of %d certificates could be read", len(certificates))
+		printer.PrintWarn("Error collecting %s: %s", path, artifact.err)
+	}
+
+	return artifact
+}
+
+// writeBundle writes the index and all artifacts into a gzip compressed tar archive
+func writeBundle(output string, artifacts []bundleArtifact) error {
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	archive := tar.NewWriter(gz)
+	now := time.Now()
+
+	entries := append([]bundleArtifact{{path: bundleIndexFile, data: bundleIndex(artifacts, now)}}, artifacts...)
+	for _, entry := range entries {
+		if entry.data == nil {
+			continue
+		}
+
+		header := &tar.Header{Name: entry.path, Mode: 0644, Size: int64(len(entry.data)), ModTime: now}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(entry.data); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func bundleIndex(artifacts []bundleArtifact, created time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Support bundle created %s\n\n", created.Format(time.RFC3339))
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ARTIFACT\tSIZE\tRESULT\tCOMMAND")
+	for _, artifact := range artifacts {
+		result := "ok"
+		if artifact.err != nil {
+			result = fmt.Sprintf("error: %s", artifact.err)
+		}
+		command := firstNonEmpty(artifact.command, "-")
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", artifact.path, len(artifact.data), result, command)
+	}
+	w.Flush()
+
+	return buf.Bytes()
+}
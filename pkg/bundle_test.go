@@ -0,0 +1,139 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "archive/tar"
+    "compress/gzip"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
+)
+
+func readBundle(t *testing.T, path string) map[string]string {
+    file, err := os.Open(path)
+    assert.Nil(t, err)
+    defer file.Close()
+
+    gz, err := gzip.NewReader(file)
+    assert.Nil(t, err)
+    archive := tar.NewReader(gz)
+
+    entries := map[string]string{}
+    for {
+        header, err := archive.Next()
+        if err == io.EOF {
+            break
+        }
+        assert.Nil(t, err)
+        data, err := ioutil.ReadAll(archive)
+        assert.Nil(t, err)
+        entries[header.Name] = string(data)
+    }
+    return entries
+}
+
+func TestBundle(t *testing.T) {
+    dir, err := ioutil.TempDir("", "bundle")
+    assert.Nil(t, err)
+    defer os.RemoveAll(dir)
+    output := filepath.Join(dir, "cluster.tar.gz")
+
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Config.ClusterGroups[0].Certificates = nil
+    cmdContext.Opts = &types.BundleOpts{
+        Groups: types.MASTER_GROUPNAME,
+        Output: output,
+        Tail: 100,
+        KubeletConfig: "/etc/kubernetes/{{.Host}}/kubelet.yaml",
+    }
+
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        return &types.SSHOutput{Stdout: "host1"}, nil
+    }
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                switch {
+                case command == containerRuntimeDetectCmd:
+                    return &types.SSHOutput{Stdout: "docker"}, nil
+                case command == "dmesg" && node.Host == "host2":
+                    return &types.SSHOutput{Stderr: "dmesg: read kernel buffer failed: Operation not permitted", ExitStatus: 1},
+                        fmt.Errorf("Process exited with status 1")
+                default:
+                    return &types.SSHOutput{Stdout: node.Host + ": " + command}, nil
+                }
+            },
+        }
+    }
+
+    Bundle(cmdContext)
+
+    entries := readBundle(t, output)
+    assert.Equal(t, "host1: uptime\n", entries["host1/uptime"])
+    assert.Equal(t, "host3: cat /etc/kubernetes/host3/kubelet.yaml\n", entries["host3/kubelet-config"])
+    assert.Equal(t, "host2: journalctl --lines=100 --unit=docker\n", entries["host2/services/docker.log"])
+    assert.Equal(t, "host1: docker logs --tail 100 k8s_kube-apiserver 2>&1\n", entries["host1/containers/k8s_kube-apiserver.log"])
+    assert.Contains(t, entries["host2/dmesg"], "Operation not permitted")
+    assert.Contains(t, entries["cluster/events"], "kubectl get events --all-namespaces --sort-by=.lastTimestamp")
+    assert.NotContains(t, entries, "host1/certificates")
+
+    index := strings.Split(entries[bundleIndexFile], "\n")
+    assert.Contains(t, index[2], "ARTIFACT")
+    assert.Len(t, index, len(entries)+3)
+    assert.Contains(t, entries[bundleIndexFile], "host2/dmesg")
+    assert.Contains(t, entries[bundleIndexFile], "error: Process exited with status 1")
+
+    out := outBuffer.String()
+    assert.Contains(t, out, "1 of 36 artifacts could not be collected")
+    assert.Contains(t, out, "Support bundle written to "+output)
+}
+
+func TestBundle_Certificates(t *testing.T) {
+    dir, err := ioutil.TempDir("", "bundle")
+    assert.Nil(t, err)
+    defer os.RemoveAll(dir)
+    output := filepath.Join(dir, "cluster.tar.gz")
+
+    ca, caKey, caPem := createTestCertificate(t, "ca", 365*24*time.Hour, nil, nil)
+    _, _, serverPem := createTestCertificate(t, "apiserver", 10*24*time.Hour, ca, caKey)
+
+    mockExecutor, _, cmdContext := defaultContext()
+    cmdContext.Config.ClusterGroups[0].Nodes = cmdContext.Config.ClusterGroups[0].Nodes[:1]
+    cmdContext.Config.ClusterGroups[0].Certificates = []types.Certificate{
+        {Path: "/certs/{{.Host}}.crt"},
+        {Path: "/certs/missing.crt"},
+    }
+    cmdContext.Opts = &types.BundleOpts{
+        Groups: types.MASTER_GROUPNAME,
+        Output: output,
+    }
+
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        switch command {
+        case "cat /certs/ca.crt":
+            return &types.SSHOutput{Stdout: caPem}, nil
+        case "cat /certs/host1.crt":
+            return &types.SSHOutput{Stdout: serverPem}, nil
+        case "cat /certs/missing.crt":
+            return &types.SSHOutput{}, fmt.Errorf("No such file or directory")
+        }
+        return &types.SSHOutput{}, nil
+    }
+
+    Bundle(cmdContext)
+
+    certificates := strings.Split(readBundle(t, output)["host1/certificates"], "\n")
+    assert.Contains(t, certificates[0], "NOT AFTER")
+    assert.True(t, strings.HasPrefix(certificates[1], types.STATUS_WARN), certificates[1])
+    assert.Contains(t, certificates[1], "/certs/host1.crt")
+    assert.True(t, strings.HasPrefix(certificates[2], types.STATUS_ERROR), certificates[2])
+    assert.Contains(t, certificates[2], "Error reading certificate /certs/missing.crt")
+}
@@ -0,0 +1,220 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+const (
+	defaultCertificateWarning  = "30d"
+	defaultCertificateCritical = "7d"
+)
+
+// checkCertificate reads the certificate from the node and inspects it locally.
+// The key file never leaves the node, only the public key derived from it is compared.
+func checkCertificate(executor types.CommandExecutor, cert types.Certificate) types.CheckResult {
+	result := types.CheckResult{Target: cert.Path}
+
+	sshOut, err := executor.PerformCmd(fmt.Sprintf("cat %s", cert.Path), clusterStatusOpts.Sudo)
+	if err != nil {
+		result.Status = types.STATUS_ERROR
+		result.Message = fmt.Sprintf("Error reading certificate %s: %s", cert.Path, err)
+		return result
+	}
+
+	chain, err := parseCertificates(sshOut.Stdout)
+	if err != nil {
+		result.Status = types.STATUS_ERROR
+		result.Message = fmt.Sprintf("Error parsing certificate %s: %s", cert.Path, err)
+		return result
+	}
+	leaf := chain[0]
+
+	result.Values = map[string]interface{}{
+		"subject":   leaf.Subject.String(),
+		"issuer":    leaf.Issuer.String(),
+		"sans":      subjectAltNames(leaf),
+		"notBefore": leaf.NotBefore,
+		"notAfter":  leaf.NotAfter,
+		"keySize":   publicKeySize(leaf),
+	}
+
+	result.Status, result.Message = certificateExpiryStatus(leaf, cert)
+	problems := []string{}
+
+	if cert.CA != "" {
+		verified, msg := verifyCertificateCA(executor, chain, cert.CA)
+		result.Values["caVerified"] = verified
+		if !verified {
+			result.Status = worseStatus(result.Status, types.STATUS_ERROR)
+			problems = append(problems, msg)
+		}
+	}
+
+	if cert.Key != "" {
+		matches, msg := certificateMatchesKey(executor, leaf, cert.Key)
+		result.Values["keyMatches"] = matches
+		if !matches {
+			result.Status = worseStatus(result.Status, types.STATUS_ERROR)
+			problems = append(problems, msg)
+		}
+	}
+
+	if len(problems) > 0 {
+		result.Message = fmt.Sprintf("%s. %s", result.Message, strings.Join(problems, ". "))
+	}
+
+	return result
+}
+
+func parseCertificates(data string) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	rest := []byte(data)
+
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM encoded certificate found")
+	}
+
+	return certs, nil
+}
+
+func certificateExpiryStatus(leaf *x509.Certificate, cert types.Certificate) (string, string) {
+	now := time.Now()
+	notAfter := leaf.NotAfter.Format(time.RFC1123)
+
+	if now.Before(leaf.NotBefore) {
+		return types.STATUS_ERROR, fmt.Sprintf("Certificate %s is not valid before %s", cert.Path, leaf.NotBefore.Format(time.RFC1123))
+	}
+	if !now.Before(leaf.NotAfter) {
+		return types.STATUS_ERROR, fmt.Sprintf("Certificate %s expired on %s", cert.Path, notAfter)
+	}
+
+	warning, err := util.ParseDuration(firstNonEmpty(cert.Warning, defaultCertificateWarning))
+	if err != nil {
+		return types.STATUS_UNKNOWN, fmt.Sprintf("Invalid warning threshold for certificate %s: %s", cert.Path, err)
+	}
+	critical, err := util.ParseDuration(firstNonEmpty(cert.Critical, defaultCertificateCritical))
+	if err != nil {
+		return types.STATUS_UNKNOWN, fmt.Sprintf("Invalid critical threshold for certificate %s: %s", cert.Path, err)
+	}
+
+	left := leaf.NotAfter.Sub(now)
+	msg := fmt.Sprintf("Certificate %s is valid until %s (%d days left)", cert.Path, notAfter, int(left.Hours()/24))
+
+	if left < critical {
+		return types.STATUS_ERROR, msg
+	} else if left < warning {
+		return types.STATUS_WARN, msg
+	}
+	return types.STATUS_OK, msg
+}
+
+func verifyCertificateCA(executor types.CommandExecutor, chain []*x509.Certificate, caPath string) (bool, string) {
+	sshOut, err := executor.PerformCmd(fmt.Sprintf("cat %s", caPath), clusterStatusOpts.Sudo)
+	if err != nil {
+		return false, fmt.Sprintf("Error reading CA %s: %s", caPath, err)
+	}
+
+	cas, err := parseCertificates(sshOut.Stdout)
+	if err != nil {
+		return false, fmt.Sprintf("Error parsing CA %s: %s", caPath, err)
+	}
+
+	roots := x509.NewCertPool()
+	for _, ca := range cas {
+		roots.AddCert(ca)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+
+	leaf := chain[0]
+	// Expiration is reported separately, only the signature chain is of interest here
+	verifyTime := time.Now()
+	if verifyTime.Before(leaf.NotBefore) || verifyTime.After(leaf.NotAfter) {
+		verifyTime = leaf.NotBefore
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   verifyTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return false, fmt.Sprintf("Not signed by CA %s: %s", caPath, err)
+	}
+
+	return true, ""
+}
+
+func certificateMatchesKey(executor types.CommandExecutor, leaf *x509.Certificate, keyPath string) (bool, string) {
+	sshOut, err := executor.PerformCmd(fmt.Sprintf("openssl pkey -pubout -in %s", keyPath), clusterStatusOpts.Sudo)
+	if err != nil {
+		return false, fmt.Sprintf("Error reading key %s: %s", keyPath, err)
+	}
+
+	block, _ := pem.Decode([]byte(sshOut.Stdout))
+	if block == nil {
+		return false, fmt.Sprintf("Error parsing public key of %s", keyPath)
+	}
+
+	certPub, err := x509.MarshalPKIXPublicKey(leaf.PublicKey)
+	if err != nil {
+		return false, fmt.Sprintf("Error encoding public key of certificate: %s", err)
+	}
+
+	if !bytes.Equal(certPub, block.Bytes) {
+		return false, fmt.Sprintf("Key %s does not match the certificate", keyPath)
+	}
+
+	return true, ""
+}
+
+func subjectAltNames(cert *x509.Certificate) []string {
+	sans := []string{}
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	return sans
+}
+
+func publicKeySize(cert *x509.Certificate) int {
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return pub.N.BitLen()
+	case *ecdsa.PublicKey:
+		return pub.Curve.Params().BitSize
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+var compareRegex = regexp.MustCompile(`^\s*(<=|>=|==|!=|<|>)\s*(-?[0-9.]+)\s*$`)
+
+func checkCustom(group string, custom types.CustomCheck, nodes []types.Node) *types.CheckReport {
+	check := &types.CheckReport{Name: custom.Name}
+	printer.PrintHeader(fmt.Sprintf("Checking %s in group [%s]", custom.Name, group), '-')
+	if nodes == nil || len(nodes) == 0 {
+		skipCheck(check, "No host configured for [%s]", group)
+		return check
+	}
+	if custom.Command == "" {
+		skipCheck(check, "No command configured for custom check %s", custom.Name)
+		return check
+	}
+
+	for _, node := range nodes {
+		if !util.IsNodeAddressValid(node) {
+			printer.PrintErr("Current node %q has no valid address", node)
+			break
+		}
+
+		printer.PrintNewLine()
+		printer.Print("On node %s:", util.ToNodeLabel(node))
+		executor := cmdExecutor.ForNode(node)
+		nodeReport := newNodeCheckReport(check, node)
+
+		addResult(nodeReport, runCustomCheck(executor, custom, node))
+	}
+
+	return check
+}
+
+func runCustomCheck(executor types.CommandExecutor, custom types.CustomCheck, node types.Node) types.CheckResult {
+	result := types.CheckResult{Target: custom.Name}
+	severity, err := customCheckSeverity(custom.Severity)
+	if err != nil {
+		result.Status = types.STATUS_UNKNOWN
+		result.Message = fmt.Sprintf("Invalid configuration of %s: %s", custom.Name, err)
+		return result
+	}
+	command := parseTemplate(custom.Command, node)
+
+	sshOut, err := executor.PerformCmd(command, custom.Sudo || clusterStatusOpts.Sudo)
+	// A non-zero exit code is reported as error together with the exit status, everything else means the command did not run
+	if err != nil && sshOut.ExitStatus == 0 {
+		result.Status = types.STATUS_ERROR
+		result.Message = fmt.Sprintf("Error running %s: %s", custom.Name, err)
+		return result
+	}
+
+	result.Values = map[string]interface{}{"exitCode": sshOut.ExitStatus, "stdout": sshOut.Stdout}
+	failures := []string{}
+
+	if exitCode, ok := customCheckExitCode(custom); ok && sshOut.ExitStatus != exitCode {
+		failures = append(failures, fmt.Sprintf("exit code %d, expected %d", sshOut.ExitStatus, exitCode))
+	}
+
+	if custom.Stdout != "" {
+		stdoutRegex, err := regexp.Compile(custom.Stdout)
+		if err != nil {
+			result.Status = types.STATUS_UNKNOWN
+			result.Message = fmt.Sprintf("Invalid stdout pattern of %s: %s", custom.Name, err)
+			return result
+		}
+
+		if !stdoutRegex.MatchString(sshOut.Stdout) {
+			failures = append(failures, fmt.Sprintf("output '%s' does not match '%s'", sshOut.Stdout, custom.Stdout))
+		}
+	}
+
+	if custom.Compare != "" {
+		ok, err := compareNumber(sshOut.Stdout, custom.Compare)
+		if err != nil {
+			result.Status = types.STATUS_UNKNOWN
+			result.Message = fmt.Sprintf("Could not compare output of %s: %s", custom.Name, err)
+			return result
+		}
+
+		if !ok {
+			failures = append(failures, fmt.Sprintf("output %s is not %s", sshOut.Stdout, strings.TrimSpace(custom.Compare)))
+		}
+	}
+
+	if len(failures) > 0 {
+		result.Status = severity
+		result.Message = fmt.Sprintf("Check %s failed: %s", custom.Name, strings.Join(failures, ", "))
+	} else {
+		result.Status = types.STATUS_OK
+		result.Message = fmt.Sprintf("Check %s passed", custom.Name)
+	}
+
+	return result
+}
+
+// compareNumber evaluates expression like "> 100" with value as left operand
+func compareNumber(value string, expression string) (bool, error) {
+	match := compareRegex.FindStringSubmatch(expression)
+	if match == nil {
+		return false, fmt.Errorf("invalid comparison '%s'", expression)
+	}
+
+	expected, err := strconv.ParseFloat(match[2], 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid comparison '%s'", expression)
+	}
+
+	actual, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return false, fmt.Errorf("output '%s' is not a number", value)
+	}
+
+	switch match[1] {
+	case "<":
+		return actual < expected, nil
+	case "<=":
+		return actual <= expected, nil
+	case ">":
+		return actual > expected, nil
+	case ">=":
+		return actual >= expected, nil
+	case "==":
+		return actual == expected, nil
+	default:
+		return actual != expected, nil
+	}
+}
+
+// customCheckExitCode returns the expected exit code. Without an ExitCode only checks of nothing else expect success.
+func customCheckExitCode(custom types.CustomCheck) (int, bool) {
+	if custom.ExitCode != nil {
+		return *custom.ExitCode, true
+	}
+	return 0, custom.Stdout == "" && custom.Compare == ""
+}
+
+func customCheckSeverity(severity string) (string, error) {
+	switch strings.ToUpper(severity) {
+	case types.STATUS_WARN, "WARNING":
+		return types.STATUS_WARN, nil
+	case types.STATUS_ERROR, "":
+		return types.STATUS_ERROR, nil
+	default:
+		return "", fmt.Errorf("unknown severity %s, valid values: %s, %s", severity, types.STATUS_WARN, types.STATUS_ERROR)
+	}
+}
@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"gopkg.in/yaml.v2"
+)
+
+// Exit codes of cluster-status following the Nagios plugin convention
+const (
+	EXIT_OK       = 0
+	EXIT_WARN     = 1
+	EXIT_CRITICAL = 2
+	EXIT_UNKNOWN  = 3
+)
+
+// reportOut receives the machine-readable cluster status report
+var reportOut io.Writer = os.Stdout
+
+func newNodeCheckReport(check *types.CheckReport, node types.Node) *types.NodeCheckReport {
+	nodeReport := &types.NodeCheckReport{Node: node.Host, IP: node.IP, Results: []types.CheckResult{}}
+	check.Nodes = append(check.Nodes, nodeReport)
+	return nodeReport
+}
+
+func skipCheck(check *types.CheckReport, msg string, a ...interface{}) {
+	check.Status = types.STATUS_SKIPPED
+	check.Message = fmt.Sprintf(msg, a...)
+	printer.PrintSkipped("%s", check.Message)
+}
+
+// addResult records result for the node and prints it in human readable form
+func addResult(nodeReport *types.NodeCheckReport, result types.CheckResult) {
+	nodeReport.Results = append(nodeReport.Results, result)
+	printStatus(result.Status, result.Message)
+}
+
+func printStatus(status string, msg string) {
+	switch status {
+	case types.STATUS_OK:
+		printer.PrintOk("%s", msg)
+	case types.STATUS_WARN:
+		printer.PrintWarn("%s", msg)
+	case types.STATUS_ERROR:
+		printer.PrintErr("%s", msg)
+	case types.STATUS_IGNORED:
+		printer.PrintIgnored("%s", msg)
+	case types.STATUS_SKIPPED:
+		printer.PrintSkipped("%s", msg)
+	default:
+		printer.PrintUnknown("%s", msg)
+	}
+}
+
+// statusSeverity orders the statuses from least to most severe. Results, checks and the overall verdict are all
+// ranked by it, a warning outweighs an unknown result like in the exit codes of cluster-status.
+var statusSeverity = map[string]int{
+	types.STATUS_SKIPPED: 0,
+	types.STATUS_IGNORED: 1,
+	types.STATUS_OK:      2,
+	types.STATUS_UNKNOWN: 3,
+	types.STATUS_WARN:    4,
+	types.STATUS_ERROR:   5,
+}
+
+// worseStatus returns the more severe of both statuses
+func worseStatus(a string, b string) string {
+	if statusSeverity[b] > statusSeverity[a] {
+		return b
+	}
+	return a
+}
+
+func countStatus(summary *types.StatusSummary, status string) {
+	switch status {
+	case types.STATUS_OK:
+		summary.Ok++
+	case types.STATUS_WARN:
+		summary.Warn++
+	case types.STATUS_ERROR:
+		summary.Error++
+	case types.STATUS_IGNORED:
+		summary.Ignored++
+	case types.STATUS_SKIPPED:
+		summary.Skipped++
+	default:
+		summary.Unknown++
+	}
+}
+
+// verdict returns the worst status of summary. Ignored and skipped results do not affect it.
+func verdict(summary types.StatusSummary) string {
+	status := types.STATUS_OK
+	for s, count := range map[string]int{
+		types.STATUS_ERROR:   summary.Error,
+		types.STATUS_WARN:    summary.Warn,
+		types.STATUS_UNKNOWN: summary.Unknown,
+	} {
+		if count > 0 {
+			status = worseStatus(status, s)
+		}
+	}
+	return status
+}
+
+func exitCode(status string) int {
+	switch status {
+	case types.STATUS_OK:
+		return EXIT_OK
+	case types.STATUS_WARN:
+		return EXIT_WARN
+	case types.STATUS_ERROR:
+		return EXIT_CRITICAL
+	default:
+		return EXIT_UNKNOWN
+	}
+}
+
+func summarizeCheck(check *types.CheckReport) types.StatusSummary {
+	summary := types.StatusSummary{}
+	if check.Status != "" {
+		countStatus(&summary, check.Status)
+	}
+	for _, node := range check.Nodes {
+		for _, result := range node.Results {
+			countStatus(&summary, result.Status)
+		}
+	}
+	summary.Status = verdict(summary)
+	return summary
+}
+
+// summarizeReport counts all results of report. A group without nodes counts as unknown.
+func summarizeReport(report *types.ClusterStatusReport) {
+	total := types.StatusSummary{}
+	for _, group := range report.Groups {
+		if group.Error != "" {
+			total.Unknown++
+		}
+		for _, check := range group.Checks {
+			summary := summarizeCheck(check)
+			total.Ok += summary.Ok
+			total.Warn += summary.Warn
+			total.Error += summary.Error
+			total.Unknown += summary.Unknown
+			total.Ignored += summary.Ignored
+			total.Skipped += summary.Skipped
+		}
+	}
+	total.Status = verdict(total)
+	report.Summary = total
+}
+
+func printSummary(report *types.ClusterStatusReport) {
+	printer.PrintHeader("Summary", '=')
+
+	rows := [][]string{}
+	for _, group := range report.Groups {
+		if group.Error != "" {
+			rows = append(rows, []string{group.Name, "-", "0", "0", "0", "1", "0", "0", types.STATUS_UNKNOWN})
+		}
+		for _, check := range group.Checks {
+			s := summarizeCheck(check)
+			rows = append(rows, []string{group.Name, check.Name, strconv.Itoa(s.Ok), strconv.Itoa(s.Warn), strconv.Itoa(s.Error),
+				strconv.Itoa(s.Unknown), strconv.Itoa(s.Ignored), strconv.Itoa(s.Skipped), s.Status})
+		}
+	}
+	printTable([]string{"GROUP", "CHECK", "OK", "WARN", "ERROR", "UNKNOWN", "IGNORED", "SKIPPED", "STATUS"}, rows, nil)
+	printer.PrintNewLine()
+
+	s := report.Summary
+	printStatus(s.Status, fmt.Sprintf("Cluster status %s: %d ok, %d warnings, %d errors, %d unknown",
+		s.Status, s.Ok, s.Warn, s.Error, s.Unknown))
+}
+
+func writeReport(report *types.ClusterStatusReport, format string) error {
+	var data []byte
+	var err error
+
+	switch format {
+	case types.OUTPUT_JSON:
+		data, err = json.MarshalIndent(report, "", "  ")
+	case types.OUTPUT_YAML:
+		data, err = yaml.Marshal(report)
+	default:
+		err = fmt.Errorf("Unknown output format %s", format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(reportOut, string(data))
+	return err
+}
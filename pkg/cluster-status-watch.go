@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/integration"
+	"github.com/mrahbar/kubernetes-inspector/types"
+)
+
+// statusChange describes how a single check result differs between two runs.
+// Old or New is nil when the result only exists in one of them.
+type statusChange struct {
+	Check string
+	Node  string
+	Old   *types.CheckResult
+	New   *types.CheckResult
+}
+
+// watchClusterStatus prints the full status once and afterwards only the changes of every run. It does not return.
+func watchClusterStatus(groups []string, interval time.Duration) {
+	previous := runClusterStatus(groups)
+	printSummary(previous)
+
+	for {
+		time.Sleep(interval)
+
+		out := printer
+		// Only changes are printed, the output of the run itself is dropped
+		printer = integration.NewDiscardLogWriter(out)
+		current := runClusterStatus(groups)
+		printer = out
+
+		printChanges(previous, current)
+		previous = current
+	}
+}
+
+func printChanges(previous *types.ClusterStatusReport, current *types.ClusterStatusReport) {
+	changes := diffReports(previous, current)
+	verdictChanged := previous.Summary.Status != current.Summary.Status
+
+	if len(changes) == 0 && !verdictChanged {
+		printer.PrintDebug("No changes at %s", current.Time.Format("15:04:05"))
+		return
+	}
+
+	printer.PrintHeader(fmt.Sprintf("Changes at %s", current.Time.Format("15:04:05")), '-')
+	for _, change := range changes {
+		if change.New == nil {
+			printer.PrintInfo(describeChange(change))
+		} else {
+			printStatus(change.New.Status, describeChange(change))
+		}
+	}
+
+	if verdictChanged {
+		printStatus(current.Summary.Status, fmt.Sprintf("Cluster status went %s -> %s",
+			previous.Summary.Status, current.Summary.Status))
+	}
+}
+
+// diffReports returns the results whose status or state differ, in order of the current report followed by removed results
+func diffReports(previous *types.ClusterStatusReport, current *types.ClusterStatusReport) []statusChange {
+	type entry struct {
+		check  string
+		node   string
+		result types.CheckResult
+	}
+
+	index := func(report *types.ClusterStatusReport) ([]string, map[string]entry) {
+		keys := []string{}
+		entries := map[string]entry{}
+		for _, group := range report.Groups {
+			for _, check := range group.Checks {
+				for _, node := range check.Nodes {
+					for _, result := range node.Results {
+						key := fmt.Sprintf("%s/%s/%s/%s", group.Name, check.Name, node.Node, result.Target)
+						if _, ok := entries[key]; !ok {
+							keys = append(keys, key)
+						}
+						entries[key] = entry{check: check.Name, node: node.Node, result: result}
+					}
+				}
+			}
+		}
+		return keys, entries
+	}
+
+	previousKeys, previousEntries := index(previous)
+	currentKeys, currentEntries := index(current)
+	changes := []statusChange{}
+
+	for _, key := range currentKeys {
+		c := currentEntries[key]
+		p, ok := previousEntries[key]
+		if !ok {
+			changes = append(changes, statusChange{Check: c.check, Node: c.node, New: &c.result})
+			continue
+		}
+
+		if p.result.Status != c.result.Status || resultState(p.result) != resultState(c.result) {
+			changes = append(changes, statusChange{Check: c.check, Node: c.node, Old: &p.result, New: &c.result})
+		}
+	}
+
+	for _, key := range previousKeys {
+		if _, ok := currentEntries[key]; !ok {
+			p := previousEntries[key]
+			changes = append(changes, statusChange{Check: p.check, Node: p.node, Old: &p.result})
+		}
+	}
+
+	return changes
+}
+
+func describeChange(change statusChange) string {
+	switch {
+	case change.Old == nil:
+		return fmt.Sprintf("%s %s on %s appeared as %s: %s", change.Check, change.New.Target, change.Node,
+			change.New.Status, change.New.Message)
+	case change.New == nil:
+		return fmt.Sprintf("%s %s on %s is no longer reported", change.Check, change.Old.Target, change.Node)
+	}
+
+	oldState, newState := resultState(*change.Old), resultState(*change.New)
+	if oldState != "" && newState != "" && oldState != newState {
+		return fmt.Sprintf("%s on %s went %s -> %s", change.New.Target, change.Node, oldState, newState)
+	}
+
+	return fmt.Sprintf("%s %s on %s went %s -> %s: %s", change.Check, change.New.Target, change.Node,
+		change.Old.Status, change.New.Status, change.New.Message)
+}
+
+// resultState returns the state reported by services and containers, e.g. active or running
+func resultState(result types.CheckResult) string {
+	if state, ok := result.Values["state"].(string); ok {
+		return state
+	}
+	return ""
+}
@@ -3,6 +3,7 @@ package pkg
 import (
     "bytes"
     "fmt"
+    "github.com/mrahbar/kubernetes-inspector/integration"
     "github.com/mrahbar/kubernetes-inspector/ssh"
     "github.com/mrahbar/kubernetes-inspector/types"
     "github.com/mrahbar/kubernetes-inspector/util"
@@ -10,6 +11,7 @@ import (
     "sort"
     "strconv"
     "strings"
+    "os"
     "text/template"
     "time"
 )
@@ -17,15 +19,63 @@ import (
 const (
     leftTemplateDelim  = "{{"
     rightTemplateDelim = "}}"
+
+    // Default usage thresholds in percent for file systems and inodes
+    defaultDiskWarning  = 65
+    defaultDiskCritical = 85
 )
 
+var spacesRegex = regexp.MustCompile("\\s+")
+
 var clusterStatusChecks = []string{types.SERVICES_CHECKNAME, types.CONTAINERS_CHECKNAME, types.CERTIFICATES_CHECKNAME, types.DISKUSAGE_CHECKNAME, types.KUBERNETES_CHECKNAME}
 var clusterStatusOpts = &types.ClusterStatusOpts{}
 
-func ClusterStatus(cmdParams *types.CommandContext) {
+// ClusterStatus runs the configured checks and returns a Nagios compatible exit code for the overall verdict
+func ClusterStatus(cmdParams *types.CommandContext) int {
     initParams(cmdParams)
     clusterStatusOpts = cmdParams.Opts.(*types.ClusterStatusOpts)
 
+    // invalid invocations exit with UNKNOWN like any other check which could not be performed
+    if clusterStatusOpts.Watch > 0 && clusterStatusOpts.Output != "" {
+        printer.PrintErr("Output format can not be combined with watch mode")
+        return EXIT_UNKNOWN
+    }
+    if clusterStatusOpts.Output != "" {
+        if clusterStatusOpts.Output != types.OUTPUT_JSON && clusterStatusOpts.Output != types.OUTPUT_YAML {
+            printer.PrintErr("Unknown output format %s. Valid formats: %s, %s", clusterStatusOpts.Output, types.OUTPUT_JSON, types.OUTPUT_YAML)
+            return EXIT_UNKNOWN
+        }
+        // stdout is reserved for the report
+        integration.SetOutput(os.Stderr)
+    }
+
+    groups, err := clusterStatusGroups()
+    if err != nil {
+        printer.PrintErr("%s", err)
+        return EXIT_UNKNOWN
+    }
+
+    if clusterStatusOpts.Watch > 0 {
+        // watch mode only ends once the process is interrupted
+        watchClusterStatus(groups, clusterStatusOpts.Watch)
+        return EXIT_OK
+    }
+
+    report := runClusterStatus(groups)
+    printSummary(report)
+
+    if clusterStatusOpts.Output != "" {
+        if err := writeReport(report, clusterStatusOpts.Output); err != nil {
+            printer.PrintErr("Failed to write report: %s", err)
+            return EXIT_UNKNOWN
+        }
+    }
+
+    return exitCode(report.Summary.Status)
+}
+
+// clusterStatusGroups returns the selected groups and fails for groups which are not configured
+func clusterStatusGroups() ([]string, error) {
     var groups = []string{}
     if strings.EqualFold(clusterStatusOpts.Groups, types.ALL_GROUPNAME) {
         for _, group := range config.ClusterGroups {
@@ -34,15 +84,28 @@ func ClusterStatus(cmdParams *types.CommandContext) {
     } else if clusterStatusOpts.Groups != "" {
         groups = strings.Split(clusterStatusOpts.Groups, ",")
     } else {
-        printer.PrintCritical("No group specified")
+        return nil, fmt.Errorf("no group specified")
     }
 
+    for _, name := range groups {
+        if util.FindGroupByName(config.ClusterGroups, name).Name == "" {
+            return nil, fmt.Errorf("unknown group %s", name)
+        }
+    }
+    return groups, nil
+}
+
+// runClusterStatus performs all selected checks on groups and returns the summarized report
+func runClusterStatus(groups []string) *types.ClusterStatusReport {
+    report := &types.ClusterStatusReport{Time: time.Now()}
+
+    checks := clusterStatusChecks
     if clusterStatusOpts.Checks != "" {
-        clusterStatusChecks = strings.Split(clusterStatusOpts.Checks, ",")
+        checks = strings.Split(clusterStatusOpts.Checks, ",")
     }
 
     printer.Print("Performing status checks %s for groups: %v",
-        strings.Join(clusterStatusChecks, ","), strings.Join(groups, " "))
+        strings.Join(checks, ","), strings.Join(groups, " "))
 
     totalNodes := []types.Node{}
     for _, element := range groups {
@@ -62,49 +125,67 @@ func ClusterStatus(cmdParams *types.CommandContext) {
         printer.PrintHeader(fmt.Sprintf("Retrieving node stats"), '=')
         printer.PrintNewLine()
         for _, node := range totalNodes {
-            getNodesStats(node)
+            report.NodeStats = append(report.NodeStats, getNodesStats(node))
         }
     }
 
     for _, g := range groups {
         group := util.FindGroupByName(config.ClusterGroups, g)
+        groupReport := &types.GroupReport{Name: g}
+        report.Groups = append(report.Groups, groupReport)
+
         printer.PrintHeader(fmt.Sprintf("Group %s", g), '=')
         if group.Nodes != nil {
-            if util.ElementInArray(clusterStatusChecks, types.SERVICES_CHECKNAME) {
-                checkServiceStatus(g, group.Services, group.Nodes)
+            if util.ElementInArray(checks, types.SERVICES_CHECKNAME) {
+                groupReport.Checks = append(groupReport.Checks, checkServiceStatus(g, group.Services, group.Nodes))
+            }
+
+            if util.ElementInArray(checks, types.CONTAINERS_CHECKNAME) {
+                groupReport.Checks = append(groupReport.Checks, checkContainerStatus(g, group.ContainerRuntime, group.Containers, group.Nodes))
             }
 
-            if util.ElementInArray(clusterStatusChecks, types.CONTAINERS_CHECKNAME) {
-                checkContainerStatus(g, group.Containers, group.Nodes)
+            if util.ElementInArray(checks, types.CERTIFICATES_CHECKNAME) {
+                certDefaults := types.Certificate{CA: group.CertificateCA, Warning: group.CertificateWarning, Critical: group.CertificateCritical}
+                groupReport.Checks = append(groupReport.Checks, checkCertificatesExpiration(g, group.Certificates, certDefaults, group.Nodes))
             }
 
-            if util.ElementInArray(clusterStatusChecks, types.CERTIFICATES_CHECKNAME) {
-                checkCertificatesExpiration(g, group.Certificates, group.Nodes)
+            if util.ElementInArray(checks, types.DISKUSAGE_CHECKNAME) {
+                groupReport.Checks = append(groupReport.Checks, checkDiskStatus(g, group.DiskUsage, group.Nodes))
             }
 
-            if util.ElementInArray(clusterStatusChecks, types.DISKUSAGE_CHECKNAME) {
-                checkDiskStatus(g, group.DiskUsage, group.Nodes)
+            if util.ElementInArray(checks, types.KUBERNETES_CHECKNAME) {
+                groupReport.Checks = append(groupReport.Checks, checkKubernetesStatus(g, group.Kubernetes, group.Nodes))
             }
 
-            if util.ElementInArray(clusterStatusChecks, types.KUBERNETES_CHECKNAME) {
-                checkKubernetesStatus(g, group.Kubernetes, group.Nodes)
+            // Custom checks run by default and can be selected by name like the built-in ones
+            for _, custom := range group.CustomChecks {
+                if clusterStatusOpts.Checks == "" || util.ElementInArray(checks, custom.Name) {
+                    groupReport.Checks = append(groupReport.Checks, checkCustom(g, custom, group.Nodes))
+                }
             }
         } else {
-            printer.PrintErr("No Nodes found for group: %s", g)
+            groupReport.Error = fmt.Sprintf("No Nodes found for group: %s", g)
+            printer.PrintErr(groupReport.Error)
         }
     }
+
+    summarizeReport(report)
+    return report
 }
 
-func getNodesStats(node types.Node) {
+func getNodesStats(node types.Node) *types.NodeStats {
+    stats := &types.NodeStats{Node: node.Host, IP: node.IP}
     if !util.IsNodeAddressValid(node) {
-        printer.PrintErr("Current node %q has no valid address", node)
-        return
+        stats.Error = fmt.Sprintf("Current node %q has no valid address", node)
+        printer.PrintErr(stats.Error)
+        return stats
     }
     printer.Print("On node %s:", util.ToNodeLabel(node))
-    cmdExecutor.SetNode(node)
+    executor := cmdExecutor.ForNode(node)
 
-    sshOut, err := cmdExecutor.PerformCmd("/bin/cat /proc/uptime", false)
+    sshOut, err := executor.PerformCmd("/bin/cat /proc/uptime", false)
     if err != nil {
+        stats.Error = fmt.Sprintf("Could not get uptime: %s", err)
         printer.PrintWarn("Could not get uptime for: %s", err)
     } else {
         parts := strings.Fields(sshOut.Stdout)
@@ -114,6 +195,7 @@ func getNodesStats(node types.Node) {
             if err != nil {
                 printer.PrintWarn("Could not parse uptime: %s", err)
             } else {
+                stats.UptimeSeconds = upsecs
                 dur := time.Duration(upsecs * 1e9)
                 dur = dur - (dur % time.Second)
                 var days int
@@ -137,12 +219,16 @@ func getNodesStats(node types.Node) {
         }
     }
 
-    sshOut, err = cmdExecutor.PerformCmd("/bin/cat /proc/loadavg", false)
+    sshOut, err = executor.PerformCmd("/bin/cat /proc/loadavg", false)
     if err != nil {
+        stats.Error = fmt.Sprintf("Could not get load statistics: %s", err)
         printer.PrintWarn("Could not get load statistics: %s", err)
     } else {
         parts := strings.Fields(sshOut.Stdout)
         if len(parts) == 5 {
+            stats.Load1, _ = strconv.ParseFloat(parts[0], 64)
+            stats.Load5, _ = strconv.ParseFloat(parts[1], 64)
+            stats.Load15, _ = strconv.ParseFloat(parts[2], 64)
             loadMsg := fmt.Sprintf("Load periods 1m: %s - 5m: %s - 10m: %s\n", parts[0], parts[1], parts[2])
 
             if i := strings.Index(parts[3], "/"); i != -1 {
@@ -151,23 +237,27 @@ func getNodesStats(node types.Node) {
                     totalProcs = parts[3][i+1:]
                 }
                 loadMsg = fmt.Sprintf("%sNumber of total processes %s", loadMsg, totalProcs)
+                stats.Processes, _ = strconv.Atoi(totalProcs)
 
                 printer.PrintOk(strings.TrimSpace(loadMsg))
             }
         }
     }
     printer.PrintNewLine()
+
+    return stats
 }
 
-func checkServiceStatus(group string, services []string, nodes []types.Node) {
+func checkServiceStatus(group string, services []string, nodes []types.Node) *types.CheckReport {
+    check := &types.CheckReport{Name: types.SERVICES_CHECKNAME}
     printer.PrintHeader(fmt.Sprintf("Checking service status in group [%s]", group), '-')
     if nodes == nil || len(nodes) == 0 {
-        printer.PrintSkipped("No host configured for [%s]", group)
-        return
+        skipCheck(check, "No host configured for [%s]", group)
+        return check
     }
     if services == nil || len(services) == 0 {
-        printer.PrintSkipped("No services configured for [%s]", group)
-        return
+        skipCheck(check, "No services configured for [%s]", group)
+        return check
     }
 
     for _, node := range nodes {
@@ -178,38 +268,50 @@ func checkServiceStatus(group string, services []string, nodes []types.Node) {
 
         printer.PrintNewLine()
         printer.Print("On node %s:", util.ToNodeLabel(node))
-        cmdExecutor.SetNode(node)
+        executor := cmdExecutor.ForNode(node)
+        nodeReport := newNodeCheckReport(check, node)
 
         for _, service := range services {
-            sshOut, err := cmdExecutor.PerformCmd(fmt.Sprintf("systemctl is-active %s", service), clusterStatusOpts.Sudo)
+            sshOut, err := executor.PerformCmd(fmt.Sprintf("systemctl is-active %s", service), clusterStatusOpts.Sudo)
+            result := types.CheckResult{Target: service}
 
             if err != nil {
-                printer.PrintErr("Error checking status of %s: %s", service, err)
+                result.Status = types.STATUS_ERROR
+                result.Message = fmt.Sprintf("Error checking status of %s: %s", service, err)
             } else {
-                result := sshOut.Stdout
-                if result == "active" {
-                    printer.PrintOk("Service %s is active", service)
-                } else if result == "activating" || result == "inactive" {
-                    printer.PrintWarn("Service %s is %s", service, result)
-                } else if result == "failed" {
-                    printer.PrintErr("Service %s is failed", service)
+                state := sshOut.Stdout
+                result.Values = map[string]interface{}{"state": state}
+                if state == "active" {
+                    result.Status = types.STATUS_OK
+                    result.Message = fmt.Sprintf("Service %s is active", service)
+                } else if state == "activating" || state == "inactive" {
+                    result.Status = types.STATUS_WARN
+                    result.Message = fmt.Sprintf("Service %s is %s", service, state)
+                } else if state == "failed" {
+                    result.Status = types.STATUS_ERROR
+                    result.Message = fmt.Sprintf("Service %s is failed", service)
                 } else {
-                    printer.PrintUnknown("Service %s is unknown state: %s", service, result)
+                    result.Status = types.STATUS_UNKNOWN
+                    result.Message = fmt.Sprintf("Service %s is unknown state: %s", service, state)
                 }
             }
+            addResult(nodeReport, result)
         }
     }
+
+    return check
 }
 
-func checkContainerStatus(group string, containers []string, nodes []types.Node) {
+func checkContainerStatus(group string, runtimeName string, containers []string, nodes []types.Node) *types.CheckReport {
+    check := &types.CheckReport{Name: types.CONTAINERS_CHECKNAME}
     printer.PrintHeader(fmt.Sprintf("Checking container status in group [%s]", group), '-')
     if nodes == nil || len(nodes) == 0 {
-        printer.PrintSkipped("No host configured for [%s]", group)
-        return
+        skipCheck(check, "No host configured for [%s]", group)
+        return check
     }
     if containers == nil || len(containers) == 0 {
-        printer.PrintSkipped("No containers configured for [%s]", group)
-        return
+        skipCheck(check, "No containers configured for [%s]", group)
+        return check
     }
 
     for _, node := range nodes {
@@ -220,40 +322,59 @@ func checkContainerStatus(group string, containers []string, nodes []types.Node)
 
         printer.PrintNewLine()
         printer.Print("On node %s:", util.ToNodeLabel(node))
-        cmdExecutor.SetNode(node)
+        executor := cmdExecutor.ForNode(node)
+        nodeReport := newNodeCheckReport(check, node)
 
-        for _, container := range containers {
-            cmd := fmt.Sprintf("docker ps -a -q --latest -f name=%s* | xargs --no-run-if-empty docker inspect -f '{{.State.Status}}'", container)
+        runtime, err := containerRuntimeFor(runtimeName, executor, clusterStatusOpts.Sudo)
+        if err != nil {
+            for _, container := range containers {
+                addResult(nodeReport, types.CheckResult{Target: container, Status: types.STATUS_ERROR,
+                    Message: fmt.Sprintf("Error checking status of %s: %s", container, err)})
+            }
+            continue
+        }
 
-            sshOut, err := cmdExecutor.PerformCmd(cmd, clusterStatusOpts.Sudo)
+        for _, container := range containers {
+            sshOut, err := executor.PerformCmd(runtime.StateCommand(container), clusterStatusOpts.Sudo)
+            result := types.CheckResult{Target: container}
 
             if err != nil {
-                printer.PrintErr("Error checking status of %s: %s", container, err)
+                result.Status = types.STATUS_ERROR
+                result.Message = fmt.Sprintf("Error checking status of %s: %s", container, err)
             } else {
-                result := sshOut.Stdout
-                if result == "running" {
-                    printer.PrintOk("Container %s is running", container)
-                } else if result == "created" || result == "paused" || result == "restarting" {
-                    printer.PrintWarn("Container %s is %s", container, result)
-                } else if result == "exited" || result == "removing" || result == "dead" {
-                    printer.PrintErr("Container %s is %s", container, result)
+                state := runtime.ParseState(sshOut.Stdout)
+                result.Values = map[string]interface{}{"state": state, "runtime": runtime.Name()}
+                if state == "running" {
+                    result.Status = types.STATUS_OK
+                    result.Message = fmt.Sprintf("Container %s is running", container)
+                } else if state == "created" || state == "paused" || state == "restarting" {
+                    result.Status = types.STATUS_WARN
+                    result.Message = fmt.Sprintf("Container %s is %s", container, state)
+                } else if state == "exited" || state == "removing" || state == "dead" {
+                    result.Status = types.STATUS_ERROR
+                    result.Message = fmt.Sprintf("Container %s is %s", container, state)
                 } else {
-                    printer.PrintIgnored("Container %s not found or in unknown state: %s", container, result)
+                    result.Status = types.STATUS_IGNORED
+                    result.Message = fmt.Sprintf("Container %s not found or in unknown state: %s", container, state)
                 }
             }
+            addResult(nodeReport, result)
         }
     }
+
+    return check
 }
 
-func checkCertificatesExpiration(group string, certificates []string, nodes []types.Node) {
+func checkCertificatesExpiration(group string, certificates []types.Certificate, defaults types.Certificate, nodes []types.Node) *types.CheckReport {
+    check := &types.CheckReport{Name: types.CERTIFICATES_CHECKNAME}
     printer.PrintHeader(fmt.Sprintf("Checking certificate status in group [%s]", group), '-')
     if nodes == nil || len(nodes) == 0 {
-        printer.PrintSkipped("No host configured for [%s]", group)
-        return
+        skipCheck(check, "No host configured for [%s]", group)
+        return check
     }
     if certificates == nil || len(certificates) == 0 {
-        printer.PrintSkipped("No certificates configured for [%s]", group)
-        return
+        skipCheck(check, "No certificates configured for [%s]", group)
+        return check
     }
 
     for _, node := range nodes {
@@ -264,19 +385,21 @@ func checkCertificatesExpiration(group string, certificates []string, nodes []ty
 
         printer.PrintNewLine()
         printer.Print("On node %s:", util.ToNodeLabel(node))
-        cmdExecutor.SetNode(node)
+        executor := cmdExecutor.ForNode(node)
+        nodeReport := newNodeCheckReport(check, node)
 
         for _, cert := range certificates {
-            cert = parseTemplate(cert, node)
-            sshOut, err := cmdExecutor.PerformCmd(fmt.Sprintf("openssl x509 -enddate -noout -in %s", cert), clusterStatusOpts.Sudo)
+            cert.Path = parseTemplate(cert.Path, node)
+            cert.Key = parseTemplate(cert.Key, node)
+            cert.CA = parseTemplate(firstNonEmpty(cert.CA, defaults.CA), node)
+            cert.Warning = firstNonEmpty(cert.Warning, defaults.Warning)
+            cert.Critical = firstNonEmpty(cert.Critical, defaults.Critical)
 
-            if err != nil {
-                printer.PrintErr("Error checking expiration of %s: %s", cert, err)
-            } else {
-                printer.PrintOk("Certificate %s is valid until %s", cert, strings.Replace(sshOut.Stdout, "notAfter=", "", 1))
-            }
+            addResult(nodeReport, checkCertificate(executor, cert))
         }
     }
+
+    return check
 }
 
 func parseTemplate(value string, node types.Node) string {
@@ -306,11 +429,12 @@ func parseTemplate(value string, node types.Node) string {
     return value
 }
 
-func checkDiskStatus(group string, diskSpace types.DiskUsage, nodes []types.Node) {
+func checkDiskStatus(group string, diskSpace types.DiskUsage, nodes []types.Node) *types.CheckReport {
+    check := &types.CheckReport{Name: types.DISKUSAGE_CHECKNAME}
     printer.PrintHeader(fmt.Sprintf("Checking disk status in group [%s]", group), '-')
     if nodes == nil || len(nodes) == 0 {
-        printer.PrintSkipped("No host configured for [%s]", group)
-        return
+        skipCheck(check, "No host configured for [%s]", group)
+        return check
     }
 
     for _, node := range nodes {
@@ -321,76 +445,213 @@ func checkDiskStatus(group string, diskSpace types.DiskUsage, nodes []types.Node
 
         printer.PrintNewLine()
         printer.Print("On node %s:", util.ToNodeLabel(node))
-        cmdExecutor.SetNode(node)
+        executor := cmdExecutor.ForNode(node)
+        nodeReport := newNodeCheckReport(check, node)
 
-        spacesRegex := regexp.MustCompile("\\s+")
-        if len(diskSpace.FileSystemUsage) > 0 {
-            for _, fsUsage := range diskSpace.FileSystemUsage {
-                sshOut, err := cmdExecutor.PerformCmd(fmt.Sprintf("df -h | grep %s", fsUsage), clusterStatusOpts.Sudo)
+        for _, fs := range diskSpace.FileSystemUsage {
+            addResult(nodeReport, checkFileSystemUsage(executor, fs, diskSpace))
 
-                if err != nil {
-                    printer.PrintErr("Error estimating file system usage for %s: %s", fsUsage, err)
-                } else {
-                    splits := spacesRegex.Split(sshOut.Stdout, 6)
-                    fsUsed := splits[2]
-                    fsAvail := splits[3]
-                    fsUsePercent := strings.Replace(splits[4], "%", "", 1)
-                    fsUsePercentVal, err := strconv.Atoi(fsUsePercent)
-
-                    if err != nil {
-                        printer.PrintErr("Error determining file system usage percent for %s: %s", fsUsage, err)
-                    } else {
-                        if fsUsePercentVal < 65 {
-                            printer.PrintOk("File system usage of %s amounts to - Used: %s Available: %s (%s%s)",
-                                fsUsage, fsUsed, fsAvail, fsUsePercent, "%%")
-                        } else if fsUsePercentVal < 85 {
-                            printer.PrintWarn("File system usage of %s amounts to - Used: %s Available: %s (%s%s)",
-                                fsUsage, fsUsed, fsAvail, fsUsePercent, "%%")
-                        } else {
-                            printer.PrintErr("File system usage of %s amounts to - Used: %s Available: %s (%s%s)",
-                                fsUsage, fsUsed, fsAvail, fsUsePercent, "%%")
-                        }
-                    }
-                }
+            if diskSpace.InodeUsage || fs.InodeWarning > 0 || fs.InodeCritical > 0 {
+                addResult(nodeReport, checkInodeUsage(executor, fs, diskSpace))
             }
         }
 
-        if len(diskSpace.DirectoryUsage) > 0 {
-            for _, dirUsage := range diskSpace.DirectoryUsage {
-                cmd := fmt.Sprintf("du -h -d 0 %s", dirUsage)
-                sshOut, err := cmdExecutor.PerformCmd(cmd, clusterStatusOpts.Sudo)
+        for _, dir := range diskSpace.DirectoryUsage {
+            addResult(nodeReport, checkDirectoryUsage(executor, dir, diskSpace))
+        }
+    }
 
-                if err != nil {
-                    printer.PrintErr("Error estimating directory usage for %s: %s", dirUsage, err)
-                } else {
-                    splits := spacesRegex.Split(sshOut.Stdout, 2)
-                    dirUse := splits[0]
+    return check
+}
 
-                    printer.PrintOk("Directory usage of %s amounts to %s", dirUsage, dirUse)
-                }
-            }
+func checkFileSystemUsage(executor types.CommandExecutor, fs types.FileSystemUsage, diskSpace types.DiskUsage) types.CheckResult {
+    result := types.CheckResult{Target: fs.Path}
+    sshOut, err := executor.PerformCmd(fmt.Sprintf("df -h | grep %s", fs.Path), clusterStatusOpts.Sudo)
+
+    if err != nil {
+        result.Status = types.STATUS_ERROR
+        result.Message = fmt.Sprintf("Error estimating file system usage for %s: %s", fs.Path, err)
+        return result
+    }
+
+    splits := spacesRegex.Split(sshOut.Stdout, 6)
+    if len(splits) < 5 {
+        result.Status = types.STATUS_ERROR
+        result.Message = fmt.Sprintf("Error parsing file system usage for %s: %s", fs.Path, sshOut.Stdout)
+        return result
+    }
+
+    fsUsed := splits[2]
+    fsAvail := splits[3]
+    fsUsePercentVal, err := strconv.Atoi(strings.Replace(splits[4], "%", "", 1))
+
+    if err != nil {
+        result.Status = types.STATUS_ERROR
+        result.Message = fmt.Sprintf("Error determining file system usage percent for %s: %s", fs.Path, err)
+        return result
+    }
+
+    warning := firstPositive(fs.Warning, diskSpace.Warning, defaultDiskWarning)
+    critical := firstPositive(fs.Critical, diskSpace.Critical, defaultDiskCritical)
+    result.Status = percentStatus(fsUsePercentVal, warning, critical)
+    result.Message = fmt.Sprintf("File system usage of %s amounts to - Used: %s Available: %s (%d%%)",
+        fs.Path, fsUsed, fsAvail, fsUsePercentVal)
+    result.Values = map[string]interface{}{"used": fsUsed, "available": fsAvail, "usedPercent": fsUsePercentVal,
+        "warning": warning, "critical": critical}
+
+    return result
+}
+
+func checkInodeUsage(executor types.CommandExecutor, fs types.FileSystemUsage, diskSpace types.DiskUsage) types.CheckResult {
+    result := types.CheckResult{Target: fs.Path + " (inodes)"}
+    sshOut, err := executor.PerformCmd(fmt.Sprintf("df -i | grep %s", fs.Path), clusterStatusOpts.Sudo)
+
+    if err != nil {
+        result.Status = types.STATUS_ERROR
+        result.Message = fmt.Sprintf("Error estimating inode usage for %s: %s", fs.Path, err)
+        return result
+    }
+
+    splits := spacesRegex.Split(sshOut.Stdout, 6)
+    if len(splits) < 5 {
+        result.Status = types.STATUS_ERROR
+        result.Message = fmt.Sprintf("Error parsing inode usage for %s: %s", fs.Path, sshOut.Stdout)
+        return result
+    }
+
+    // File systems like btrfs do not have a fixed number of inodes
+    if splits[4] == "-" {
+        result.Status = types.STATUS_IGNORED
+        result.Message = fmt.Sprintf("File system %s does not report inode usage", fs.Path)
+        return result
+    }
+
+    inodeUsePercentVal, err := strconv.Atoi(strings.Replace(splits[4], "%", "", 1))
+    if err != nil {
+        result.Status = types.STATUS_ERROR
+        result.Message = fmt.Sprintf("Error determining inode usage percent for %s: %s", fs.Path, err)
+        return result
+    }
+
+    warning := firstPositive(fs.InodeWarning, diskSpace.InodeWarning, defaultDiskWarning)
+    critical := firstPositive(fs.InodeCritical, diskSpace.InodeCritical, defaultDiskCritical)
+    result.Status = percentStatus(inodeUsePercentVal, warning, critical)
+    result.Message = fmt.Sprintf("Inode usage of %s amounts to - Used: %s Free: %s (%d%%)",
+        fs.Path, splits[2], splits[3], inodeUsePercentVal)
+    result.Values = map[string]interface{}{"inodesUsed": splits[2], "inodesFree": splits[3], "inodesUsedPercent": inodeUsePercentVal,
+        "warning": warning, "critical": critical}
+
+    return result
+}
+
+func checkDirectoryUsage(executor types.CommandExecutor, dir types.DirectoryUsage, diskSpace types.DiskUsage) types.CheckResult {
+    result := types.CheckResult{Target: dir.Path}
+    sshOut, err := executor.PerformCmd(fmt.Sprintf("du -h -d 0 %s", dir.Path), clusterStatusOpts.Sudo)
+
+    if err != nil {
+        result.Status = types.STATUS_ERROR
+        result.Message = fmt.Sprintf("Error estimating directory usage for %s: %s", dir.Path, err)
+        return result
+    }
+
+    dirUse := spacesRegex.Split(sshOut.Stdout, 2)[0]
+    result.Status = types.STATUS_OK
+    result.Message = fmt.Sprintf("Directory usage of %s amounts to %s", dir.Path, dirUse)
+    result.Values = map[string]interface{}{"size": dirUse}
+
+    warning := firstNonEmpty(dir.Warning, diskSpace.DirectoryWarning)
+    critical := firstNonEmpty(dir.Critical, diskSpace.DirectoryCritical)
+    if warning == "" && critical == "" {
+        return result
+    }
+
+    size, err := util.ParseSize(dirUse)
+    if err != nil {
+        result.Status = types.STATUS_UNKNOWN
+        result.Message = fmt.Sprintf("Error parsing directory usage of %s: %s", dir.Path, err)
+        return result
+    }
+    result.Values["sizeBytes"] = size
+
+    for _, threshold := range []struct {
+        limit  string
+        status string
+    }{{critical, types.STATUS_ERROR}, {warning, types.STATUS_WARN}} {
+        if threshold.limit == "" {
+            continue
+        }
+
+        limit, err := util.ParseSize(threshold.limit)
+        if err != nil {
+            result.Status = types.STATUS_UNKNOWN
+            result.Message = fmt.Sprintf("Invalid threshold for directory %s: %s", dir.Path, err)
+            return result
+        }
+
+        if size > limit {
+            result.Status = threshold.status
+            result.Message = fmt.Sprintf("%s exceeds %s", result.Message, threshold.limit)
+            break
         }
     }
+    result.Values["warning"] = warning
+    result.Values["critical"] = critical
+
+    return result
+}
+
+func percentStatus(value int, warning int, critical int) string {
+    if value >= critical {
+        return types.STATUS_ERROR
+    } else if value >= warning {
+        return types.STATUS_WARN
+    }
+    return types.STATUS_OK
 }
 
-func checkKubernetesStatus(group string, kubernetes types.Kubernetes, nodes []types.Node) {
+func firstPositive(values ...int) int {
+    for _, v := range values {
+        if v > 0 {
+            return v
+        }
+    }
+    return 0
+}
+
+func firstNonEmpty(values ...string) string {
+    for _, v := range values {
+        if v != "" {
+            return v
+        }
+    }
+    return ""
+}
+
+func checkKubernetesStatus(group string, kubernetes types.Kubernetes, nodes []types.Node) *types.CheckReport {
+    check := &types.CheckReport{Name: types.KUBERNETES_CHECKNAME}
     printer.PrintHeader(fmt.Sprintf("Checking Kubernetes status in group [%s]", group), '=')
 
     if nodes == nil || len(nodes) == 0 {
-        printer.PrintCritical("No host configured for [%s]", group)
+        skipCheck(check, "No host configured for [%s]", group)
+        return check
     }
 
     if kubernetes.Resources == nil || len(kubernetes.Resources) == 0 {
-        printer.PrintCritical("No resources configured for [%s]", group)
+        skipCheck(check, "No resources configured for [%s]", group)
+        return check
     }
 
     node := ssh.GetFirstAccessibleNode(config.Ssh.LocalOn, cmdExecutor, nodes)
 
     if !util.IsNodeAddressValid(node) {
-        printer.PrintCritical("No master available for Kubernetes status check")
+        check.Status = types.STATUS_UNKNOWN
+        check.Message = "No master available for Kubernetes status check"
+        printer.PrintUnknown(check.Message)
+        return check
     }
 
     printer.Print("Running kubectl on node %s\n", util.ToNodeLabel(node))
+    nodeReport := newNodeCheckReport(check, node)
 
     for _, resource := range kubernetes.Resources {
         msg := fmt.Sprintf("Status of %s", resource.Type)
@@ -408,12 +669,18 @@ func checkKubernetesStatus(group string, kubernetes types.Kubernetes, nodes []ty
         cmdExecutor.SetNode(node)
         sshOut, err := cmdExecutor.RunKubectlCommand(args)
         printer.PrintNewLine()
+        result := types.CheckResult{Target: resource.Type + namespace_msg}
 
         if err != nil {
-            printer.PrintErr("Error checking %s%s: %s", resource.Type, namespace_msg, err)
+            result.Status = types.STATUS_ERROR
+            result.Message = fmt.Sprintf("Error checking %s%s: %s", resource.Type, namespace_msg, err)
         } else {
-            printer.PrintOk(sshOut.Stdout)
+            result.Status = types.STATUS_OK
+            result.Message = sshOut.Stdout
         }
+        addResult(nodeReport, result)
         printer.PrintNewLine()
     }
+
+    return check
 }
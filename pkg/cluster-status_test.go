@@ -0,0 +1,480 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "os"
+    "bytes"
+    "strings"
+    "encoding/json"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/pem"
+    "math/big"
+    "time"
+    "fmt"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
+)
+
+func TestClusterStatus_UnknownOutput(t *testing.T) {
+    _, outBuffer, context := defaultContext()
+    context.Opts = &types.ClusterStatusOpts{
+        Groups: types.MASTER_GROUPNAME,
+        Output: "xml",
+    }
+
+    code := ClusterStatus(context)
+
+    assert.Equal(t, EXIT_UNKNOWN, code)
+    assert.Contains(t, outBuffer.String(), "Unknown output format xml")
+}
+
+func TestClusterStatus_WatchWithOutput(t *testing.T) {
+    mockExecutor, outBuffer, context := defaultContext()
+    context.Opts = &types.ClusterStatusOpts{
+        Groups: types.MASTER_GROUPNAME,
+        Output: types.OUTPUT_JSON,
+        Watch:  time.Second,
+    }
+
+    called := false
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        called = true
+        return &types.SSHOutput{}, nil
+    }
+
+    code := ClusterStatus(context)
+
+    assert.Equal(t, EXIT_UNKNOWN, code)
+    assert.False(t, called)
+    assert.Contains(t, outBuffer.String(), "Output format can not be combined with watch mode")
+}
+
+func TestClusterStatus_UnknownGroup(t *testing.T) {
+    mockExecutor, outBuffer, context := defaultContext()
+    context.Opts = &types.ClusterStatusOpts{
+        Groups: types.MASTER_GROUPNAME + ",Workers",
+    }
+
+    called := false
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        called = true
+        return &types.SSHOutput{}, nil
+    }
+
+    code := ClusterStatus(context)
+
+    assert.Equal(t, EXIT_UNKNOWN, code)
+    assert.False(t, called)
+    assert.Contains(t, outBuffer.String(), "unknown group Workers")
+}
+
+func TestClusterStatus_SeverityOrder(t *testing.T) {
+    assert.Equal(t, types.STATUS_WARN, worseStatus(types.STATUS_UNKNOWN, types.STATUS_WARN))
+    assert.Equal(t, types.STATUS_WARN, worseStatus(types.STATUS_WARN, types.STATUS_UNKNOWN))
+    assert.Equal(t, types.STATUS_ERROR, worseStatus(types.STATUS_WARN, types.STATUS_ERROR))
+    assert.Equal(t, types.STATUS_UNKNOWN, worseStatus(types.STATUS_OK, types.STATUS_UNKNOWN))
+
+    assert.Equal(t, types.STATUS_WARN, verdict(types.StatusSummary{Warn: 1, Unknown: 2}))
+    assert.Equal(t, types.STATUS_ERROR, verdict(types.StatusSummary{Error: 1, Warn: 1, Unknown: 1}))
+    assert.Equal(t, types.STATUS_UNKNOWN, verdict(types.StatusSummary{Ok: 3, Unknown: 1}))
+    assert.Equal(t, types.STATUS_OK, verdict(types.StatusSummary{Ok: 1, Skipped: 1, Ignored: 1}))
+}
+
+func clusterGroups(t *testing.T) []string {
+    groups, err := clusterStatusGroups()
+    assert.Nil(t, err)
+    return groups
+}
+
+func TestClusterStatus_JsonOutput(t *testing.T) {
+    mockExecutor, _, context := defaultContext()
+    context.Opts = &types.ClusterStatusOpts{
+        Groups:    types.MASTER_GROUPNAME,
+        Checks:    types.SERVICES_CHECKNAME + "," + types.DISKUSAGE_CHECKNAME,
+        SkipStats: true,
+        Output:    types.OUTPUT_JSON,
+    }
+
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        if strings.HasPrefix(command, "systemctl") {
+            return &types.SSHOutput{Stdout: "active"}, nil
+        } else if strings.HasPrefix(command, "df") {
+            return &types.SSHOutput{Stdout: "/dev/sda1 20G 14G 6G 70% /"}, nil
+        }
+        return &types.SSHOutput{Stdout: "1.2G /var/log"}, nil
+    }
+
+    reportBuffer := &bytes.Buffer{}
+    reportOut = reportBuffer
+    defer func() { reportOut = os.Stdout }()
+
+    code := ClusterStatus(context)
+    assert.Equal(t, EXIT_WARN, code)
+
+    report := types.ClusterStatusReport{}
+    err := json.Unmarshal(reportBuffer.Bytes(), &report)
+    assert.Nil(t, err)
+    assert.Len(t, report.Groups, 1)
+
+    checks := report.Groups[0].Checks
+    assert.Len(t, checks, 2)
+    assert.Equal(t, types.SERVICES_CHECKNAME, checks[0].Name)
+    assert.Len(t, checks[0].Nodes, 3)
+    assert.Equal(t, "host1", checks[0].Nodes[0].Node)
+    assert.Equal(t, types.STATUS_OK, checks[0].Nodes[0].Results[0].Status)
+
+    diskResults := checks[1].Nodes[0].Results
+    assert.Len(t, diskResults, 2)
+    assert.Equal(t, "/dev/sda1", diskResults[0].Target)
+    assert.Equal(t, types.STATUS_WARN, diskResults[0].Status)
+    assert.Equal(t, float64(70), diskResults[0].Values["usedPercent"])
+    assert.Equal(t, "1.2G", diskResults[1].Values["size"])
+
+    assert.Equal(t, types.STATUS_WARN, report.Summary.Status)
+    assert.Equal(t, 6, report.Summary.Ok)
+    assert.Equal(t, 3, report.Summary.Warn)
+}
+
+func TestClusterStatus_ExitCodes(t *testing.T) {
+    tests := []struct {
+        state string
+        code  int
+    }{
+        {"active", EXIT_OK},
+        {"inactive", EXIT_WARN},
+        {"failed", EXIT_CRITICAL},
+        {"reloading", EXIT_UNKNOWN},
+    }
+
+    for _, test := range tests {
+        mockExecutor, outBuffer, context := defaultContext()
+        context.Opts = &types.ClusterStatusOpts{
+            Groups:    types.MASTER_GROUPNAME,
+            Checks:    types.SERVICES_CHECKNAME,
+            SkipStats: true,
+        }
+
+        state := test.state
+        mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+            return &types.SSHOutput{Stdout: state}, nil
+        }
+
+        code := ClusterStatus(context)
+
+        assert.Equal(t, test.code, code, "Exit code for state %s", test.state)
+        assert.Contains(t, outBuffer.String(), "Cluster status")
+    }
+}
+
+func TestClusterStatus_DiskThresholds(t *testing.T) {
+    mockExecutor, _, context := defaultContext()
+    context.Config.ClusterGroups[0].DiskUsage = types.DiskUsage{
+        FileSystemUsage: []types.FileSystemUsage{
+            {Path: "/dev/sda1"},
+            {Path: "/dev/sdb1", Warning: 80, Critical: 95},
+        },
+        DirectoryUsage: []types.DirectoryUsage{
+            {Path: "/var/log"},
+            {Path: "/var/lib/docker", Critical: "100G"},
+        },
+        Warning:          50,
+        InodeUsage:       true,
+        DirectoryWarning: "1G",
+    }
+    context.Opts = &types.ClusterStatusOpts{
+        Groups:    types.MASTER_GROUPNAME,
+        Checks:    types.DISKUSAGE_CHECKNAME,
+        SkipStats: true,
+        Output:    types.OUTPUT_JSON,
+    }
+
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        switch command {
+        case "df -h | grep /dev/sda1":
+            return &types.SSHOutput{Stdout: "/dev/sda1 20G 12G 8G 60% /"}, nil
+        case "df -h | grep /dev/sdb1":
+            return &types.SSHOutput{Stdout: "/dev/sdb1 20G 17G 3G 85% /data"}, nil
+        case "df -i | grep /dev/sda1":
+            return &types.SSHOutput{Stdout: "/dev/sda1 1000 900 100 90% /"}, nil
+        case "df -i | grep /dev/sdb1":
+            return &types.SSHOutput{Stdout: "/dev/sdb1 0 0 0 - /data"}, nil
+        case "du -h -d 0 /var/log":
+            return &types.SSHOutput{Stdout: "1.5G /var/log"}, nil
+        default:
+            return &types.SSHOutput{Stdout: "120G /var/lib/docker"}, nil
+        }
+    }
+
+    reportBuffer := &bytes.Buffer{}
+    reportOut = reportBuffer
+    defer func() { reportOut = os.Stdout }()
+
+    code := ClusterStatus(context)
+    assert.Equal(t, EXIT_CRITICAL, code)
+
+    report := types.ClusterStatusReport{}
+    err := json.Unmarshal(reportBuffer.Bytes(), &report)
+    assert.Nil(t, err)
+
+    results := report.Groups[0].Checks[0].Nodes[0].Results
+    assert.Len(t, results, 6)
+
+    statuses := map[string]string{}
+    for _, result := range results {
+        statuses[result.Target] = result.Status
+    }
+    assert.Equal(t, types.STATUS_WARN, statuses["/dev/sda1"])
+    assert.Equal(t, types.STATUS_ERROR, statuses["/dev/sda1 (inodes)"])
+    assert.Equal(t, types.STATUS_WARN, statuses["/dev/sdb1"])
+    assert.Equal(t, types.STATUS_IGNORED, statuses["/dev/sdb1 (inodes)"])
+    assert.Equal(t, types.STATUS_WARN, statuses["/var/log"])
+    assert.Equal(t, types.STATUS_ERROR, statuses["/var/lib/docker"])
+}
+
+func TestClusterStatus_DiskDefaultThresholds(t *testing.T) {
+    tests := []struct {
+        percent string
+        status  string
+    }{
+        {"0%", types.STATUS_OK},
+        {"64%", types.STATUS_OK},
+        {"65%", types.STATUS_WARN},
+        {"84%", types.STATUS_WARN},
+        {"85%", types.STATUS_ERROR},
+        {"100%", types.STATUS_ERROR},
+    }
+
+    mockExecutor := &sshTest.MockExecutor{}
+    for _, test := range tests {
+        mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+            return &types.SSHOutput{Stdout: "/dev/sda1 100 50 50 " + test.percent + " /"}, nil
+        }
+
+        fs := types.FileSystemUsage{Path: "/dev/sda1"}
+        result := checkFileSystemUsage(mockExecutor, fs, types.DiskUsage{})
+        assert.Equal(t, test.status, result.Status, "file system usage of %s", test.percent)
+        assert.Equal(t, defaultDiskWarning, result.Values["warning"])
+        assert.Equal(t, defaultDiskCritical, result.Values["critical"])
+
+        result = checkInodeUsage(mockExecutor, fs, types.DiskUsage{})
+        assert.Equal(t, test.status, result.Status, "inode usage of %s", test.percent)
+    }
+}
+
+func createTestCertificate(t *testing.T, cn string, validFor time.Duration, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string) {
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    assert.Nil(t, err)
+
+    template := &x509.Certificate{
+        SerialNumber:          big.NewInt(time.Now().UnixNano()),
+        Subject:               pkix.Name{CommonName: cn},
+        DNSNames:              []string{cn},
+        NotBefore:             time.Now().Add(-time.Hour),
+        NotAfter:              time.Now().Add(validFor),
+        IsCA:                  parent == nil,
+        BasicConstraintsValid: true,
+        KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+    }
+    if parent == nil {
+        parent, parentKey = template, key
+    }
+
+    der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+    assert.Nil(t, err)
+    cert, err := x509.ParseCertificate(der)
+    assert.Nil(t, err)
+
+    return cert, key, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func publicKeyPem(t *testing.T, key *ecdsa.PrivateKey) string {
+    der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+    assert.Nil(t, err)
+    return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestClusterStatus_Certificates(t *testing.T) {
+    ca, caKey, caPem := createTestCertificate(t, "ca", 365*24*time.Hour, nil, nil)
+    _, _, otherCaPem := createTestCertificate(t, "other-ca", 365*24*time.Hour, nil, nil)
+    _, serverKey, serverPem := createTestCertificate(t, "apiserver", 90*24*time.Hour, ca, caKey)
+    _, clientKey, clientPem := createTestCertificate(t, "client", 10*24*time.Hour, ca, caKey)
+    _, _, expiredPem := createTestCertificate(t, "expired", -time.Minute, ca, caKey)
+
+    files := map[string]string{
+        "cat /certs/ca.crt":                          caPem,
+        "cat /certs/other-ca.crt":                    otherCaPem,
+        "cat /certs/server.crt":                      serverPem,
+        "cat /certs/client.crt":                      clientPem,
+        "cat /certs/expired.crt":                     expiredPem,
+        "openssl pkey -pubout -in /certs/server.key": publicKeyPem(t, serverKey),
+        "openssl pkey -pubout -in /certs/client.key": publicKeyPem(t, clientKey),
+    }
+
+    mockExecutor, _, context := defaultContext()
+    context.Config.ClusterGroups[0].Certificates = []types.Certificate{
+        {Path: "/certs/server.crt", Key: "/certs/server.key"},
+        {Path: "/certs/client.crt", Key: "/certs/server.key", Warning: "5d", Critical: "2d"},
+        {Path: "/certs/server.crt", CA: "/certs/other-ca.crt"},
+        {Path: "/certs/expired.crt"},
+        {Path: "/certs/client.crt"},
+    }
+    context.Config.ClusterGroups[0].CertificateCA = "/certs/ca.crt"
+    context.Opts = &types.ClusterStatusOpts{
+        Groups:    types.MASTER_GROUPNAME,
+        Checks:    types.CERTIFICATES_CHECKNAME,
+        SkipStats: true,
+        Output:    types.OUTPUT_JSON,
+    }
+
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        return &types.SSHOutput{Stdout: files[command]}, nil
+    }
+
+    reportBuffer := &bytes.Buffer{}
+    reportOut = reportBuffer
+    defer func() { reportOut = os.Stdout }()
+
+    ClusterStatus(context)
+
+    report := types.ClusterStatusReport{}
+    err := json.Unmarshal(reportBuffer.Bytes(), &report)
+    assert.Nil(t, err)
+
+    results := report.Groups[0].Checks[0].Nodes[0].Results
+    assert.Len(t, results, 5)
+
+    assert.Equal(t, types.STATUS_OK, results[0].Status)
+    assert.Equal(t, "CN=apiserver", results[0].Values["subject"])
+    assert.Equal(t, "CN=ca", results[0].Values["issuer"])
+    assert.Equal(t, []interface{}{"apiserver"}, results[0].Values["sans"])
+    assert.Equal(t, float64(256), results[0].Values["keySize"])
+    assert.Equal(t, true, results[0].Values["caVerified"])
+    assert.Equal(t, true, results[0].Values["keyMatches"])
+
+    assert.Equal(t, types.STATUS_ERROR, results[1].Status)
+    assert.Equal(t, false, results[1].Values["keyMatches"])
+    assert.Contains(t, results[1].Message, "does not match")
+
+    assert.Equal(t, types.STATUS_ERROR, results[2].Status)
+    assert.Equal(t, false, results[2].Values["caVerified"])
+
+    assert.Equal(t, types.STATUS_ERROR, results[3].Status)
+    assert.Contains(t, results[3].Message, "expired")
+
+    assert.Equal(t, types.STATUS_WARN, results[4].Status)
+}
+
+func TestClusterStatus_CustomChecks(t *testing.T) {
+    mockExecutor, _, context := defaultContext()
+    noSwapExitCode := 1
+    context.Config.ClusterGroups[0].CustomChecks = []types.CustomCheck{
+        {Name: "IpForward", Command: "sysctl -n net.ipv4.ip_forward", Stdout: "^1$"},
+        {Name: "ApiPort", Command: "ss -ltn | grep -q :6443", Severity: "warn"},
+        {Name: "OpenFiles", Command: "cat /proc/sys/fs/file-nr | cut -f1", Compare: "< 1000"},
+        {Name: "Unselected", Command: "true"},
+        {Name: "Kernel", Command: "uname -r; false", Stdout: "^4\\."},
+        {Name: "NoSwap", Command: "swapon --show", ExitCode: &noSwapExitCode},
+        {Name: "Typo", Command: "true", Severity: "critical"},
+    }
+    context.Config.ClusterGroups[0].Nodes = context.Config.ClusterGroups[0].Nodes[:1]
+    context.Opts = &types.ClusterStatusOpts{
+        Groups:    types.MASTER_GROUPNAME,
+        Checks:    "IpForward,ApiPort,OpenFiles,Kernel,NoSwap,Typo",
+        SkipStats: true,
+        Output:    types.OUTPUT_JSON,
+    }
+
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        switch command {
+        case "sysctl -n net.ipv4.ip_forward":
+            return &types.SSHOutput{Stdout: "1"}, nil
+        case "ss -ltn | grep -q :6443":
+            return &types.SSHOutput{ExitStatus: 1}, fmt.Errorf("")
+        case "uname -r; false":
+            return &types.SSHOutput{Stdout: "4.15.0", ExitStatus: 1}, fmt.Errorf("")
+        case "swapon --show":
+            return &types.SSHOutput{}, nil
+        case "true":
+            t.Error("custom check with invalid severity was run")
+            return &types.SSHOutput{}, nil
+        default:
+            return &types.SSHOutput{Stdout: "1500"}, nil
+        }
+    }
+
+    reportBuffer := &bytes.Buffer{}
+    reportOut = reportBuffer
+    defer func() { reportOut = os.Stdout }()
+
+    code := ClusterStatus(context)
+    assert.Equal(t, EXIT_CRITICAL, code)
+
+    report := types.ClusterStatusReport{}
+    err := json.Unmarshal(reportBuffer.Bytes(), &report)
+    assert.Nil(t, err)
+
+    checks := report.Groups[0].Checks
+    assert.Len(t, checks, 6)
+
+    assert.Equal(t, "IpForward", checks[0].Name)
+    assert.Equal(t, types.STATUS_OK, checks[0].Nodes[0].Results[0].Status)
+    assert.Equal(t, "ApiPort", checks[1].Name)
+    assert.Equal(t, types.STATUS_WARN, checks[1].Nodes[0].Results[0].Status)
+    assert.Contains(t, checks[1].Nodes[0].Results[0].Message, "exit code 1, expected 0")
+    assert.Equal(t, "OpenFiles", checks[2].Name)
+    assert.Equal(t, types.STATUS_ERROR, checks[2].Nodes[0].Results[0].Status)
+    // the exit code is ignored when only the output is checked
+    assert.Equal(t, "Kernel", checks[3].Name)
+    assert.Equal(t, types.STATUS_OK, checks[3].Nodes[0].Results[0].Status)
+    assert.Equal(t, "NoSwap", checks[4].Name)
+    assert.Equal(t, types.STATUS_ERROR, checks[4].Nodes[0].Results[0].Status)
+    assert.Contains(t, checks[4].Nodes[0].Results[0].Message, "exit code 0, expected 1")
+    assert.Equal(t, "Typo", checks[5].Name)
+    assert.Equal(t, types.STATUS_UNKNOWN, checks[5].Nodes[0].Results[0].Status)
+    assert.Contains(t, checks[5].Nodes[0].Results[0].Message, "unknown severity critical")
+}
+
+func TestClusterStatus_WatchChanges(t *testing.T) {
+    mockExecutor, outBuffer, context := defaultContext()
+    context.Opts = &types.ClusterStatusOpts{
+        Groups:    types.MASTER_GROUPNAME,
+        Checks:    types.SERVICES_CHECKNAME,
+        SkipStats: true,
+    }
+    initParams(context)
+    clusterStatusOpts = context.Opts.(*types.ClusterStatusOpts)
+
+    state := "active"
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                if node.Host == "host3" {
+                    return &types.SSHOutput{Stdout: state}, nil
+                }
+                return &types.SSHOutput{Stdout: "active"}, nil
+            },
+        }
+    }
+
+    previous := runClusterStatus(clusterGroups(t))
+    state = "failed"
+    current := runClusterStatus(clusterGroups(t))
+
+    changes := diffReports(previous, current)
+    assert.Len(t, changes, 1)
+    assert.Equal(t, "docker on host3 went active -> failed", describeChange(changes[0]))
+
+    outBuffer.Reset()
+    printChanges(previous, current)
+    out := outBuffer.String()
+    assert.Contains(t, out, "docker on host3 went active -> failed")
+    assert.Contains(t, out, "Cluster status went OK -> ERROR")
+
+    assert.Empty(t, diffReports(current, current))
+}
@@ -0,0 +1,147 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+// containerRuntimeDetectCmd prints crictl when crictl reaches a CRI runtime and docker otherwise
+const containerRuntimeDetectCmd = "crictl info > /dev/null 2>&1 && echo crictl || echo docker"
+
+// containerRuntime builds the commands which inspect the containers of a node
+type containerRuntime interface {
+	Name() string
+	// StateCommand prints the state of the latest container whose name matches name
+	StateCommand(name string) string
+	// ParseState returns the state printed by StateCommand as docker names it: running, created, exited, ...
+	ParseState(output string) string
+	// LogsCommand returns the command retrieving the logs of the latest container whose name matches name,
+	// executor is used to resolve the container on its node
+	LogsCommand(name string, opts *types.LogsOpts, executor types.CommandExecutor) (string, error)
+}
+
+type dockerRuntime struct{}
+
+func (dockerRuntime) Name() string {
+	return types.CONTAINER_RUNTIME_DOCKER
+}
+
+func (dockerRuntime) StateCommand(name string) string {
+	return fmt.Sprintf("docker ps -a -q --latest -f name=%s* | xargs --no-run-if-empty docker inspect -f '{{.State.Status}}'", name)
+}
+
+func (dockerRuntime) ParseState(output string) string {
+	return strings.TrimSpace(output)
+}
+
+func (dockerRuntime) LogsCommand(name string, opts *types.LogsOpts, executor types.CommandExecutor) (string, error) {
+	command := []string{"docker logs"}
+	if opts.Tail > 0 {
+		command = append(command, fmt.Sprintf("--tail %d", opts.Tail))
+	}
+	if opts.Since != "" {
+		command = append(command, fmt.Sprintf("--since %s", opts.Since))
+	}
+	if opts.Follow {
+		command = append(command, "--follow --timestamps")
+	}
+	command = append(command, opts.ExtraArgs...)
+	command = append(command, name)
+	return strings.Join(command, " "), nil
+}
+
+// crictlRuntime reaches containerd and CRI-O through the CRI. Containers are named like the containers of their
+// pod there, the k8s_ prefix docker names them with is dropped so that the same configuration fits both runtimes.
+type crictlRuntime struct{}
+
+type crictlContainers struct {
+	Containers []struct {
+		State string `json:"state"`
+	} `json:"containers"`
+}
+
+func (crictlRuntime) Name() string {
+	return types.CONTAINER_RUNTIME_CRICTL
+}
+
+func (crictlRuntime) latestContainer(name string, format string) string {
+	return fmt.Sprintf("crictl ps -a --latest --name '%s' %s", strings.TrimPrefix(name, "k8s_"), format)
+}
+
+func (r crictlRuntime) StateCommand(name string) string {
+	return r.latestContainer(name, "--output json")
+}
+
+// ParseState maps the CRI states like CONTAINER_RUNNING, an empty list is returned as empty state
+func (crictlRuntime) ParseState(output string) string {
+	containers := crictlContainers{}
+	if err := json.Unmarshal([]byte(output), &containers); err != nil {
+		return strings.TrimSpace(output)
+	}
+	if len(containers.Containers) == 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(containers.Containers[0].State, "CONTAINER_"))
+}
+
+// LogsCommand resolves the id of the container first since crictl logs does not accept names. The lookup runs
+// as a separate command so that it is run with sudo like the logs command itself.
+func (r crictlRuntime) LogsCommand(name string, opts *types.LogsOpts, executor types.CommandExecutor) (string, error) {
+	sshOut, err := executor.PerformCmd(r.latestContainer(name, "--quiet"), opts.Sudo)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up container %s: %s", name, err)
+	}
+	id := strings.TrimSpace(sshOut.Stdout)
+	if id == "" {
+		return "", fmt.Errorf("container %s not found", name)
+	}
+
+	command := []string{"crictl logs"}
+	if opts.Tail > 0 {
+		command = append(command, fmt.Sprintf("--tail=%d", opts.Tail))
+	}
+	if opts.Since != "" {
+		command = append(command, fmt.Sprintf("--since=%s", opts.Since))
+	}
+	if opts.Follow {
+		command = append(command, "--follow --timestamps")
+	}
+	command = append(command, opts.ExtraArgs...)
+	command = append(command, id)
+	return strings.Join(command, " "), nil
+}
+
+// containerRuntimeFor returns the configured runtime or detects it on the node of executor. crictl is preferred
+// when it reaches a runtime, docker is assumed otherwise. The detection is run with sudo since crictl needs
+// access to the runtime socket.
+func containerRuntimeFor(configured string, executor types.CommandExecutor, sudo bool) (containerRuntime, error) {
+	switch strings.ToLower(configured) {
+	case types.CONTAINER_RUNTIME_DOCKER:
+		return dockerRuntime{}, nil
+	case types.CONTAINER_RUNTIME_CRICTL, "containerd", "cri-o":
+		return crictlRuntime{}, nil
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown container runtime %s, valid values are docker and crictl", configured)
+	}
+
+	sshOut, err := executor.PerformCmd(containerRuntimeDetectCmd, sudo)
+	if err == nil && strings.TrimSpace(sshOut.Stdout) == types.CONTAINER_RUNTIME_CRICTL {
+		return crictlRuntime{}, nil
+	}
+	return dockerRuntime{}, nil
+}
+
+// nodeContainerRuntime returns the runtime configured by the first group of node which configures one
+func nodeContainerRuntime(node types.Node) string {
+	for _, group := range config.ClusterGroups {
+		if group.ContainerRuntime != "" && util.NodeInArray(group.Nodes, node) {
+			return group.ContainerRuntime
+		}
+	}
+	return ""
+}
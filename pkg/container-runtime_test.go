@@ -0,0 +1,163 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "bytes"
+    "encoding/json"
+    "os"
+    "strings"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
+)
+
+func TestCrictlRuntime(t *testing.T) {
+    runtime := crictlRuntime{}
+
+    assert.Equal(t, "crictl ps -a --latest --name 'kube-apiserver' --output json", runtime.StateCommand("k8s_kube-apiserver"))
+    assert.Equal(t, "running", runtime.ParseState(`{"containers": [{"id": "1a2b", "state": "CONTAINER_RUNNING"}]}`))
+    assert.Equal(t, "exited", runtime.ParseState(`{"containers": [{"id": "1a2b", "state": "CONTAINER_EXITED"}]}`))
+    assert.Equal(t, "", runtime.ParseState(`{"containers": []}`))
+
+    containerID := ""
+    mockExecutor := &sshTest.MockExecutor{}
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        assert.Equal(t, "crictl ps -a --latest --name 'kube-proxy' --quiet", command)
+        assert.True(t, sudo)
+        return &types.SSHOutput{Stdout: containerID}, nil
+    }
+
+    containerID = "1a2b3c\n"
+    opts := &types.LogsOpts{Tail: 10, Since: "10m", Follow: true, GenericOpts: types.GenericOpts{Sudo: true}}
+    command, err := runtime.LogsCommand("k8s_kube-proxy", opts, mockExecutor)
+    assert.Nil(t, err)
+    assert.Equal(t, "crictl logs --tail=10 --since=10m --follow --timestamps 1a2b3c", command)
+
+    containerID = ""
+    _, err = runtime.LogsCommand("k8s_kube-proxy", opts, mockExecutor)
+    assert.EqualError(t, err, "container k8s_kube-proxy not found")
+}
+
+func TestContainerRuntimeFor(t *testing.T) {
+    mockExecutor := &sshTest.MockExecutor{}
+    detected := ""
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        assert.Equal(t, containerRuntimeDetectCmd, command)
+        assert.True(t, sudo)
+        return &types.SSHOutput{Stdout: detected}, nil
+    }
+
+    for configured, expected := range map[string]string{
+        "docker":     types.CONTAINER_RUNTIME_DOCKER,
+        "containerd": types.CONTAINER_RUNTIME_CRICTL,
+        "CRI-O":      types.CONTAINER_RUNTIME_CRICTL,
+    } {
+        runtime, err := containerRuntimeFor(configured, mockExecutor, true)
+        assert.Nil(t, err)
+        assert.Equal(t, expected, runtime.Name(), configured)
+    }
+
+    detected = "crictl\n"
+    runtime, err := containerRuntimeFor("", mockExecutor, true)
+    assert.Nil(t, err)
+    assert.Equal(t, types.CONTAINER_RUNTIME_CRICTL, runtime.Name())
+
+    detected = "docker\n"
+    runtime, err = containerRuntimeFor("", mockExecutor, true)
+    assert.Nil(t, err)
+    assert.Equal(t, types.CONTAINER_RUNTIME_DOCKER, runtime.Name())
+
+    _, err = containerRuntimeFor("rkt", mockExecutor, true)
+    assert.EqualError(t, err, "unknown container runtime rkt, valid values are docker and crictl")
+}
+
+func TestClusterStatus_ContainersWithCrictl(t *testing.T) {
+    mockExecutor, _, cmdContext := defaultContext()
+    cmdContext.Config.ClusterGroups[0].ContainerRuntime = "containerd"
+    cmdContext.Opts = &types.ClusterStatusOpts{
+        Groups:    types.MASTER_GROUPNAME,
+        Checks:    types.CONTAINERS_CHECKNAME,
+        SkipStats: true,
+        Output:    types.OUTPUT_JSON,
+    }
+
+    commands := []string{}
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        commands = append(commands, command)
+        if strings.Contains(command, "kube-scheduler") {
+            return &types.SSHOutput{Stdout: `{"containers": [{"state": "CONTAINER_EXITED"}]}`}, nil
+        }
+        return &types.SSHOutput{Stdout: `{"containers": [{"state": "CONTAINER_RUNNING"}]}`}, nil
+    }
+
+    reportBuffer := &bytes.Buffer{}
+    reportOut = reportBuffer
+    defer func() { reportOut = os.Stdout }()
+
+    code := ClusterStatus(cmdContext)
+    assert.Equal(t, EXIT_CRITICAL, code)
+    assert.Contains(t, commands, "crictl ps -a --latest --name 'kube-apiserver' --output json")
+    assert.NotContains(t, commands, containerRuntimeDetectCmd)
+
+    report := types.ClusterStatusReport{}
+    err := json.Unmarshal(reportBuffer.Bytes(), &report)
+    assert.Nil(t, err)
+
+    results := report.Groups[0].Checks[0].Nodes[0].Results
+    assert.Len(t, results, 3)
+    assert.Equal(t, types.STATUS_OK, results[0].Status)
+    assert.Equal(t, "crictl", results[0].Values["runtime"])
+    assert.Equal(t, "exited", results[2].Values["state"])
+    assert.Equal(t, types.STATUS_ERROR, results[2].Status)
+}
+
+func TestLogs_ContainerDetectsCrictl(t *testing.T) {
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.LogsOpts{
+        Type: "container",
+        Tail: 10,
+        GenericOpts: types.GenericOpts{
+            NodeArg: "host1",
+            TargetArg: "k8s_kube-apiserver",
+        },
+    }
+
+    called := false
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        if command == containerRuntimeDetectCmd {
+            return &types.SSHOutput{Stdout: "crictl"}, nil
+        }
+        if command == "crictl ps -a --latest --name 'kube-apiserver' --quiet" {
+            return &types.SSHOutput{Stdout: "1a2b3c"}, nil
+        }
+        called = true
+        assert.Equal(t, "crictl logs --tail=10 1a2b3c", command)
+        return &types.SSHOutput{Stdout: "apiserver logs"}, nil
+    }
+
+    Logs(cmdContext)
+    assert.True(t, called)
+    assert.Contains(t, outBuffer.String(), "apiserver logs")
+}
+
+func TestLogs_ContainerNotFoundWithCrictl(t *testing.T) {
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Config.ClusterGroups[0].ContainerRuntime = "crictl"
+    cmdContext.Opts = &types.LogsOpts{
+        Type: "container",
+        GenericOpts: types.GenericOpts{
+            NodeArg: "host1",
+            TargetArg: "k8s_kube-apiserver",
+        },
+    }
+
+    commands := []string{}
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        commands = append(commands, command)
+        return &types.SSHOutput{}, nil
+    }
+
+    Logs(cmdContext)
+    assert.Equal(t, []string{"crictl ps -a --latest --name 'kube-apiserver' --quiet"}, commands)
+    assert.Contains(t, outBuffer.String(), "container k8s_kube-apiserver not found")
+}
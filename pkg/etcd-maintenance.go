@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+const defaultDefragCommandTimeout = 5 * time.Minute
+
+func EtcdDefrag(cmdParams *types.CommandContext) {
+	nodes := initEtcdCluster(cmdParams)
+
+	ordered := leaderLast(nodes)
+	rows := [][]string{}
+	statuses := []string{}
+
+	for _, node := range ordered {
+		label := util.ToNodeLabel(node)
+		printer.PrintHeader(fmt.Sprintf("Defragmenting %s", label), '-')
+
+		// A member is unavailable while it is defragmented, never start while another one is down already
+		before, unhealthy := queryEtcdClusterHealth(nodes, node)
+		if len(unhealthy) > 0 {
+			printTable([]string{"NODE", "BEFORE", "AFTER"}, rows, statuses)
+			printer.PrintCritical("Cluster is not healthy, aborting defragmentation before %s:\n%s", label, strings.Join(unhealthy, "\n"))
+		}
+
+		executor := cmdExecutor.ForNode(node)
+		if _, err := etcdctl(executor, node, fmt.Sprintf("defrag --command-timeout=%s", defragCommandTimeout())); err != nil {
+			printTable([]string{"NODE", "BEFORE", "AFTER"}, rows, statuses)
+			printer.PrintCritical("Error defragmenting member %s: %s", label, err)
+		}
+
+		sizeBefore := formatBytes(before.status.Status.DbSize)
+		after, err := queryEtcdEndpointStatus(executor, node)
+		if err != nil {
+			printer.PrintWarn("Member %s defragmented, but its status could not be queried: %s", label, err)
+			rows = append(rows, []string{label, sizeBefore, "-"})
+			statuses = append(statuses, types.STATUS_WARN)
+			continue
+		}
+
+		sizeAfter := formatBytes(after.Status.DbSize)
+		printer.PrintOk("Member %s defragmented: %s -> %s", label, sizeBefore, sizeAfter)
+		rows = append(rows, []string{label, sizeBefore, sizeAfter})
+		statuses = append(statuses, types.STATUS_OK)
+	}
+
+	printer.PrintNewLine()
+	printTable([]string{"NODE", "BEFORE", "AFTER"}, rows, statuses)
+}
+
+func EtcdCompact(cmdParams *types.CommandContext) {
+	nodes := initEtcdCluster(cmdParams)
+
+	if etcdClusterOpts.Retention < 0 {
+		printer.PrintCritical("Retention must not be negative")
+	}
+
+	node, executor := firstAccessibleEtcdNode(nodes)
+	status, err := queryEtcdEndpointStatus(executor, node)
+	if err != nil {
+		printer.PrintCritical("Error querying current revision: %s", err)
+	}
+
+	revision := status.Status.Header.Revision
+	target := revision - etcdClusterOpts.Retention
+	if target <= 0 {
+		printer.PrintOk("Current revision %d is within the retention of %d revisions, nothing to compact",
+			revision, etcdClusterOpts.Retention)
+		return
+	}
+
+	args := fmt.Sprintf("compaction %d", target)
+	if etcdClusterOpts.Physical {
+		args += " --physical"
+	}
+
+	if _, err := etcdctl(executor, node, args); err != nil {
+		if strings.Contains(err.Error(), "required revision has been compacted") {
+			printer.PrintOk("Keyspace is already compacted beyond revision %d", target)
+			return
+		}
+		printer.PrintCritical("Error compacting keyspace to revision %d: %s", target, err)
+	}
+
+	printer.PrintOk("Compacted keyspace to revision %d, current revision is %d", target, revision)
+	printer.PrintInfo("Run etcd defrag to return the freed space to the file system")
+}
+
+// queryEtcdClusterHealth queries every member and returns the status of target together with a description of
+// each member which is not healthy
+func queryEtcdClusterHealth(nodes []types.Node, target types.Node) (etcdMemberStatus, []string) {
+	var targetStatus etcdMemberStatus
+	unhealthy := []string{}
+	for _, node := range nodes {
+		member := queryEtcdMember(node)
+		if node.Host == target.Host {
+			targetStatus = member
+		}
+		if member.status == nil || !member.healthy {
+			unhealthy = append(unhealthy, fmt.Sprintf("Member %s is not healthy: %s", util.ToNodeLabel(node), member.err))
+		}
+	}
+	return targetStatus, unhealthy
+}
+
+// defragCommandTimeout replaces the default of etcdctl of 5 seconds, which a large database easily exceeds
+func defragCommandTimeout() time.Duration {
+	if etcdClusterOpts.CommandTimeout <= 0 {
+		return defaultDefragCommandTimeout
+	}
+	return etcdClusterOpts.CommandTimeout
+}
+
+// leaderLast orders the nodes so that the current leader comes last, the leader
+// change caused by its defragmentation then happens only once
+func leaderLast(nodes []types.Node) []types.Node {
+	var leader *types.Node
+	ordered := []types.Node{}
+
+	for i, node := range nodes {
+		status, err := queryEtcdEndpointStatus(cmdExecutor.ForNode(node), node)
+		if err == nil && status.Status.Leader == status.Status.Header.MemberId {
+			leader = &nodes[i]
+			continue
+		}
+		ordered = append(ordered, node)
+	}
+
+	if leader == nil {
+		printer.PrintWarn("No leader found, members are defragmented in configured order")
+		return nodes
+	}
+	return append(ordered, *leader)
+}
@@ -0,0 +1,131 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "github.com/bouk/monkey"
+    "os"
+    "strings"
+    "errors"
+)
+
+func defragMemberResponse(memberId string, defragmented *bool) func(command string) (*types.SSHOutput, error) {
+    return func(command string) (*types.SSHOutput, error) {
+        switch {
+        case strings.HasSuffix(command, "endpoint status --write-out=json"):
+            dbSize := "8388608"
+            if *defragmented {
+                dbSize = "1048576"
+            }
+            return &types.SSHOutput{Stdout: `[{"Endpoint":"http://x:2379","Status":{"header":{"member_id":` + memberId +
+                `,"revision":25000},"dbSize":` + dbSize + `,"leader":1}}]`}, nil
+        case strings.HasSuffix(command, " defrag --command-timeout=5m0s"):
+            *defragmented = true
+            return &types.SSHOutput{}, nil
+        default:
+            return &types.SSHOutput{}, nil
+        }
+    }
+}
+
+func TestEtcdDefrag_LeaderLast(t *testing.T) {
+    etcd1, etcd2 := false, false
+    context, outBuffer, commands := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": defragMemberResponse("1", &etcd1),
+        "etcd2": defragMemberResponse("2", &etcd2),
+    })
+
+    EtcdDefrag(context)
+
+    defrags := []string{}
+    for _, command := range *commands {
+        if strings.Contains(command, " defrag") {
+            defrags = append(defrags, command)
+        }
+    }
+    assert.Equal(t, []string{
+        "etcd2: env ETCDCTL_API=3 etcdctl --endpoints=http://10.0.0.2:2379 defrag --command-timeout=5m0s",
+        "etcd1: env ETCDCTL_API=3 etcdctl --endpoints=http://10.0.0.1:2379 defrag --command-timeout=5m0s",
+    }, defrags)
+    assert.Contains(t, outBuffer.String(), "Member etcd1 (10.0.0.1) defragmented: 8.0 MiB -> 1.0 MiB")
+}
+
+func TestEtcdDefrag_AbortsOnUnhealthyMember(t *testing.T) {
+    etcd1 := false
+    context, _, commands := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": defragMemberResponse("1", &etcd1),
+        "etcd2": func(command string) (*types.SSHOutput, error) {
+            if strings.HasSuffix(command, "endpoint health") {
+                return nil, errors.New("unhealthy cluster")
+            }
+            return defragMemberResponse("2", new(bool))(command)
+        },
+    })
+
+    osExitCalled := false
+    patch := monkey.Patch(os.Exit, func(int) {
+        osExitCalled = true
+        panic("exit")
+    })
+    defer patch.Unpatch()
+
+    assert.Panics(t, func() { EtcdDefrag(context) })
+    assert.True(t, osExitCalled)
+    for _, command := range *commands {
+        assert.NotContains(t, command, " defrag", command)
+    }
+}
+
+func TestEtcdDefrag_AbortsWhenOtherMemberIsUnhealthy(t *testing.T) {
+    context, outBuffer, commands := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": func(command string) (*types.SSHOutput, error) {
+            if strings.HasSuffix(command, "endpoint health") {
+                return nil, errors.New("unhealthy cluster")
+            }
+            return defragMemberResponse("1", new(bool))(command)
+        },
+        "etcd2": defragMemberResponse("2", new(bool)),
+    })
+
+    patch := monkey.Patch(os.Exit, func(int) {
+        panic("exit")
+    })
+    defer patch.Unpatch()
+
+    // etcd2 is defragmented first as etcd1 is the leader, but etcd1 being down already must stop it
+    assert.Panics(t, func() { EtcdDefrag(context) })
+    for _, command := range *commands {
+        assert.NotContains(t, command, " defrag", command)
+    }
+    assert.Contains(t, outBuffer.String(), "Cluster is not healthy, aborting defragmentation before etcd2 (10.0.0.2)")
+    assert.Contains(t, outBuffer.String(), "Member etcd1 (10.0.0.1) is not healthy: unhealthy cluster")
+}
+
+func TestEtcdCompact(t *testing.T) {
+    context, outBuffer, commands := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": defragMemberResponse("1", new(bool)),
+        "etcd2": defragMemberResponse("2", new(bool)),
+    })
+    context.Opts.(*types.EtcdClusterOpts).Retention = 10000
+
+    EtcdCompact(context)
+
+    assert.Contains(t, *commands, "etcd1: env ETCDCTL_API=3 etcdctl --endpoints=http://10.0.0.1:2379 compaction 15000")
+    assert.Contains(t, outBuffer.String(), "Compacted keyspace to revision 15000, current revision is 25000")
+}
+
+func TestEtcdCompact_WithinRetention(t *testing.T) {
+    context, outBuffer, commands := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": defragMemberResponse("1", new(bool)),
+        "etcd2": defragMemberResponse("2", new(bool)),
+    })
+    context.Opts.(*types.EtcdClusterOpts).Retention = 30000
+
+    EtcdCompact(context)
+
+    for _, command := range *commands {
+        assert.NotContains(t, command, "compaction")
+    }
+    assert.Contains(t, outBuffer.String(), "nothing to compact")
+}
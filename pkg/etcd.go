@@ -0,0 +1,334 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mrahbar/kubernetes-inspector/ssh"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+var etcdClusterOpts *types.EtcdClusterOpts
+
+var alarmRegex = regexp.MustCompile(`memberID:(\d+)\s+alarm:(\w+)`)
+
+// etcdMemberStatus is the result of querying a single node of the Etcd group
+type etcdMemberStatus struct {
+	node    types.Node
+	healthy bool
+	status  *types.EtcdEndpointStatus
+	err     error
+}
+
+// EtcdHealth prints the state of every member and returns a Nagios compatible exit code like cluster-status
+func EtcdHealth(cmdParams *types.CommandContext) int {
+	nodes := initEtcdCluster(cmdParams)
+
+	members := []etcdMemberStatus{}
+	for _, node := range nodes {
+		members = append(members, queryEtcdMember(node))
+	}
+
+	var leader *types.EtcdEndpointStatus
+	for _, m := range members {
+		if m.status != nil && m.status.Status.Leader == m.status.Status.Header.MemberId {
+			leader = m.status
+		}
+	}
+
+	verdict := types.STATUS_OK
+	alarms, err := queryEtcdMemberAlarms(members)
+	if err != nil {
+		verdict = types.STATUS_UNKNOWN
+		printer.PrintWarn("Could not query alarms: %s", err)
+	}
+
+	header := []string{"NODE", "ENDPOINT", "HEALTHY", "LEADER", "VERSION", "DB SIZE", "RAFT TERM", "RAFT INDEX", "ALARMS"}
+	rows := [][]string{}
+	statuses := []string{}
+	problems := []string{}
+
+	for _, m := range members {
+		label := util.ToNodeLabel(m.node)
+		if m.status == nil {
+			rows = append(rows, []string{label, etcdEndpoint(m.node), "false", "-", "-", "-", "-", "-", "-"})
+			statuses = append(statuses, types.STATUS_ERROR)
+			problems = append(problems, fmt.Sprintf("Member %s is not reachable: %s", label, m.err))
+			continue
+		}
+
+		s := m.status.Status
+		memberAlarms := []string{}
+		for _, alarm := range alarms {
+			if alarm.MemberId == s.Header.MemberId {
+				memberAlarms = append(memberAlarms, alarm.Alarm)
+			}
+		}
+
+		status := types.STATUS_OK
+		if !m.healthy {
+			status = types.STATUS_ERROR
+			problems = append(problems, fmt.Sprintf("Member %s is unhealthy: %s", label, m.err))
+		}
+		if len(memberAlarms) > 0 {
+			status = types.STATUS_ERROR
+			problems = append(problems, fmt.Sprintf("Member %s has alarms: %s", label, strings.Join(memberAlarms, ",")))
+		}
+		if leader != nil && leader.Status.RaftIndex > s.RaftIndex && leader.Status.RaftIndex-s.RaftIndex > etcdClusterOpts.MaxRaftLag {
+			status = worseStatus(status, types.STATUS_WARN)
+			problems = append(problems, fmt.Sprintf("Member %s lags %d raft entries behind the leader",
+				label, leader.Status.RaftIndex-s.RaftIndex))
+		}
+
+		alarmColumn := "-"
+		if len(memberAlarms) > 0 {
+			alarmColumn = strings.Join(memberAlarms, ",")
+		}
+		rows = append(rows, []string{label, m.status.Endpoint, strconv.FormatBool(m.healthy),
+			strconv.FormatBool(s.Leader == s.Header.MemberId), s.Version, formatBytes(s.DbSize),
+			strconv.FormatUint(s.RaftTerm, 10), strconv.FormatUint(s.RaftIndex, 10), alarmColumn})
+		statuses = append(statuses, status)
+	}
+
+	printTable(header, rows, statuses)
+	printer.PrintNewLine()
+
+	for _, status := range statuses {
+		verdict = worseStatus(verdict, status)
+	}
+	if leader == nil {
+		verdict = types.STATUS_ERROR
+		problems = append(problems, "No leader found")
+	}
+	for _, problem := range problems {
+		printer.PrintWarn("%s", problem)
+	}
+	if verdict == types.STATUS_OK {
+		printer.PrintOk("Etcd cluster is healthy")
+	}
+	return exitCode(verdict)
+}
+
+func EtcdMembers(cmdParams *types.CommandContext) {
+	nodes := initEtcdCluster(cmdParams)
+
+	node, executor := firstAccessibleEtcdNode(nodes)
+	sshOut, err := etcdctl(executor, node, "member list --write-out=json")
+	if err != nil {
+		printer.PrintCritical("Error listing members: %s", err)
+	}
+
+	memberList := types.EtcdMemberList{}
+	if err := json.Unmarshal([]byte(sshOut.Stdout), &memberList); err != nil {
+		printer.PrintCritical("Error parsing member list: %s", err)
+	}
+
+	rows := [][]string{}
+	statuses := []string{}
+	for _, member := range memberList.Members {
+		status := types.STATUS_OK
+		name := member.Name
+		// Members which were added but never started have no name yet
+		if name == "" {
+			name = "(unstarted)"
+			status = types.STATUS_WARN
+		}
+		rows = append(rows, []string{fmt.Sprintf("%x", member.ID), name,
+			strings.Join(member.PeerURLs, ","), strings.Join(member.ClientURLs, ",")})
+		statuses = append(statuses, status)
+	}
+
+	printTable([]string{"ID", "NAME", "PEER URLS", "CLIENT URLS"}, rows, statuses)
+}
+
+func EtcdAlarms(cmdParams *types.CommandContext) {
+	nodes := initEtcdCluster(cmdParams)
+
+	alarms, err := queryEtcdAlarms(nodes)
+	if err != nil {
+		printer.PrintCritical("Error listing alarms: %s", err)
+	}
+
+	if len(alarms) == 0 {
+		printer.PrintOk("No alarms")
+		return
+	}
+
+	rows := [][]string{}
+	statuses := []string{}
+	for _, alarm := range alarms {
+		rows = append(rows, []string{fmt.Sprintf("%x", alarm.MemberId), alarm.Alarm})
+		statuses = append(statuses, types.STATUS_ERROR)
+	}
+
+	printTable([]string{"MEMBER", "ALARM"}, rows, statuses)
+}
+
+func initEtcdCluster(cmdParams *types.CommandContext) []types.Node {
+	initParams(cmdParams)
+	etcdClusterOpts = cmdParams.Opts.(*types.EtcdClusterOpts)
+
+	group := util.FindGroupByName(config.ClusterGroups, types.ETCD_GROUPNAME)
+	if group.Nodes == nil || len(group.Nodes) == 0 {
+		printer.PrintCritical("No host configured for group [%s]", types.ETCD_GROUPNAME)
+	}
+
+	return group.Nodes
+}
+
+func firstAccessibleEtcdNode(nodes []types.Node) (types.Node, types.CommandExecutor) {
+	node := ssh.GetFirstAccessibleNode(config.Ssh.LocalOn, cmdExecutor, nodes)
+	if !util.IsNodeAddressValid(node) {
+		printer.PrintCritical("No node of group [%s] accessible", types.ETCD_GROUPNAME)
+	}
+
+	return node, cmdExecutor.ForNode(node)
+}
+
+// etcdNodeOpts resolves endpoint and TLS files of etcd on node. Values given as flags take precedence over the
+// settings of the Etcd group, whose file paths are templated for the node.
+func etcdNodeOpts(opts types.EtcdOpts, clientPort int, node types.Node) types.EtcdOpts {
+	settings := util.FindGroupByName(config.ClusterGroups, types.ETCD_GROUPNAME).Etcd
+
+	resolved := opts
+	resolved.CaFile = parseTemplate(firstNonEmpty(opts.CaFile, settings.CaFile), node)
+	resolved.ClientCertFile = parseTemplate(firstNonEmpty(opts.ClientCertFile, settings.ClientCertFile), node)
+	resolved.ClientKeyFile = parseTemplate(firstNonEmpty(opts.ClientKeyFile, settings.ClientKeyFile), node)
+	resolved.ClientCertAuth = opts.ClientCertAuth || resolved.ClientCertFile != ""
+
+	if resolved.Endpoint == "" {
+		scheme := settings.Scheme
+		if scheme == "" {
+			scheme = "http"
+			if resolved.ClientCertAuth {
+				scheme = "https"
+			}
+		}
+
+		port := clientPort
+		if port == 0 {
+			port = settings.ClientPort
+		}
+		if port == 0 {
+			port = types.ETCD_DEFAULT_CLIENT_PORT
+		}
+		resolved.Endpoint = fmt.Sprintf("%s://%s:%d", scheme, util.GetNodeAddress(node), port)
+	}
+
+	return resolved
+}
+
+func etcdEndpoint(node types.Node) string {
+	return etcdNodeOpts(etcdClusterOpts.EtcdOpts, etcdClusterOpts.ClientPort, node).Endpoint
+}
+
+// etcdTLSArgs returns the etcdctl v3 flags for client certificate authentication, files which are not set are left out
+func etcdTLSArgs(opts types.EtcdOpts) string {
+	if !opts.ClientCertAuth {
+		return ""
+	}
+
+	args := ""
+	for _, arg := range []struct{ flag, file string }{
+		{"cacert", opts.CaFile},
+		{"cert", opts.ClientCertFile},
+		{"key", opts.ClientKeyFile},
+	} {
+		if arg.file != "" {
+			args += fmt.Sprintf(" --%s=%s", arg.flag, arg.file)
+		}
+	}
+	return args
+}
+
+// etcdctl runs etcdctl with api v3 on node against the client endpoint of the node
+func etcdctl(executor types.CommandExecutor, node types.Node, args string) (*types.SSHOutput, error) {
+	opts := etcdNodeOpts(etcdClusterOpts.EtcdOpts, etcdClusterOpts.ClientPort, node)
+	cmd := fmt.Sprintf("env ETCDCTL_API=3 etcdctl --endpoints=%s%s %s", opts.Endpoint, etcdTLSArgs(opts), args)
+	return executor.PerformCmd(cmd, etcdClusterOpts.Sudo)
+}
+
+func queryEtcdMember(node types.Node) etcdMemberStatus {
+	executor := cmdExecutor.ForNode(node)
+	member := etcdMemberStatus{node: node}
+
+	status, err := queryEtcdEndpointStatus(executor, node)
+	if err != nil {
+		member.err = err
+		return member
+	}
+	member.status = status
+
+	_, err = etcdctl(executor, node, "endpoint health")
+	member.healthy = err == nil
+	member.err = err
+
+	return member
+}
+
+func queryEtcdEndpointStatus(executor types.CommandExecutor, node types.Node) (*types.EtcdEndpointStatus, error) {
+	sshOut, err := etcdctl(executor, node, "endpoint status --write-out=json")
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []types.EtcdEndpointStatus{}
+	if err := json.Unmarshal([]byte(sshOut.Stdout), &statuses); err != nil || len(statuses) == 0 {
+		return nil, fmt.Errorf("could not parse endpoint status: %s", sshOut.Stdout)
+	}
+	return &statuses[0], nil
+}
+
+func queryEtcdAlarms(nodes []types.Node) ([]types.EtcdAlarm, error) {
+	node, executor := firstAccessibleEtcdNode(nodes)
+	return listEtcdAlarms(executor, node)
+}
+
+// queryEtcdMemberAlarms lists the alarms on the first member which reported its status
+func queryEtcdMemberAlarms(members []etcdMemberStatus) ([]types.EtcdAlarm, error) {
+	for _, m := range members {
+		if m.status != nil {
+			return listEtcdAlarms(cmdExecutor.ForNode(m.node), m.node)
+		}
+	}
+	return nil, fmt.Errorf("no member of group [%s] is reachable", types.ETCD_GROUPNAME)
+}
+
+func listEtcdAlarms(executor types.CommandExecutor, node types.Node) ([]types.EtcdAlarm, error) {
+	sshOut, err := etcdctl(executor, node, "alarm list")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEtcdAlarms(sshOut.Stdout), nil
+}
+
+func parseEtcdAlarms(output string) []types.EtcdAlarm {
+	alarms := []types.EtcdAlarm{}
+	for _, match := range alarmRegex.FindAllStringSubmatch(output, -1) {
+		memberId, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		alarms = append(alarms, types.EtcdAlarm{MemberId: memberId, Alarm: match[2]})
+	}
+	return alarms
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
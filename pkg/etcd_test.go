@@ -0,0 +1,197 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "strings"
+    "errors"
+    "bytes"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
+)
+
+func etcdClusterContext(responses map[string]func(command string) (*types.SSHOutput, error)) (*types.CommandContext, *bytes.Buffer, *[]string) {
+    mockExecutor, outBuffer, context := defaultContext()
+    context.Config.ClusterGroups = append(context.Config.ClusterGroups, types.ClusterGroup{
+        Name: types.ETCD_GROUPNAME,
+        Nodes: []types.Node{
+            {Host: "etcd1", IP: "10.0.0.1"},
+            {Host: "etcd2", IP: "10.0.0.2"},
+        },
+    })
+    context.Opts = &types.EtcdClusterOpts{ClientPort: 2379, MaxRaftLag: 100}
+
+    commands := &[]string{}
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                *commands = append(*commands, node.Host+": "+command)
+                return responses[node.Host](command)
+            },
+        }
+    }
+
+    return context, outBuffer, commands
+}
+
+func etcdMemberResponse(memberId string, raftIndex string, alarms string) func(command string) (*types.SSHOutput, error) {
+    return func(command string) (*types.SSHOutput, error) {
+        switch {
+        case strings.HasSuffix(command, "endpoint status --write-out=json"):
+            return &types.SSHOutput{Stdout: `[{"Endpoint":"http://x:2379","Status":{"header":{"member_id":` + memberId +
+                `},"version":"3.3.10","dbSize":2097152,"leader":1,"raftIndex":` + raftIndex + `,"raftTerm":4}}]`}, nil
+        case strings.HasSuffix(command, "alarm list"):
+            return &types.SSHOutput{Stdout: alarms}, nil
+        default:
+            return &types.SSHOutput{}, nil
+        }
+    }
+}
+
+func TestEtcdHealth_Healthy(t *testing.T) {
+    context, outBuffer, commands := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": etcdMemberResponse("1", "1000", ""),
+        "etcd2": etcdMemberResponse("2", "995", ""),
+    })
+
+    code := EtcdHealth(context)
+
+    assert.Equal(t, EXIT_OK, code)
+    assert.Contains(t, *commands, "etcd1: env ETCDCTL_API=3 etcdctl --endpoints=http://10.0.0.1:2379 endpoint status --write-out=json")
+    assert.Contains(t, *commands, "etcd2: env ETCDCTL_API=3 etcdctl --endpoints=http://10.0.0.2:2379 endpoint health")
+    out := outBuffer.String()
+    assert.Contains(t, out, "RAFT INDEX")
+    assert.Contains(t, out, "2.0 MiB")
+    assert.Contains(t, out, "Etcd cluster is healthy")
+}
+
+func TestEtcdHealth_LaggingMemberAndAlarm(t *testing.T) {
+    alarms := "memberID:2 alarm:NOSPACE\n"
+    context, outBuffer, _ := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": etcdMemberResponse("1", "1000", alarms),
+        "etcd2": etcdMemberResponse("2", "500", alarms),
+    })
+
+    code := EtcdHealth(context)
+
+    assert.Equal(t, EXIT_CRITICAL, code)
+    out := outBuffer.String()
+    assert.Contains(t, out, "NOSPACE")
+    assert.Contains(t, out, "Member etcd2 (10.0.0.2) lags 500 raft entries behind the leader")
+    assert.NotContains(t, out, "Etcd cluster is healthy")
+}
+
+func TestEtcdHealth_UnreachableMember(t *testing.T) {
+    context, outBuffer, _ := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": etcdMemberResponse("1", "1000", ""),
+        "etcd2": func(string) (*types.SSHOutput, error) { return nil, errors.New("connection refused") },
+    })
+    context.Opts.(*types.EtcdClusterOpts).EtcdOpts = types.EtcdOpts{ClientCertAuth: true, CaFile: "ca.crt", ClientCertFile: "c.crt", ClientKeyFile: "c.key"}
+
+    code := EtcdHealth(context)
+
+    assert.Equal(t, EXIT_CRITICAL, code)
+    out := outBuffer.String()
+    assert.Contains(t, out, "https://10.0.0.2:2379")
+    assert.Contains(t, out, "is not reachable: connection refused")
+}
+
+func TestEtcdHealth_LaggingMemberWarns(t *testing.T) {
+    context, outBuffer, _ := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": etcdMemberResponse("1", "1000", ""),
+        "etcd2": etcdMemberResponse("2", "500", ""),
+    })
+
+    code := EtcdHealth(context)
+
+    assert.Equal(t, EXIT_WARN, code)
+    assert.Contains(t, outBuffer.String(), "lags 500 raft entries behind the leader")
+}
+
+func TestEtcdHealth_NoMemberReachable(t *testing.T) {
+    unreachable := func(string) (*types.SSHOutput, error) { return nil, errors.New("connection refused") }
+    context, outBuffer, commands := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": unreachable,
+        "etcd2": unreachable,
+    })
+
+    code := EtcdHealth(context)
+
+    assert.Equal(t, EXIT_CRITICAL, code)
+    for _, command := range *commands {
+        assert.NotContains(t, command, "alarm list")
+    }
+    out := outBuffer.String()
+    assert.Contains(t, out, "RAFT INDEX")
+    assert.Contains(t, out, "Member etcd1 (10.0.0.1) is not reachable: connection refused")
+    assert.Contains(t, out, "Could not query alarms: no member of group [Etcd] is reachable")
+    assert.Contains(t, out, "No leader found")
+}
+
+func TestEtcdMembers(t *testing.T) {
+    members := `{"members":[{"ID":11259375,"name":"etcd1","peerURLs":["https://10.0.0.1:2380"],"clientURLs":["https://10.0.0.1:2379"]},` +
+        `{"ID":2,"peerURLs":["https://10.0.0.2:2380"]}]}`
+    response := func(string) (*types.SSHOutput, error) { return &types.SSHOutput{Stdout: members}, nil }
+    context, outBuffer, _ := etcdClusterContext(map[string]func(string) (*types.SSHOutput, error){
+        "etcd1": response,
+        "etcd2": response,
+    })
+
+    EtcdMembers(context)
+
+    out := outBuffer.String()
+    assert.Contains(t, out, "abcdef")
+    assert.Contains(t, out, "https://10.0.0.1:2380")
+    assert.Contains(t, out, "(unstarted)")
+}
+
+func TestParseEtcdAlarms(t *testing.T) {
+    alarms := parseEtcdAlarms("memberID:13803658152347727308 alarm:NOSPACE\nmemberID:2 alarm:CORRUPT\n")
+
+    assert.Equal(t, []types.EtcdAlarm{
+        {MemberId: 13803658152347727308, Alarm: "NOSPACE"},
+        {MemberId: 2, Alarm: "CORRUPT"},
+    }, alarms)
+}
+
+func TestEtcdNodeOpts_FromGroupSettings(t *testing.T) {
+    context, _, commands := etcdClusterContext(map[string]func(command string) (*types.SSHOutput, error){
+        "etcd1": etcdMemberResponse("1", "10", ""),
+        "etcd2": etcdMemberResponse("2", "10", ""),
+    })
+    context.Config.ClusterGroups[len(context.Config.ClusterGroups)-1].Etcd = types.EtcdSettings{
+        ClientPort:     4001,
+        CaFile:         "/etc/etcd/ca.crt",
+        ClientCertFile: "/etc/etcd/{{.Host}}.crt",
+        ClientKeyFile:  "/etc/etcd/{{.Host}}.key",
+    }
+    context.Opts = &types.EtcdClusterOpts{MaxRaftLag: 100}
+
+    nodes := initEtcdCluster(context)
+    etcdctl(cmdExecutor.ForNode(nodes[0]), nodes[0], "member list --write-out=json")
+
+    assert.Equal(t, "etcd1: env ETCDCTL_API=3 etcdctl --endpoints=https://10.0.0.1:4001 "+
+        "--cacert=/etc/etcd/ca.crt --cert=/etc/etcd/etcd1.crt --key=/etc/etcd/etcd1.key member list --write-out=json", (*commands)[0])
+}
+
+func TestEtcdNodeOpts_FlagsTakePrecedence(t *testing.T) {
+    _, _, context := defaultContext()
+    context.Config.ClusterGroups = append(context.Config.ClusterGroups, types.ClusterGroup{
+        Name:  types.ETCD_GROUPNAME,
+        Nodes: []types.Node{{Host: "etcd1", IP: "10.0.0.1"}},
+        Etcd:  types.EtcdSettings{Scheme: "https", ClientPort: 4001, ClientCertFile: "/etc/etcd/{{.Host}}.crt"},
+    })
+    initParams(context)
+    node := types.Node{Host: "etcd1", IP: "10.0.0.1"}
+
+    opts := etcdNodeOpts(types.EtcdOpts{ClientCertFile: "/tmp/client.crt"}, 2379, node)
+    assert.Equal(t, "https://10.0.0.1:2379", opts.Endpoint)
+    assert.Equal(t, "/tmp/client.crt", opts.ClientCertFile)
+    assert.True(t, opts.ClientCertAuth)
+    assert.Equal(t, " --cert=/tmp/client.crt", etcdTLSArgs(opts))
+
+    opts = etcdNodeOpts(types.EtcdOpts{Endpoint: "http://127.0.0.1:2379"}, 0, node)
+    assert.Equal(t, "http://127.0.0.1:2379", opts.Endpoint)
+    assert.Equal(t, "/etc/etcd/etcd1.crt", opts.ClientCertFile)
+}
@@ -3,7 +3,7 @@ package pkg
 import (
 	"fmt"
 
-	"github.com/mrahbar/kubernetes-inspector/ssh"
+	"github.com/mrahbar/kubernetes-inspector/integration"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/mrahbar/kubernetes-inspector/util"
 )
@@ -16,7 +16,7 @@ func Exec(cmdParams *types.CommandContext) {
     runGeneric(cmdParams.Config, &execOpts.GenericOpts, initializeExec, exec)
 }
 
-func initializeExec(target string, node string) {
+func initializeExec(target string, node string, printer integration.LogWriter) {
 	printer.PrintHeader(fmt.Sprintf("Executing %v on node %s:\n", target, node), '=')
 
 	if execOpts.FileOutput != "" {
@@ -30,27 +30,6 @@ func initializeExec(target string, node string) {
     printer.PrintNewLine()
 }
 
-func exec(command string) {
-    sshOut, err := cmdExecutor.PerformCmd(command, execOpts.Sudo)
-
-    printer.Print(fmt.Sprintf("Result on node %s:", util.ToNodeLabel(cmdExecutor.GetNode())))
-	if err != nil {
-        printer.PrintErr("Error executing command: %s", err)
-	} else {
-		result := ssh.CombineOutput(sshOut)
-		if execOpts.FileOutput != "" {
-			out := fmt.Sprintf("Result of '%s' on node %s:\n\n%s\n\n", command, util.ToNodeLabel(cmdExecutor.GetNode()), result)
-			err := util.WriteOutputFile(execOpts.FileOutput, out)
-			if err != nil {
-                printer.PrintWarn("Failed to write to output file %s forwarding to screen: %s", execOpts.FileOutput, err)
-                printer.PrintOk(result)
-			} else {
-                printer.PrintOk("Result written to file")
-			}
-		} else {
-            printer.PrintOk(result)
-		}
-	}
-
-    printer.PrintNewLine()
+func exec(command string, executor types.CommandExecutor, printer integration.LogWriter) {
+    printResult(nodeSource(executor.GetNode()), command, execOpts.Sudo, execOpts.Buffered, execOpts.FileOutput, executor, printer)
 }
@@ -2,12 +2,18 @@ package pkg
 
 import (
     "testing"
+    "github.com/mrahbar/kubernetes-inspector/integration"
     "github.com/mrahbar/kubernetes-inspector/types"
     "github.com/stretchr/testify/assert"
     "github.com/bouk/monkey"
     "os"
     "fmt"
     "strings"
+    "time"
+    "io"
+    "context"
+    "io/ioutil"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
 )
 
 func TestExec_CommandMissing(t *testing.T) {
@@ -101,3 +107,231 @@ func TestExec_OrderHosts(t *testing.T) {
     assert.NotEmpty(t, out)
     assert.True(t, strings.Index(out, "host1") < strings.Index(out, "host3"))
 }
+
+func TestExec_ParallelOrderHosts(t *testing.T) {
+    mockExecutor, outBuffer, context := defaultContext()
+    context.Opts = &types.ExecOpts{
+        GenericOpts: types.GenericOpts {
+            TargetArg: "pwd",
+            NodeArg: "host3,host1,host2",
+            Parallel: 3,
+        },
+    }
+
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                if node.Host == "host1" {
+                    time.Sleep(50 * time.Millisecond)
+                }
+                return &types.SSHOutput{Stdout: "result-" + node.Host}, nil
+            },
+        }
+    }
+
+    Exec(context)
+
+    out := outBuffer.String()
+    assert.Contains(t, out, "result-host1")
+    assert.Contains(t, out, "result-host2")
+    assert.Contains(t, out, "result-host3")
+    assert.True(t, strings.Index(out, "result-host1") < strings.Index(out, "result-host2"))
+    assert.True(t, strings.Index(out, "result-host2") < strings.Index(out, "result-host3"))
+}
+
+func TestExec_ParallelExecutorPrintsToNodeOutput(t *testing.T) {
+    mockExecutor, outBuffer, context := defaultContext()
+    context.Opts = &types.ExecOpts{
+        GenericOpts: types.GenericOpts {
+            TargetArg: "pwd",
+            NodeArg: "host3,host1,host2",
+            Parallel: 3,
+        },
+    }
+
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockWithPrinter: func(printer integration.LogWriter) types.CommandExecutor {
+                return &sshTest.MockExecutor{
+                    Node: node,
+                    MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                        printer.PrintDebug("executor-" + node.Host)
+                        if node.Host == "host1" {
+                            time.Sleep(50 * time.Millisecond)
+                        }
+                        return &types.SSHOutput{Stdout: "result-" + node.Host}, nil
+                    },
+                }
+            },
+        }
+    }
+
+    Exec(context)
+
+    // messages of the executor are part of the block of its node
+    out := outBuffer.String()
+    for _, host := range []string{"host1", "host2", "host3"} {
+        assert.Contains(t, out, "executor-"+host)
+        assert.True(t, strings.Index(out, "executor-"+host) < strings.Index(out, "result-"+host), host)
+    }
+    assert.True(t, strings.Index(out, "result-host1") < strings.Index(out, "executor-host2"))
+    assert.True(t, strings.Index(out, "result-host2") < strings.Index(out, "executor-host3"))
+}
+
+func TestExec_Streaming(t *testing.T) {
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.ExecOpts{
+        GenericOpts: types.GenericOpts {
+            TargetArg: "apt-get upgrade",
+            NodeArg: "host1",
+        },
+    }
+
+    lines := []string{}
+    mockExecutor.MockPerformCmdStreaming = func(ctx context.Context, command string, sudo bool, stdout io.Writer, stderr io.Writer) (*types.SSHOutput, error) {
+        // every chunk is printed as soon as it completes a line
+        fmt.Fprint(stdout, "Reading package ")
+        assert.NotContains(t, outBuffer.String(), "Reading package")
+        fmt.Fprint(stdout, "lists...\r\nBuilding")
+        lines = append(lines, outBuffer.String())
+        fmt.Fprint(stderr, "W: repository not signed\n")
+        fmt.Fprint(stdout, " dependency tree")
+        return &types.SSHOutput{}, nil
+    }
+
+    Exec(cmdContext)
+
+    out := outBuffer.String()
+    assert.Contains(t, lines[0], "host1 (3) | Reading package lists...")
+    assert.NotContains(t, lines[0], "Building")
+    assert.Contains(t, out, "host1 (3) | W: repository not signed")
+    assert.Contains(t, out, "host1 (3) | Building dependency tree")
+    assert.True(t, strings.Index(out, "not signed") < strings.Index(out, "dependency tree"))
+    assert.NotContains(t, out, "Error executing command")
+}
+
+func TestExec_StreamingFailed(t *testing.T) {
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.ExecOpts{
+        GenericOpts: types.GenericOpts {
+            TargetArg: "false",
+            NodeArg: "host1",
+        },
+    }
+
+    mockExecutor.MockPerformCmdStreaming = func(ctx context.Context, command string, sudo bool, stdout io.Writer, stderr io.Writer) (*types.SSHOutput, error) {
+        fmt.Fprint(stderr, "permission denied")
+        return &types.SSHOutput{ExitStatus: 1}, fmt.Errorf("command exited with status 1")
+    }
+
+    Exec(cmdContext)
+
+    out := outBuffer.String()
+    assert.Contains(t, out, "host1 (3) | permission denied")
+    assert.Contains(t, out, "Error executing command: command exited with status 1")
+}
+
+func TestExec_Buffered(t *testing.T) {
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.ExecOpts{
+        Buffered: true,
+        GenericOpts: types.GenericOpts {
+            TargetArg: "pwd",
+            NodeArg: "host1",
+        },
+    }
+
+    mockExecutor.MockPerformCmdStreaming = func(ctx context.Context, command string, sudo bool, stdout io.Writer, stderr io.Writer) (*types.SSHOutput, error) {
+        t.Fatal("buffered output must not stream")
+        return nil, nil
+    }
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        return &types.SSHOutput{Stdout: "/root"}, nil
+    }
+
+    Exec(cmdContext)
+
+    assert.Contains(t, outBuffer.String(), "Stdout: /root")
+}
+
+func TestExec_ParallelBufferedFileOrder(t *testing.T) {
+    mockExecutor, _, cmdContext := defaultContext()
+    file, err := ioutil.TempFile("", "exec-output")
+    assert.Nil(t, err)
+    file.Close()
+    defer os.Remove(file.Name())
+
+    cmdContext.Opts = &types.ExecOpts{
+        Buffered: true,
+        FileOutput: file.Name(),
+        GenericOpts: types.GenericOpts {
+            TargetArg: "pwd",
+            NodeArg: "host3,host1,host2",
+            Parallel: 3,
+        },
+    }
+
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                if node.Host == "host1" {
+                    time.Sleep(50 * time.Millisecond)
+                }
+                return &types.SSHOutput{Stdout: "result-" + node.Host}, nil
+            },
+        }
+    }
+
+    Exec(cmdContext)
+
+    content, err := ioutil.ReadFile(file.Name())
+    assert.Nil(t, err)
+    out := string(content)
+    assert.True(t, strings.Index(out, "result-host1") < strings.Index(out, "result-host2"))
+    assert.True(t, strings.Index(out, "result-host2") < strings.Index(out, "result-host3"))
+}
+
+func TestExec_ParallelStreamingFileOrder(t *testing.T) {
+    mockExecutor, _, cmdContext := defaultContext()
+    file, err := ioutil.TempFile("", "exec-output")
+    assert.Nil(t, err)
+    file.Close()
+    defer os.Remove(file.Name())
+
+    cmdContext.Opts = &types.ExecOpts{
+        FileOutput: file.Name(),
+        GenericOpts: types.GenericOpts {
+            TargetArg: "journalctl",
+            NodeArg: "host3,host1,host2",
+            Parallel: 3,
+        },
+    }
+
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmdStreaming: func(ctx context.Context, command string, sudo bool, stdout io.Writer, stderr io.Writer) (*types.SSHOutput, error) {
+                // the lines of the nodes are written at the same time
+                for i := 0; i < 5; i++ {
+                    fmt.Fprintf(stdout, "line %d of %s\n", i, node.Host)
+                    time.Sleep(5 * time.Millisecond)
+                }
+                return &types.SSHOutput{}, nil
+            },
+        }
+    }
+
+    Exec(cmdContext)
+
+    content, err := ioutil.ReadFile(file.Name())
+    assert.Nil(t, err)
+    out := string(content)
+    for _, host := range []string{"host1", "host2", "host3"} {
+        assert.Contains(t, out, "line 0 of "+host+"\nline 1 of "+host+"\nline 2 of "+host+"\nline 3 of "+host+"\nline 4 of "+host+"\n")
+    }
+    assert.True(t, strings.Index(out, "of host1") < strings.Index(out, "of host2"))
+    assert.True(t, strings.Index(out, "of host2") < strings.Index(out, "of host3"))
+}
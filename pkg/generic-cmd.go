@@ -1,16 +1,24 @@
 package pkg
 
 import (
+	"github.com/mrahbar/kubernetes-inspector/integration"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/mrahbar/kubernetes-inspector/util"
 	"strings"
 	"sort"
 )
 
-type Initializer func(target string, node string)
-type Processor func(target string)
+// Initializer and Processor are called once per node. They must only use the passed executor and printer
+// since nodes might be processed concurrently.
+type Initializer func(target string, node string, printer integration.LogWriter)
+type Processor func(target string, executor types.CommandExecutor, printer integration.LogWriter)
 
 func runGeneric(config types.Config, opts *types.GenericOpts, initializer Initializer, processor Processor) {
+	processNodes(selectNodes(config, opts), opts.Parallel, opts.TargetArg, initializer, processor)
+}
+
+// selectNodes returns the nodes selected by name or group, sorted by host
+func selectNodes(config types.Config, opts *types.GenericOpts) []types.Node {
 	if opts.TargetArg == "" {
 		printer.PrintCritical("Invalid options. Parameter missing.")
 	}
@@ -57,14 +65,52 @@ func runGeneric(config types.Config, opts *types.GenericOpts, initializer Initia
 
 	if len(totalNodes) == 0 {
 		printer.PrintCritical("No node in current selection")
-	} else {
-		sort.Slice(totalNodes, func(i, j int) bool {
-			return totalNodes[i].Host < totalNodes[j].Host
-		})
-		for _, node := range totalNodes {// TODO maybe paralle loop http://www.golangpatterns.info/concurrency/parallel-for-loop
-			initializer(opts.TargetArg, util.ToNodeLabel(node))
-			cmdExecutor.SetNode(node)
-			processor(opts.TargetArg)
+	}
+
+	sort.Slice(totalNodes, func(i, j int) bool {
+		return totalNodes[i].Host < totalNodes[j].Host
+	})
+	return totalNodes
+}
+
+func processNodes(nodes []types.Node, parallel int, target string, initializer Initializer, processor Processor) {
+	if parallel <= 1 {
+		for _, node := range nodes {
+			initializer(target, util.ToNodeLabel(node), printer)
+			processor(target, cmdExecutor.ForNode(node), printer)
+		}
+		return
+	}
+
+	// Every node writes into its own buffer which is flushed in host order as soon as
+	// the node and all of its predecessors are done.
+	outputs := make([]*integration.BufferedLogWriter, len(nodes))
+	done := make([]chan struct{}, len(nodes))
+	for i := range nodes {
+		outputs[i] = integration.NewBufferedLogWriter(printer)
+		done[i] = make(chan struct{})
+	}
+
+	queue := make(chan int)
+	go func() {
+		for i := range nodes {
+			queue <- i
 		}
+		close(queue)
+	}()
+
+	for w := 0; w < parallel && w < len(nodes); w++ {
+		go func() {
+			for i := range queue {
+				initializer(target, util.ToNodeLabel(nodes[i]), outputs[i])
+				processor(target, cmdExecutor.ForNode(nodes[i]).WithPrinter(outputs[i]), outputs[i])
+				close(done[i])
+			}
+		}()
+	}
+
+	for i := range nodes {
+		<-done[i]
+		outputs[i].Flush()
 	}
 }
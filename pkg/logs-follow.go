@@ -0,0 +1,264 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/integration"
+	"github.com/mrahbar/kubernetes-inspector/types"
+)
+
+// followReorderWindow is how long lines are held back to be merged in order of their timestamps
+var followReorderWindow = 500 * time.Millisecond
+
+// followColors tell the nodes apart in the merged stream
+var followColors = []func(a ...interface{}) string{
+	integration.Blue.SprintFunc(),
+	integration.Green.SprintFunc(),
+	integration.Magenta.SprintFunc(),
+	integration.Yellow.SprintFunc(),
+	integration.White.SprintFunc(),
+}
+
+var klogTimestampRegex = regexp.MustCompile(`^[IWEF](\d{4} \d{2}:\d{2}:\d{2}\.\d{6})`)
+
+// followTarget is one of the followed streams, like the logs of a service on a node or of a pod
+type followTarget struct {
+	label    string
+	executor types.CommandExecutor
+	command  string
+	// file receives the lines of the target instead of the common output
+	file string
+}
+
+type followSource struct {
+	label string
+	color func(a ...interface{}) string
+	file  *os.File
+	last  time.Time
+}
+
+type followLine struct {
+	source  *followSource
+	text    string
+	stderr  bool
+	time    time.Time
+	arrival time.Time
+	seq     uint64
+}
+
+// logMerger merges the lines of several nodes into one stream. Lines are held back for the reorder window and
+// emitted in order of their timestamps, a line without timestamp keeps the time of the previous line of its node.
+type logMerger struct {
+	mu      sync.Mutex
+	window  time.Duration
+	now     func() time.Time
+	emit    func(line followLine)
+	pending []followLine
+	seq     uint64
+}
+
+func (m *logMerger) add(source *followSource, text string, stderr bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	t, ok := parseLogTimestamp(text, now)
+	if ok {
+		source.last = t
+	} else if !source.last.IsZero() {
+		t = source.last
+	} else {
+		t = now
+	}
+
+	m.seq++
+	m.pending = append(m.pending, followLine{source: source, text: text, stderr: stderr, time: t, arrival: now, seq: m.seq})
+}
+
+// flush emits every line which was held back for the reorder window together with all lines of an earlier
+// timestamp. With all set every pending line is emitted.
+func (m *logMerger) flush(all bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sort.Slice(m.pending, func(i, j int) bool {
+		if m.pending[i].time.Equal(m.pending[j].time) {
+			return m.pending[i].seq < m.pending[j].seq
+		}
+		return m.pending[i].time.Before(m.pending[j].time)
+	})
+
+	ripe := len(m.pending)
+	if !all {
+		deadline := m.now().Add(-m.window)
+		ripe = 0
+		for i, line := range m.pending {
+			if !line.arrival.After(deadline) {
+				ripe = i + 1
+			}
+		}
+	}
+
+	for _, line := range m.pending[:ripe] {
+		m.emit(line)
+	}
+	m.pending = append([]followLine{}, m.pending[ripe:]...)
+}
+
+// print runs f between emitted lines
+func (m *logMerger) print(f func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f()
+}
+
+// parseLogTimestamp parses the leading timestamp of RFC 3339 (docker, kubectl, journalctl short-iso),
+// syslog (journalctl) and klog lines. The latter two lack the year which is taken from now.
+func parseLogTimestamp(line string, now time.Time) (time.Time, bool) {
+	field := line
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		field = line[:i]
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05Z0700"} {
+		if t, err := time.Parse(layout, field); err == nil {
+			return t, true
+		}
+	}
+
+	if len(line) >= len(time.Stamp) {
+		if t, err := time.ParseInLocation(time.Stamp, line[:len(time.Stamp)], now.Location()); err == nil {
+			return withYear(t, now), true
+		}
+	}
+
+	if match := klogTimestampRegex.FindStringSubmatch(line); match != nil {
+		if t, err := time.ParseInLocation("0102 15:04:05.000000", match[1], now.Location()); err == nil {
+			return withYear(t, now), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// withYear sets the year of now, timestamps which would lie more than a day ahead belong to the previous year
+func withYear(t time.Time, now time.Time) time.Time {
+	t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), now.Location())
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// followLogs runs the commands of all targets concurrently and prints their lines as one stream
+// until every command ended or the program is interrupted, which closes all sessions.
+func followLogs(targets []followTarget, title string) {
+	var file *os.File
+	if logOpts.FileOutput != "" && !isDirectory(logOpts.FileOutput) {
+		f, err := os.OpenFile(logOpts.FileOutput, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+		if err != nil {
+			printer.PrintCritical("Failed to open output file %s: %s", logOpts.FileOutput, err)
+		}
+		file = f
+		defer file.Close()
+	}
+
+	width := 0
+	sources := []*followSource{}
+	for i, target := range targets {
+		if len(target.label) > width {
+			width = len(target.label)
+		}
+
+		source := &followSource{label: target.label, color: followColors[i%len(followColors)]}
+		if target.file != "" {
+			f, err := os.Create(target.file)
+			if err != nil {
+				printer.PrintCritical("Failed to open output file %s: %s", target.file, err)
+			}
+			source.file = f
+			defer f.Close()
+		}
+		sources = append(sources, source)
+	}
+
+	merger := &logMerger{
+		window: followReorderWindow,
+		now:    time.Now,
+		emit: func(line followLine) {
+			label := fmt.Sprintf("%-*s |", width, line.source.label)
+			if line.source.file != nil {
+				fmt.Fprintln(line.source.file, line.text)
+			} else if file != nil {
+				fmt.Fprintf(file, "%s %s\n", label, line.text)
+			} else if line.stderr {
+				printer.PrintWarn("%s %s", line.source.color(label), line.text)
+			} else {
+				printer.Print("%s %s", line.source.color(label), line.text)
+			}
+		},
+	}
+
+	printer.PrintHeader(fmt.Sprintf("Following logs for %s, press Ctrl-C to stop", title), '=')
+	if file != nil {
+		printer.PrintInfo("Logs are written to file %s screen output is suppressed.", logOpts.FileOutput)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(target followTarget, source *followSource) {
+			defer wg.Done()
+
+			mu := &sync.Mutex{}
+			stdout := &lineWriter{mu: mu, print: func(line string) { merger.add(source, line, false) }}
+			stderr := &lineWriter{mu: mu, print: func(line string) { merger.add(source, line, true) }}
+
+			_, err := target.executor.PerformCmdStreaming(ctx, target.command, logOpts.Sudo, stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+			if err != nil && ctx.Err() == nil {
+				merger.print(func() { printer.PrintErr("Following logs of %s failed: %s", source.label, err) })
+			}
+		}(target, sources[i])
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	ticker := time.NewTicker(merger.window / 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			merger.flush(false)
+		case <-interrupt:
+			merger.print(func() { printer.PrintInfo("Interrupted, closing sessions") })
+			cancel()
+			<-finished
+			merger.flush(true)
+			return
+		case <-finished:
+			merger.flush(true)
+			return
+		}
+	}
+}
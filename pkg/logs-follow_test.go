@@ -0,0 +1,154 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "context"
+    "fmt"
+    "io"
+    "strings"
+    "syscall"
+    "time"
+    "os"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
+)
+
+func TestParseLogTimestamp(t *testing.T) {
+    now := time.Date(2018, 3, 20, 12, 0, 0, 0, time.UTC)
+
+    for line, expected := range map[string]time.Time{
+        "2018-03-20T11:59:58.123456789Z I0320 proxier.go:100] syncing": time.Date(2018, 3, 20, 11, 59, 58, 123456789, time.UTC),
+        "2018-03-20T12:59:58+0100 node1 kubelet[123]: started":          time.Date(2018, 3, 20, 11, 59, 58, 0, time.UTC),
+        "Mar 20 11:59:58 node1 kubelet[123]: started":                   time.Date(2018, 3, 20, 11, 59, 58, 0, time.UTC),
+        "Dec 31 23:59:59 node1 kubelet[123]: started":                   time.Date(2017, 12, 31, 23, 59, 59, 0, time.UTC),
+        "E0320 11:59:58.000100   1 reflector.go:205] failed":             time.Date(2018, 3, 20, 11, 59, 58, 100000, time.UTC),
+    } {
+        parsed, ok := parseLogTimestamp(line, now)
+        assert.True(t, ok, line)
+        assert.True(t, expected.Equal(parsed), "%s: %s", line, parsed)
+    }
+
+    _, ok := parseLogTimestamp("    at main.go:12", now)
+    assert.False(t, ok)
+}
+
+func TestLogMerger_OrdersByTimestamp(t *testing.T) {
+    now := time.Date(2018, 3, 20, 12, 0, 0, 0, time.UTC)
+    emitted := []string{}
+    merger := &logMerger{
+        window: time.Second,
+        now:    func() time.Time { return now },
+        emit:   func(line followLine) { emitted = append(emitted, line.source.label+" "+line.text) },
+    }
+    node1 := &followSource{label: "node1"}
+    node2 := &followSource{label: "node2"}
+
+    merger.add(node1, "2018-03-20T11:59:50Z panic", false)
+    merger.add(node1, "    at main.go:12", false)
+    merger.add(node2, "2018-03-20T11:59:40Z started", false)
+    now = now.Add(500 * time.Millisecond)
+    merger.add(node2, "2018-03-20T11:59:45Z ready", false)
+
+    merger.flush(false)
+    assert.Empty(t, emitted)
+
+    // the first two lines are ripe, the older line of node2 which is still held back precedes them
+    now = now.Add(600 * time.Millisecond)
+    merger.flush(false)
+    assert.Equal(t, []string{
+        "node2 2018-03-20T11:59:40Z started",
+        "node2 2018-03-20T11:59:45Z ready",
+        "node1 2018-03-20T11:59:50Z panic",
+        "node1     at main.go:12",
+    }, emitted)
+
+    merger.add(node2, "2018-03-20T11:59:30Z late", false)
+    merger.flush(true)
+    assert.Equal(t, "node2 2018-03-20T11:59:30Z late", emitted[4])
+}
+
+func TestLogs_Follow(t *testing.T) {
+    window := followReorderWindow
+    followReorderWindow = 10 * time.Millisecond
+    defer func() { followReorderWindow = window }()
+
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.LogsOpts{
+        Type: "container",
+        Tail: 10,
+        Follow: true,
+        GenericOpts: types.GenericOpts{
+            NodeArg: "host1,host2",
+            TargetArg: "kube-proxy",
+        },
+    }
+
+    commands := make(chan string, 2)
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmdStreaming: func(ctx context.Context, command string, sudo bool, stdout io.Writer, stderr io.Writer) (*types.SSHOutput, error) {
+                commands <- command
+                if node.Host == "host1" {
+                    fmt.Fprint(stdout, "2018-03-20T12:00:02Z second\n2018-03-20T12:00:04Z fourth\n")
+                    return &types.SSHOutput{}, nil
+                }
+                fmt.Fprint(stdout, "2018-03-20T12:00:01Z first\n2018-03-20T12:00:03Z third\n")
+                return &types.SSHOutput{ExitStatus: 1}, fmt.Errorf("command exited with status 1")
+            },
+        }
+    }
+
+    Logs(cmdContext)
+
+    assert.Equal(t, "docker logs --tail 10 --follow --timestamps kube-proxy", <-commands)
+    out := outBuffer.String()
+    first := strings.Index(out, "first")
+    second := strings.Index(out, "second")
+    third := strings.Index(out, "third")
+    fourth := strings.Index(out, "fourth")
+    assert.True(t, first >= 0 && first < second && second < third && third < fourth, out)
+    assert.Contains(t, out, "host2 (1) | 2018-03-20T12:00:01Z first")
+    assert.Contains(t, out, "Following logs of host2 (1) failed: command exited with status 1")
+}
+
+func TestLogs_FollowInterrupted(t *testing.T) {
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.LogsOpts{
+        Type: "service",
+        Follow: true,
+        GenericOpts: types.GenericOpts{
+            NodeArg: "host1,host2",
+            TargetArg: "kubelet",
+        },
+    }
+
+    started := make(chan struct{}, 2)
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmdStreaming: func(ctx context.Context, command string, sudo bool, stdout io.Writer, stderr io.Writer) (*types.SSHOutput, error) {
+                assert.Equal(t, "journalctl --follow --unit=kubelet", command)
+                fmt.Fprint(stdout, "Mar 20 12:00:00 "+node.Host+" kubelet[1]: running")
+                started <- struct{}{}
+                <-ctx.Done()
+                return &types.SSHOutput{}, ctx.Err()
+            },
+        }
+    }
+
+    go func() {
+        <-started
+        <-started
+        syscall.Kill(os.Getpid(), syscall.SIGINT)
+    }()
+
+    Logs(cmdContext)
+
+    out := outBuffer.String()
+    assert.Contains(t, out, "Interrupted, closing sessions")
+    assert.Contains(t, out, "host1 kubelet[1]: running")
+    assert.Contains(t, out, "host2 kubelet[1]: running")
+    assert.NotContains(t, out, "failed")
+}
@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mrahbar/kubernetes-inspector/ssh"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+// podLogs retrieves the logs of the pod given as element or of all pods matching the selector.
+// kubectl runs once on the first accessible master, the group and node selection does not apply to pods.
+func podLogs() {
+	if logOpts.TargetArg == "" && logOpts.Selector == "" {
+		printer.PrintCritical("Either a pod as element or a selector is required")
+	}
+	if logOpts.TargetArg != "" && logOpts.Selector != "" {
+		printer.PrintCritical("A pod as element and a selector are mutually exclusive")
+	}
+	if logOpts.Container != "" && logOpts.AllContainers {
+		printer.PrintCritical("A container and all containers are mutually exclusive")
+	}
+	if logOpts.Namespace == "" {
+		logOpts.Namespace = "default"
+	}
+
+	group := util.FindGroupByName(config.ClusterGroups, types.MASTER_GROUPNAME)
+	if group.Nodes == nil || len(group.Nodes) == 0 {
+		printer.PrintCritical("No host configured for group [%s]", types.MASTER_GROUPNAME)
+	}
+
+	node := ssh.GetFirstAccessibleNode(config.Ssh.LocalOn, cmdExecutor, group.Nodes)
+	if !util.IsNodeAddressValid(node) {
+		printer.PrintCritical("No master available")
+	}
+	executor := cmdExecutor.ForNode(node)
+
+	pods := []string{logOpts.TargetArg}
+	if logOpts.Selector != "" {
+		pods = selectPods(executor)
+		if len(pods) == 0 {
+			printer.PrintInfo("No pod in namespace %s matches selector %s", logOpts.Namespace, logOpts.Selector)
+			return
+		}
+	}
+
+	dir := ""
+	if logOpts.FileOutput != "" && isDirectory(logOpts.FileOutput) {
+		dir = logOpts.FileOutput
+	}
+
+	if logOpts.Follow {
+		targets := []followTarget{}
+		for _, pod := range pods {
+			target := followTarget{label: pod, executor: executor, command: podLogsCommand(pod)}
+			if dir != "" {
+				target.file = podLogFile(dir, pod)
+			}
+			targets = append(targets, target)
+		}
+		followLogs(targets, fmt.Sprintf("%d pods in namespace %s", len(pods), logOpts.Namespace))
+		return
+	}
+
+	printer.PrintHeader(fmt.Sprintf("Retrieving logs of %d pods in namespace %s on node %s:\n",
+		len(pods), logOpts.Namespace, util.ToNodeLabel(node)), '=')
+	if dir != "" {
+		printer.PrintInfo("Logs are written to one file per pod in %s screen output is suppressed.", dir)
+	} else if logOpts.FileOutput != "" {
+		if err := util.InitializeOutputFile(logOpts.FileOutput); err != nil {
+			printer.PrintCritical("Failed to open output file %s: %s", logOpts.FileOutput, err)
+		}
+		printer.PrintInfo("Result is written to file %s screen output is suppressed.", logOpts.FileOutput)
+	}
+	printer.PrintNewLine()
+
+	for _, pod := range pods {
+		fileOutput := logOpts.FileOutput
+		if dir != "" {
+			fileOutput = podLogFile(dir, pod)
+			if err := ioutil.WriteFile(fileOutput, []byte{}, 0666); err != nil {
+				printer.PrintWarn("Failed to create output file %s: %s", fileOutput, err)
+			}
+		}
+
+		source := resultSource{kind: "pod", name: logOpts.Namespace + "/" + pod}
+		printResult(source, podLogsCommand(pod), logOpts.Sudo, logOpts.Buffered, fileOutput, executor, printer)
+	}
+}
+
+// selectPods returns the names of the pods in the namespace matching the selector
+func selectPods(executor types.CommandExecutor) []string {
+	command := fmt.Sprintf("kubectl get pods --namespace=%s --selector='%s' --output=jsonpath='{.items[*].metadata.name}'",
+		logOpts.Namespace, logOpts.Selector)
+	sshOut, err := executor.PerformCmd(command, logOpts.Sudo)
+	if err != nil {
+		printer.PrintCritical("Error listing pods matching selector %s: %s", logOpts.Selector, err)
+	}
+
+	return strings.Fields(sshOut.Stdout)
+}
+
+// podLogsCommand returns the kubectl command retrieving the logs of pod. When following, timestamps are
+// requested so that the lines of several pods can be merged in order.
+func podLogsCommand(pod string) string {
+	command := []string{"kubectl logs", fmt.Sprintf("--namespace=%s", logOpts.Namespace)}
+	if logOpts.Container != "" {
+		command = append(command, fmt.Sprintf("--container=%s", logOpts.Container))
+	}
+	if logOpts.AllContainers {
+		command = append(command, "--all-containers=true")
+	}
+	if logOpts.Previous {
+		command = append(command, "--previous")
+	}
+	if logOpts.Tail > 0 {
+		command = append(command, fmt.Sprintf("--tail=%d", logOpts.Tail))
+	}
+	if logOpts.Since != "" {
+		command = append(command, fmt.Sprintf("--since=%s", logOpts.Since))
+	}
+	if logOpts.Follow {
+		command = append(command, "--follow --timestamps")
+	}
+	command = append(command, logOpts.ExtraArgs...)
+	command = append(command, pod)
+
+	return strings.Join(command, " ")
+}
+
+func podLogFile(dir string, pod string) string {
+	return filepath.Join(dir, fmt.Sprintf("%s_%s.log", logOpts.Namespace, pod))
+}
+
+func isDirectory(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
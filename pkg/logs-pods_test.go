@@ -0,0 +1,121 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "github.com/bouk/monkey"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
+)
+
+func TestLogs_PodSelectorToDirectory(t *testing.T) {
+    dir, err := ioutil.TempDir("", "pod-logs")
+    assert.Nil(t, err)
+    defer os.RemoveAll(dir)
+
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.LogsOpts{
+        Type: "pod",
+        Namespace: "kube-system",
+        Selector: "k8s-app in (kube-dns)",
+        AllContainers: true,
+        Previous: true,
+        FileOutput: dir,
+        GenericOpts: types.GenericOpts{
+            GroupArg: "worker",
+        },
+    }
+
+    nodes := []string{}
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                nodes = append(nodes, node.Host)
+                switch {
+                case strings.HasPrefix(command, "kubectl get pods"):
+                    assert.Equal(t, "kubectl get pods --namespace=kube-system --selector='k8s-app in (kube-dns)' "+
+                        "--output=jsonpath='{.items[*].metadata.name}'", command)
+                    return &types.SSHOutput{Stdout: "kube-dns-1 kube-dns-2"}, nil
+                case strings.HasSuffix(command, "kube-dns-1"):
+                    assert.Equal(t, "kubectl logs --namespace=kube-system --all-containers=true --previous kube-dns-1", command)
+                    return &types.SSHOutput{Stdout: "logs of dns 1"}, nil
+                default:
+                    return &types.SSHOutput{Stdout: "logs of dns 2"}, nil
+                }
+            },
+        }
+    }
+
+    Logs(cmdContext)
+
+    assert.Equal(t, []string{"host1", "host1", "host1"}, nodes)
+    assert.NotContains(t, outBuffer.String(), "logs of dns")
+    assert.Contains(t, outBuffer.String(), "Result on pod kube-system/kube-dns-2:")
+
+    content, err := ioutil.ReadFile(filepath.Join(dir, "kube-system_kube-dns-1.log"))
+    assert.Nil(t, err)
+    assert.Contains(t, string(content), "logs of dns 1")
+    assert.NotContains(t, string(content), "logs of dns 2")
+    content, err = ioutil.ReadFile(filepath.Join(dir, "kube-system_kube-dns-2.log"))
+    assert.Nil(t, err)
+    assert.Contains(t, string(content), "logs of dns 2")
+}
+
+func TestLogs_PodFirstAccessibleMaster(t *testing.T) {
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.LogsOpts{
+        Type: "pod",
+        Container: "dnsmasq",
+        GenericOpts: types.GenericOpts{
+            TargetArg: "kube-dns-1",
+        },
+    }
+
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        if command == "hostname" && mockExecutor.Node.Host == "host1" {
+            return &types.SSHOutput{}, fmt.Errorf("SSH failed")
+        }
+        return &types.SSHOutput{}, nil
+    }
+    logsNode := ""
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                logsNode = node.Host
+                assert.Equal(t, "kubectl logs --namespace=default --container=dnsmasq kube-dns-1", command)
+                return &types.SSHOutput{Stdout: "dnsmasq started"}, nil
+            },
+        }
+    }
+
+    Logs(cmdContext)
+
+    assert.Equal(t, "host3", logsNode)
+    assert.Contains(t, outBuffer.String(), "default/kube-dns-1 | dnsmasq started")
+}
+
+func TestLogs_PodInvalidOptions(t *testing.T) {
+    patch := monkey.Patch(os.Exit, func(int) {
+        panic("exit")
+    })
+    defer patch.Unpatch()
+
+    for msg, opts := range map[string]*types.LogsOpts{
+        "Either a pod as element or a selector is required": {Type: "pod"},
+        "A pod as element and a selector are mutually exclusive": {Type: "pod", Selector: "app=web", GenericOpts: types.GenericOpts{TargetArg: "web-1"}},
+        "A container and all containers are mutually exclusive": {Type: "pod", Container: "web", AllContainers: true, GenericOpts: types.GenericOpts{TargetArg: "web-1"}},
+    } {
+        _, outBuffer, cmdContext := defaultContext()
+        cmdContext.Opts = opts
+
+        assert.Panics(t, func() { Logs(cmdContext) })
+        assert.Contains(t, outBuffer.String(), msg)
+    }
+}
@@ -3,7 +3,7 @@ package pkg
 import (
 	"fmt"
 
-	"github.com/mrahbar/kubernetes-inspector/ssh"
+	"github.com/mrahbar/kubernetes-inspector/integration"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/mrahbar/kubernetes-inspector/util"
 	"strings"
@@ -14,10 +14,31 @@ var logOpts *types.LogsOpts
 func Logs(cmdParams *types.CommandContext) {
 	initParams(cmdParams)
 	logOpts = cmdParams.Opts.(*types.LogsOpts)
+
+	if logOpts.Type == "pod" {
+		podLogs()
+		return
+	}
+
+	if logOpts.Follow {
+		nodes := selectNodes(cmdParams.Config, &logOpts.GenericOpts)
+		targets := []followTarget{}
+		for _, node := range nodes {
+			executor := cmdExecutor.ForNode(node)
+			command, err := logsCommand(logOpts.TargetArg, executor, printer)
+			if err != nil {
+				printer.PrintErr("Skipping node %s: %s", util.ToNodeLabel(node), err)
+				continue
+			}
+			targets = append(targets, followTarget{label: util.ToNodeLabel(node), executor: executor, command: command})
+		}
+		followLogs(targets, fmt.Sprintf("%s %s on %d nodes", logOpts.Type, logOpts.TargetArg, len(targets)))
+		return
+	}
 	runGeneric(cmdParams.Config, &logOpts.GenericOpts, initializeLogs, logs)
 }
 
-func initializeLogs(target string, node string) {
+func initializeLogs(target string, node string, printer integration.LogWriter) {
 	printer.PrintHeader(fmt.Sprintf("Retrieving logs for %s %s on node %s :\n",
 		logOpts.Type, target, node), '=')
 
@@ -32,76 +53,48 @@ func initializeLogs(target string, node string) {
 	printer.PrintNewLine()
 }
 
-func logs(element string) {
-	command := []string{}
+func logs(element string, executor types.CommandExecutor, printer integration.LogWriter) {
+	command, err := logsCommand(element, executor, printer)
+	if err != nil {
+		printer.PrintErr("Error retrieving logs of %s: %s", element, err)
+		return
+	}
+	printResult(nodeSource(executor.GetNode()), command, logOpts.Sudo, logOpts.Buffered, logOpts.FileOutput, executor, printer)
+}
+
+// logsCommand returns the command retrieving the logs of a service or container on the node of executor. When following,
+// timestamps are requested from the container runtime so that the lines of several nodes can be merged in order.
+func logsCommand(element string, executor types.CommandExecutor, printer integration.LogWriter) (string, error) {
 	switch logOpts.Type {
 	case "service":
-		command = append(command, "journalctl")
-		if logOpts.Tail > 0 {
-			command = append(command, fmt.Sprintf("--lines=%d", logOpts.Tail))
-		}
-		if logOpts.Since != "" {
-			command = append(command, fmt.Sprintf("--since=%s", logOpts.Since))
-		}
-		if len(logOpts.ExtraArgs) > 0 {
-			for _, arg := range logOpts.ExtraArgs {
-				command = append(command, arg)
-			}
-		}
-		command = append(command, fmt.Sprintf("--unit=%s", element))
+		return serviceLogsCommand(element, logOpts), nil
 	case "container":
-		command = append(command, "docker logs")
-		if logOpts.Tail > 0 {
-			command = append(command, fmt.Sprintf("--tail %d", logOpts.Tail))
-		}
-		if logOpts.Since != "" {
-			command = append(command, fmt.Sprintf("--since %s", logOpts.Since))
-		}
-		if len(logOpts.ExtraArgs) > 0 {
-			for _, arg := range logOpts.ExtraArgs {
-				command = append(command, arg)
-			}
-		}
-		command = append(command, element)
-	case "pod":
-		command = append(command, "kubectl logs")
-		if logOpts.Tail > 0 {
-			command = append(command, fmt.Sprintf("--tail=%d", logOpts.Tail))
-		}
-		if logOpts.Since != "" {
-			command = append(command, fmt.Sprintf("--since=%s", logOpts.Since))
-		}
-		if len(logOpts.ExtraArgs) > 0 {
-			for _, arg := range logOpts.ExtraArgs {
-				command = append(command, arg)
-			}
+		runtime, err := containerRuntimeFor(nodeContainerRuntime(executor.GetNode()), executor, logOpts.Sudo)
+		if err != nil {
+			printer.PrintCritical("Error retrieving logs of %s: %s", element, err)
 		}
-		command = append(command, element)
+		return runtime.LogsCommand(element, logOpts, executor)
 	default:
 		printer.PrintCritical("Unknown type %s", logOpts.Type)
 	}
 
-	cmd := fmt.Sprintf("%s", strings.Join(command, " "))
-	sshOut, err := cmdExecutor.PerformCmd(cmd, clusterStatusOpts.Sudo)
+	return "", nil
+}
 
-	printer.Print(fmt.Sprintf("Result on node %s:\n", util.ToNodeLabel(cmdExecutor.GetNode())))
-	if err != nil {
-		printer.PrintErr("Error executing command: %s", err)
-	} else {
-		result := ssh.CombineOutput(sshOut)
-		if logOpts.FileOutput != "" {
-			out := fmt.Sprintf("Result of '%s' on node %s:\n\n%s\n\n", strings.Join(command, " "), util.ToNodeLabel(cmdExecutor.GetNode()), result)
-			err := util.WriteOutputFile(logOpts.FileOutput, out)
-			if err != nil {
-				printer.PrintWarn("Failed to write to output file %s forwarding to screen: %s", logOpts.FileOutput, err)
-				printer.PrintOk(result)
-			} else {
-				printer.PrintOk("Result written to file")
-			}
-		} else {
-			printer.PrintOk(result)
-		}
+// serviceLogsCommand returns the journalctl command retrieving the logs of the systemd unit
+func serviceLogsCommand(unit string, opts *types.LogsOpts) string {
+	command := []string{"journalctl"}
+	if opts.Tail > 0 {
+		command = append(command, fmt.Sprintf("--lines=%d", opts.Tail))
+	}
+	if opts.Since != "" {
+		command = append(command, fmt.Sprintf("--since=%s", opts.Since))
 	}
+	if opts.Follow {
+		command = append(command, "--follow")
+	}
+	command = append(command, opts.ExtraArgs...)
+	command = append(command, fmt.Sprintf("--unit=%s", unit))
 
-	printer.PrintNewLine()
+	return strings.Join(command, " ")
 }
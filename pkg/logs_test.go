@@ -70,6 +70,9 @@ func TestLogs_Docker(t *testing.T) {
     called := false
     logsOut := "Kubelet logs"
     mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        if command == containerRuntimeDetectCmd {
+            return &types.SSHOutput{Stdout: "docker"}, nil
+        }
         called = true
         assert.Equal(t, "docker logs --tail 10 --since 10m kubelet", command)
         return &types.SSHOutput{Stdout: logsOut}, nil
@@ -96,8 +99,11 @@ func TestLogs_Pod(t *testing.T) {
     called := false
     logsOut := "kube-dns logs"
     mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        if command == "hostname" {
+            return &types.SSHOutput{}, nil
+        }
         called = true
-        assert.Equal(t, "kubectl logs --tail=10 --since=10m kube-dns", command)
+        assert.Equal(t, "kubectl logs --namespace=default --tail=10 --since=10m kube-dns", command)
         return &types.SSHOutput{Stdout: logsOut}, nil
     }
 
@@ -125,8 +131,11 @@ func TestLogs_FileOutput(t *testing.T) {
     called := false
     logsOut := "kube-dns logs"
     mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        if command == "hostname" {
+            return &types.SSHOutput{}, nil
+        }
         called = true
-        assert.Equal(t, "kubectl logs --tail=10 --since=10m kube-dns", command)
+        assert.Equal(t, "kubectl logs --namespace=default --tail=10 --since=10m kube-dns", command)
         return &types.SSHOutput{Stdout: logsOut}, nil
     }
 
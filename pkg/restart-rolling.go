@@ -0,0 +1,281 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/ssh"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+// rollingPollInterval is how often the health of a restarted node is checked
+var rollingPollInterval = 2 * time.Second
+
+const defaultRollingTimeout = 5 * time.Minute
+
+// healthGate is one of the checks a restarted node has to pass
+type healthGate struct {
+	name  string
+	check func() error
+}
+
+// rollingRestart restarts the service batch by batch. The next batch is started only once every node of the
+// current batch is healthy again, the rollout is aborted on the first node which fails to restart or become healthy.
+func rollingRestart() {
+	if restartOpts.Parallel > 1 {
+		printer.PrintCritical("A rolling restart is processed in batches, use --batch-size instead of --parallel")
+	}
+	batchSize := restartOpts.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	timeout := restartOpts.Timeout
+	if timeout <= 0 {
+		timeout = defaultRollingTimeout
+	}
+
+	service := restartOpts.TargetArg
+	nodes := selectNodes(config, &restartOpts.GenericOpts)
+
+	var master types.CommandExecutor
+	nodeNames := map[string]string{}
+	if restartOpts.WaitReady {
+		master = readyCheckExecutor()
+		nodeNames = kubernetesNodeNames(master, nodes)
+	}
+
+	batches := (len(nodes) + batchSize - 1) / batchSize
+	printer.PrintHeader(fmt.Sprintf("Rolling restart of service %s on %d nodes in %d batches", service, len(nodes), batches), '=')
+
+	for b := 0; b < batches; b++ {
+		end := (b + 1) * batchSize
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+		batch := nodes[b*batchSize : end]
+		if b > 0 && restartOpts.Pause > 0 {
+			printer.PrintInfo("Pausing %s before the next batch", restartOpts.Pause)
+			time.Sleep(restartOpts.Pause)
+		}
+
+		labels := []string{}
+		for _, node := range batch {
+			labels = append(labels, util.ToNodeLabel(node))
+		}
+		printer.PrintNewLine()
+		printer.Print("Batch %d/%d: %s", b+1, batches, strings.Join(labels, ", "))
+
+		restartedAt := map[string]time.Time{}
+		for i, node := range batch {
+			executor := cmdExecutor.ForNode(node)
+			// The clock of the node is used since the timestamps of the checks are taken on the node as well
+			now, err := nodeTime(executor)
+			if err != nil {
+				abortRollout(nodes, b*batchSize+i, "Error reading the time of node %s: %s", util.ToNodeLabel(node), err)
+			}
+			if _, err := executor.PerformCmd(fmt.Sprintf("systemctl restart %s", service), restartOpts.Sudo); err != nil {
+				abortRollout(nodes, b*batchSize+i+1, "Error restarting service %s on node %s: %s", service, util.ToNodeLabel(node), err)
+			}
+			restartedAt[node.Host] = now
+		}
+
+		for _, node := range batch {
+			if err := waitUntilHealthy(node, nodeNames[node.Host], master, restartedAt[node.Host], timeout); err != nil {
+				abortRollout(nodes, end, "Service %s on node %s did not become healthy: %s", service, util.ToNodeLabel(node), err)
+			}
+			printer.PrintOk("Service %s restarted on node %s and healthy.", service, util.ToNodeLabel(node))
+		}
+	}
+
+	printer.PrintNewLine()
+	printer.PrintOk("Rolling restart of service %s finished on %d nodes", service, len(nodes))
+}
+
+// abortRollout reports the failure together with the nodes which were left untouched and exits
+func abortRollout(nodes []types.Node, next int, format string, a ...interface{}) {
+	printer.PrintErr(format, a...)
+	if next < len(nodes) {
+		labels := []string{}
+		for _, node := range nodes[next:] {
+			labels = append(labels, util.ToNodeLabel(node))
+		}
+		printer.PrintWarn("Not restarted: %s", strings.Join(labels, ", "))
+	}
+	printer.PrintCritical("Rolling restart aborted")
+}
+
+// readyCheckExecutor returns the executor of the first accessible master which reads the node conditions
+func readyCheckExecutor() types.CommandExecutor {
+	group := util.FindGroupByName(config.ClusterGroups, types.MASTER_GROUPNAME)
+	if group.Nodes == nil || len(group.Nodes) == 0 {
+		printer.PrintCritical("No host configured for group [%s]", types.MASTER_GROUPNAME)
+	}
+
+	node := ssh.GetFirstAccessibleNode(config.Ssh.LocalOn, cmdExecutor, group.Nodes)
+	if !util.IsNodeAddressValid(node) {
+		printer.PrintCritical("No master available")
+	}
+	return cmdExecutor.ForNode(node)
+}
+
+// kubernetesNodeNames maps the configured nodes to the names of their Kubernetes nodes. A node is matched by its
+// InternalIP, falling back to the host name, and the rollout is not started when one of the nodes is missing.
+func kubernetesNodeNames(master types.CommandExecutor, nodes []types.Node) map[string]string {
+	command := "kubectl get nodes --output=jsonpath='{range .items[*]}{.metadata.name} " +
+		"{.status.addresses[?(@.type==\"InternalIP\")].address}{\"\\n\"}{end}'"
+	sshOut, err := master.PerformCmd(command, restartOpts.Sudo)
+	if err != nil {
+		printer.PrintCritical("Error listing Kubernetes nodes: %s", err)
+	}
+
+	byAddress := map[string]string{}
+	byName := map[string]string{}
+	for _, line := range strings.Split(sshOut.Stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		byName[fields[0]] = fields[0]
+		for _, address := range fields[1:] {
+			byAddress[address] = fields[0]
+		}
+	}
+
+	names := map[string]string{}
+	for _, node := range nodes {
+		name, ok := byAddress[node.IP]
+		if !ok {
+			name, ok = byName[node.Host]
+		}
+		if !ok {
+			printer.PrintCritical("Node %s not found in Kubernetes, neither by InternalIP nor by name", util.ToNodeLabel(node))
+		}
+		names[node.Host] = name
+	}
+	return names
+}
+
+// nodeTime returns the current time of the node in seconds
+func nodeTime(executor types.CommandExecutor) (time.Time, error) {
+	sshOut, err := executor.PerformCmd("date +%s", false)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseUnixTime(sshOut.Stdout)
+}
+
+func parseUnixTime(value string) (time.Time, error) {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", strings.TrimSpace(value))
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// waitUntilHealthy polls until the service is active since its restart, the health command succeeds and, if
+// requested, the node sent a Ready heartbeat after the restart. Each gate must pass before the next one is checked.
+// With a settle period the service has to be still active and not restarted again once the period is over.
+func waitUntilHealthy(node types.Node, nodeName string, master types.CommandExecutor, restartedAt time.Time, timeout time.Duration) error {
+	executor := cmdExecutor.ForNode(node)
+	deadline := time.Now().Add(timeout)
+
+	active := healthGate{"active", func() error {
+		return checkServiceRestarted(executor, restartedAt)
+	}}
+	gates := []healthGate{active}
+	if restartOpts.HealthCmd != "" {
+		command := parseTemplate(restartOpts.HealthCmd, node)
+		gates = append(gates, healthGate{"health command", func() error {
+			_, err := executor.PerformCmd(command, restartOpts.Sudo)
+			return err
+		}})
+	}
+	if master != nil {
+		gates = append(gates, healthGate{"node Ready", func() error {
+			return checkNodeReady(master, nodeName, restartedAt)
+		}})
+	}
+
+	for _, gate := range gates {
+		for {
+			err := gate.check()
+			if err == nil {
+				printer.PrintDebug("Node %s passed %s", util.ToNodeLabel(node), gate.name)
+				break
+			}
+			if !time.Now().Before(deadline) {
+				return fmt.Errorf("%s not passed within %s: %s", gate.name, timeout, err)
+			}
+			time.Sleep(rollingPollInterval)
+		}
+	}
+
+	if restartOpts.Settle > 0 {
+		time.Sleep(restartOpts.Settle)
+		if err := active.check(); err != nil {
+			return fmt.Errorf("not stable for %s: %s", restartOpts.Settle, err)
+		}
+	}
+
+	return nil
+}
+
+// checkServiceRestarted requires the service to be active and to have entered the active state after the restart,
+// systemctl reports a simple service active right away, even when it fails shortly after
+func checkServiceRestarted(executor types.CommandExecutor, restartedAt time.Time) error {
+	service := restartOpts.TargetArg
+	sshOut, err := executor.PerformCmd(fmt.Sprintf("systemctl is-active %s", service), restartOpts.Sudo)
+	state := ""
+	if sshOut != nil {
+		state = strings.TrimSpace(sshOut.Stdout)
+	}
+	if state == "" && err != nil {
+		return err
+	}
+	if state != "active" {
+		return fmt.Errorf("service is %s", firstNonEmpty(state, "unknown"))
+	}
+
+	sshOut, err = executor.PerformCmd(fmt.Sprintf("date -d \"$(systemctl show %s --property=ActiveEnterTimestamp --value)\" +%%s", service), false)
+	if err != nil {
+		return err
+	}
+	since, err := parseUnixTime(sshOut.Stdout)
+	if err != nil {
+		return err
+	}
+	if since.Before(restartedAt) {
+		return fmt.Errorf("service still active since %s, before the restart", since.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// checkNodeReady requires the Ready condition with a heartbeat sent after the restart, the condition alone
+// stays True for the grace period of the node controller while the kubelet is down
+func checkNodeReady(master types.CommandExecutor, nodeName string, restartedAt time.Time) error {
+	command := fmt.Sprintf("kubectl get node %s --output=jsonpath='{.status.conditions[?(@.type==\"Ready\")].status} "+
+		"{.status.conditions[?(@.type==\"Ready\")].lastHeartbeatTime}'", nodeName)
+	sshOut, err := master.PerformCmd(command, restartOpts.Sudo)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(sshOut.Stdout)
+	if len(fields) != 2 {
+		return fmt.Errorf("Ready condition is unknown")
+	}
+	if fields[0] != "True" {
+		return fmt.Errorf("Ready condition is %s", fields[0])
+	}
+	heartbeat, err := time.Parse(time.RFC3339, fields[1])
+	if err != nil {
+		return fmt.Errorf("invalid heartbeat time %s", fields[1])
+	}
+	if heartbeat.Before(restartedAt) {
+		return fmt.Errorf("no heartbeat since the restart, the last one was at %s", fields[1])
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "github.com/bouk/monkey"
+    "fmt"
+    "os"
+    "strings"
+    "sync"
+    "time"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
+)
+
+// restartedService answers the commands a rolling restart runs on a node, the service became active after the restart
+func restartedService(command string) (*types.SSHOutput, error) {
+    switch {
+    case command == "date +%s":
+        return &types.SSHOutput{Stdout: "1521446400\n"}, nil
+    case strings.HasPrefix(command, "date -d"):
+        return &types.SSHOutput{Stdout: "1521446402\n"}, nil
+    case strings.HasPrefix(command, "systemctl is-active"):
+        return &types.SSHOutput{Stdout: "active"}, nil
+    }
+    return &types.SSHOutput{}, nil
+}
+
+func TestRollingRestart_Batches(t *testing.T) {
+    interval := rollingPollInterval
+    rollingPollInterval = time.Millisecond
+    defer func() { rollingPollInterval = interval }()
+
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.RestartOpts{
+        GenericOpts: types.GenericOpts{
+            TargetArg: "kubelet",
+            NodeArg: "host1,host2,host3",
+        },
+        Rolling: true,
+        BatchSize: 2,
+        HealthCmd: "curl -sf http://{{.Host}}:10248/healthz",
+        WaitReady: true,
+    }
+
+    mu := sync.Mutex{}
+    commands := []string{}
+    // host2 is still activating, then reports the start time from before the restart and sends no fresh heartbeat at first.
+    // host3 is not listed with its address and is matched by name.
+    activating := map[string]bool{"host2": true}
+    oldStart := map[string]bool{"host2": true}
+    oldHeartbeat := true
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        return &types.SSHOutput{Stdout: "host1"}, nil
+    }
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                mu.Lock()
+                defer mu.Unlock()
+                commands = append(commands, node.Host+": "+command)
+                switch {
+                case command == "systemctl is-active kubelet" && activating[node.Host]:
+                    activating[node.Host] = false
+                    return &types.SSHOutput{Stdout: "activating", ExitStatus: 3}, fmt.Errorf("Process exited with status 3")
+                case strings.HasPrefix(command, "date -d") && oldStart[node.Host]:
+                    oldStart[node.Host] = false
+                    return &types.SSHOutput{Stdout: "1521440000\n"}, nil
+                case strings.HasPrefix(command, "kubectl get nodes"):
+                    return &types.SSHOutput{Stdout: "node-a 3\nnode-b 1\nhost3 10.0.0.3\n"}, nil
+                case strings.HasPrefix(command, "kubectl get node node-b") && oldHeartbeat:
+                    oldHeartbeat = false
+                    return &types.SSHOutput{Stdout: "True 2018-03-19T07:59:50Z"}, nil
+                case strings.HasPrefix(command, "kubectl get node "):
+                    return &types.SSHOutput{Stdout: "True 2018-03-19T08:00:10Z"}, nil
+                }
+                return restartedService(command)
+            },
+        }
+    }
+
+    Restart(cmdContext)
+
+    nodes := "kubectl get nodes --output=jsonpath='{range .items[*]}{.metadata.name} " +
+        "{.status.addresses[?(@.type==\"InternalIP\")].address}{\"\\n\"}{end}'"
+    ready := "kubectl get node %s --output=jsonpath='{.status.conditions[?(@.type==\"Ready\")].status} " +
+        "{.status.conditions[?(@.type==\"Ready\")].lastHeartbeatTime}'"
+    since := "date -d \"$(systemctl show kubelet --property=ActiveEnterTimestamp --value)\" +%s"
+    assert.Equal(t, []string{
+        "host1: " + nodes,
+        "host1: date +%s",
+        "host1: systemctl restart kubelet",
+        "host2: date +%s",
+        "host2: systemctl restart kubelet",
+        "host1: systemctl is-active kubelet",
+        "host1: " + since,
+        "host1: curl -sf http://host1:10248/healthz",
+        "host1: " + fmt.Sprintf(ready, "node-a"),
+        "host2: systemctl is-active kubelet",
+        "host2: systemctl is-active kubelet",
+        "host2: " + since,
+        "host2: systemctl is-active kubelet",
+        "host2: " + since,
+        "host2: curl -sf http://host2:10248/healthz",
+        "host1: " + fmt.Sprintf(ready, "node-b"),
+        "host1: " + fmt.Sprintf(ready, "node-b"),
+        "host3: date +%s",
+        "host3: systemctl restart kubelet",
+        "host3: systemctl is-active kubelet",
+        "host3: " + since,
+        "host3: curl -sf http://host3:10248/healthz",
+        "host1: " + fmt.Sprintf(ready, "host3"),
+    }, commands)
+
+    out := outBuffer.String()
+    assert.Contains(t, out, "Batch 1/2: host1 (3), host2 (1)")
+    assert.Contains(t, out, "Rolling restart of service kubelet finished on 3 nodes")
+}
+
+func TestRollingRestart_UnknownKubernetesNode(t *testing.T) {
+    patch := monkey.Patch(os.Exit, func(int) {
+        panic("exit")
+    })
+    defer patch.Unpatch()
+
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.RestartOpts{
+        GenericOpts: types.GenericOpts{
+            TargetArg: "kubelet",
+            NodeArg: "host1,host2",
+        },
+        Rolling: true,
+        WaitReady: true,
+    }
+
+    restarted := false
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        return &types.SSHOutput{Stdout: "host1"}, nil
+    }
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                if strings.HasPrefix(command, "kubectl get nodes") {
+                    return &types.SSHOutput{Stdout: "node-a 3\n"}, nil
+                }
+                if strings.HasPrefix(command, "systemctl restart") {
+                    restarted = true
+                }
+                return restartedService(command)
+            },
+        }
+    }
+
+    assert.Panics(t, func() { Restart(cmdContext) })
+    assert.False(t, restarted)
+    assert.Contains(t, outBuffer.String(), "Node host2 (1) not found in Kubernetes, neither by InternalIP nor by name")
+}
+
+func TestRollingRestart_NotStableAfterSettle(t *testing.T) {
+    interval := rollingPollInterval
+    rollingPollInterval = time.Millisecond
+    defer func() { rollingPollInterval = interval }()
+
+    patch := monkey.Patch(os.Exit, func(int) {
+        panic("exit")
+    })
+    defer patch.Unpatch()
+
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.RestartOpts{
+        GenericOpts: types.GenericOpts{
+            TargetArg: "kubelet",
+            NodeArg: "host1,host2",
+        },
+        Rolling: true,
+        Settle: time.Millisecond,
+    }
+
+    checks := 0
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                // the service crashes right after it was reported active
+                if command == "systemctl is-active kubelet" {
+                    checks++
+                    if checks > 1 {
+                        return &types.SSHOutput{Stdout: "failed", ExitStatus: 3}, fmt.Errorf("Process exited with status 3")
+                    }
+                }
+                return restartedService(command)
+            },
+        }
+    }
+
+    assert.Panics(t, func() { Restart(cmdContext) })
+    out := outBuffer.String()
+    assert.Contains(t, out, "Service kubelet on node host1 (3) did not become healthy: not stable for 1ms: service is failed")
+    assert.Contains(t, out, "Not restarted: host2 (1)")
+}
+
+func TestRollingRestart_AbortsOnFailure(t *testing.T) {
+    interval := rollingPollInterval
+    rollingPollInterval = time.Millisecond
+    defer func() { rollingPollInterval = interval }()
+
+    patch := monkey.Patch(os.Exit, func(int) {
+        panic("exit")
+    })
+    defer patch.Unpatch()
+
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.RestartOpts{
+        GenericOpts: types.GenericOpts{
+            TargetArg: "etcd",
+            NodeArg: "host1,host2,host3",
+        },
+        Rolling: true,
+        Timeout: 20 * time.Millisecond,
+        HealthCmd: "etcdctl endpoint health",
+    }
+
+    restarted := []string{}
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                switch command {
+                case "systemctl restart etcd":
+                    restarted = append(restarted, node.Host)
+                case "etcdctl endpoint health":
+                    if node.Host == "host2" {
+                        return &types.SSHOutput{}, fmt.Errorf("Process exited with status 1")
+                    }
+                }
+                return restartedService(command)
+            },
+        }
+    }
+
+    assert.Panics(t, func() { Restart(cmdContext) })
+
+    out := outBuffer.String()
+    assert.Equal(t, []string{"host1", "host2"}, restarted)
+    assert.Contains(t, out, "Service etcd on node host2 (1) did not become healthy: health command not passed within 20ms: Process exited with status 1")
+    assert.Contains(t, out, "Not restarted: host3 (2)")
+    assert.Contains(t, out, "Rolling restart aborted")
+}
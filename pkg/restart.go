@@ -3,28 +3,33 @@ package pkg
 import (
 	"fmt"
 
+	"github.com/mrahbar/kubernetes-inspector/integration"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/mrahbar/kubernetes-inspector/util"
 )
 
-var restartOpts *types.GenericOpts
+var restartOpts *types.RestartOpts
 
 func Restart(cmdParams *types.CommandContext) {
     initParams(cmdParams)
-    restartOpts = cmdParams.Opts.(*types.GenericOpts)
-	runGeneric(config, restartOpts, initializeRestartService, restartService)
+    restartOpts = cmdParams.Opts.(*types.RestartOpts)
+    if restartOpts.Rolling {
+        rollingRestart()
+        return
+    }
+	runGeneric(config, &restartOpts.GenericOpts, initializeRestartService, restartService)
 }
 
-func initializeRestartService(service string, node string) {
+func initializeRestartService(service string, node string, printer integration.LogWriter) {
     printer.PrintHeader(fmt.Sprintf("Restarting service %v on node %s",
         service, node), '=')
     printer.PrintNewLine()
 }
 
-func restartService(service string) {
-    _, err := cmdExecutor.PerformCmd(fmt.Sprintf("systemctl restart %s", service), restartOpts.Sudo)
+func restartService(service string, executor types.CommandExecutor, printer integration.LogWriter) {
+    _, err := executor.PerformCmd(fmt.Sprintf("systemctl restart %s", service), restartOpts.Sudo)
 
-    printer.Print(fmt.Sprintf("Result on node %s:", util.ToNodeLabel(cmdExecutor.GetNode())))
+    printer.Print(fmt.Sprintf("Result on node %s:", util.ToNodeLabel(executor.GetNode())))
 
 	if err != nil {
         printer.PrintErr("Error restarting service %s: %s", service, err)
@@ -11,9 +11,11 @@ import (
 
 func TestRestartService_Ok(t *testing.T) {
     mockExecutor, outBuffer, context := defaultContext()
-    context.Opts = &types.GenericOpts{
-        TargetArg: "docker",
-        NodeArg: "host1",
+    context.Opts = &types.RestartOpts{
+        GenericOpts: types.GenericOpts{
+            TargetArg: "docker",
+            NodeArg: "host1",
+        },
     }
 
     called := false
@@ -30,9 +32,11 @@ func TestRestartService_Ok(t *testing.T) {
 
 func TestRestartServiceMultipleNodes_Ok(t *testing.T) {
     mockExecutor, outBuffer, context := defaultContext()
-    context.Opts = &types.GenericOpts{
-        TargetArg: "docker",
-        NodeArg: "host1,host2",
+    context.Opts = &types.RestartOpts{
+        GenericOpts: types.GenericOpts{
+            TargetArg: "docker",
+            NodeArg: "host1,host2",
+        },
     }
 
     called := false
@@ -50,9 +54,11 @@ func TestRestartServiceMultipleNodes_Ok(t *testing.T) {
 
 func TestRestartService_Error(t *testing.T) {
     mockExecutor, outBuffer, context := defaultContext()
-    context.Opts = &types.GenericOpts{
-        TargetArg: "docker",
-        NodeArg: "host1",
+    context.Opts = &types.RestartOpts{
+        GenericOpts: types.GenericOpts{
+            TargetArg: "docker",
+            NodeArg: "host1",
+        },
     }
 
     called := false
@@ -72,8 +78,10 @@ func TestRestartService_Error(t *testing.T) {
 
 func TestRestartService_TargetMissing(t *testing.T) {
     _, outBuffer, context := defaultContext()
-    context.Opts = &types.GenericOpts{
-        NodeArg: "host1",
+    context.Opts = &types.RestartOpts{
+        GenericOpts: types.GenericOpts{
+            NodeArg: "host1",
+        },
     }
 
     osExitCalled := false
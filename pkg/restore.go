@@ -0,0 +1,307 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+const remoteRestoreArchiveName = "etcd-restore.tar.gz"
+const defaultRestoreTimeout = 5 * time.Minute
+
+// restorePollInterval is how often is checked whether etcd stopped or became healthy
+var restorePollInterval = 2 * time.Second
+
+var etcdRestoreOpts *types.EtcdRestoreOpts
+
+// restoreState tracks what a restore created so far. The undo commands are recorded as the steps complete and
+// printed in reverse order when the restore fails.
+var restoreState struct {
+	decryptedArchive string
+	remoteDirs       map[string]string
+	undo             []string
+}
+
+func Restore(cmdParams *types.CommandContext) {
+	initParams(cmdParams)
+	etcdRestoreOpts = cmdParams.Opts.(*types.EtcdRestoreOpts)
+	restoreState.decryptedArchive = ""
+	restoreState.remoteDirs = map[string]string{}
+	restoreState.undo = []string{}
+
+	group := util.FindGroupByName(config.ClusterGroups, types.ETCD_GROUPNAME)
+	if group.Nodes == nil || len(group.Nodes) == 0 {
+		printer.PrintCritical("No host configured for group [%s]", types.ETCD_GROUPNAME)
+	}
+
+	if _, err := os.Stat(etcdRestoreOpts.Archive); err != nil {
+		printer.PrintCritical("Archive %s not readable: %s", etcdRestoreOpts.Archive, err)
+	}
+
+	initialCluster := etcdInitialCluster(group.Nodes)
+	printer.Print("Restoring %s to the members %s", etcdRestoreOpts.Archive, initialCluster)
+	printer.Print("Etcd is stopped on all members and %s is replaced. The old data is kept in %s.bak-<timestamp>",
+		etcdRestoreOpts.DataDir, etcdRestoreOpts.DataDir)
+
+	if !etcdRestoreOpts.Yes {
+		printer.PrintCritical("Restore replaces the data of all etcd members. Run again with --yes to proceed")
+	}
+
+	archive := etcdRestoreOpts.Archive
+	if isEncryptedBackup(archive) {
+		identities, err := backupIdentities(etcdRestoreOpts.Identities)
+		if err != nil {
+			printer.PrintCritical("Error reading identities: %s", err)
+		}
+
+		// The plain archive is only kept in a temporary file readable by the current user
+		archive, err = decryptBackup(etcdRestoreOpts.Archive, identities)
+		if err != nil {
+			printer.PrintCritical("Error decrypting archive %s: %s", etcdRestoreOpts.Archive, err)
+		}
+		restoreState.decryptedArchive = archive
+		printer.PrintOk("Archive decrypted")
+	}
+
+	executors := map[string]types.CommandExecutor{}
+	for _, node := range group.Nodes {
+		executors[node.Host] = cmdExecutor.ForNode(node)
+	}
+
+	printer.PrintHeader("Uploading archive", '-')
+	for _, node := range group.Nodes {
+		prepareRestore(executors[node.Host], node, archive)
+	}
+
+	backupSuffix := strings.Replace(time.Now().Format("2006-01-02T15:04:05"), ":", "-", -1)
+
+	printer.PrintHeader("Stopping etcd", '-')
+	for _, node := range group.Nodes {
+		stopEtcd(executors[node.Host], node, backupSuffix)
+	}
+	for _, node := range group.Nodes {
+		waitEtcdStopped(executors[node.Host], node)
+	}
+
+	printer.PrintHeader("Restoring snapshot", '-')
+	for _, node := range group.Nodes {
+		restoreSnapshot(executors[node.Host], node, initialCluster, backupSuffix)
+	}
+
+	printer.PrintHeader("Starting etcd", '-')
+	for _, node := range group.Nodes {
+		startEtcd(executors[node.Host], node, backupSuffix)
+	}
+	for _, node := range group.Nodes {
+		waitEtcdHealthy(executors[node.Host], node)
+	}
+
+	cleanupRestore(executors)
+	printer.PrintNewLine()
+	printer.PrintOk("Etcd restored from %s", etcdRestoreOpts.Archive)
+}
+
+func etcdPeerUrl(node types.Node) string {
+	return fmt.Sprintf("%s://%s:%d", etcdRestoreOpts.PeerScheme, util.GetNodeAddress(node), etcdRestoreOpts.PeerPort)
+}
+
+func etcdInitialCluster(nodes []types.Node) string {
+	members := []string{}
+	for _, node := range nodes {
+		members = append(members, fmt.Sprintf("%s=%s", node.Host, etcdPeerUrl(node)))
+	}
+	return strings.Join(members, ",")
+}
+
+// restoreManifestBackup is where the static pod manifest is kept while etcd is stopped. The file stays next to the
+// manifest but is hidden, kubelet would otherwise start the pod from it as it reads every file of the directory.
+func restoreManifestBackup(backupSuffix string) string {
+	dir, file := path.Split(etcdRestoreOpts.StaticPodManifest)
+	return path.Join(dir, fmt.Sprintf(".%s.restore-%s", file, backupSuffix))
+}
+
+func restoreTimeout() time.Duration {
+	if etcdRestoreOpts.Timeout <= 0 {
+		return defaultRestoreTimeout
+	}
+	return etcdRestoreOpts.Timeout
+}
+
+// restoreCmd runs cmd on the node and aborts the restore on failure
+func restoreCmd(executor types.CommandExecutor, node types.Node, cmd string, failure string) *types.SSHOutput {
+	return performRestoreCmd(executor, node, cmd, etcdRestoreOpts.Sudo, failure)
+}
+
+// restoreUserCmd runs cmd as the ssh user, which owns the temporary restore directory
+func restoreUserCmd(executor types.CommandExecutor, node types.Node, cmd string, failure string) *types.SSHOutput {
+	return performRestoreCmd(executor, node, cmd, false, failure)
+}
+
+func performRestoreCmd(executor types.CommandExecutor, node types.Node, cmd string, sudo bool, failure string) *types.SSHOutput {
+	sshOut, err := executor.PerformCmd(cmd, sudo)
+	if err != nil {
+		restoreFailed("%s on node %s: %s", failure, util.ToNodeLabel(node), err)
+	}
+	return sshOut
+}
+
+// recordRestoreUndo remembers the command which reverts a completed step on node
+func recordRestoreUndo(node types.Node, cmd string) {
+	if etcdRestoreOpts.Sudo {
+		cmd = "sudo " + cmd
+	}
+	restoreState.undo = append(restoreState.undo, fmt.Sprintf("%s: %s", util.ToNodeLabel(node), cmd))
+}
+
+// restoreFailed removes the temporary files, prints the commands which undo the completed steps and exits
+func restoreFailed(format string, a ...interface{}) {
+	printer.PrintErr(format, a...)
+	cleanupRestore(nil)
+
+	if len(restoreState.undo) > 0 {
+		printer.PrintWarn("The restore stopped halfway, undo the completed steps in this order:")
+		for i := len(restoreState.undo) - 1; i >= 0; i-- {
+			printer.Print("  %s", restoreState.undo[i])
+		}
+	}
+	printer.PrintCritical("Restore aborted")
+}
+
+// cleanupRestore removes the decrypted archive and the temporary directories on the nodes
+func cleanupRestore(executors map[string]types.CommandExecutor) {
+	if restoreState.decryptedArchive != "" {
+		os.Remove(restoreState.decryptedArchive)
+		restoreState.decryptedArchive = ""
+	}
+
+	group := util.FindGroupByName(config.ClusterGroups, types.ETCD_GROUPNAME)
+	for _, node := range group.Nodes {
+		dir, ok := restoreState.remoteDirs[node.Host]
+		if !ok {
+			continue
+		}
+		executor := executors[node.Host]
+		if executor == nil {
+			executor = cmdExecutor.ForNode(node)
+		}
+		if _, err := executor.PerformCmd(fmt.Sprintf("rm -rf %s", dir), false); err != nil {
+			printer.PrintWarn("Could not remove %s on node %s: %s", dir, util.ToNodeLabel(node), err)
+		}
+	}
+	restoreState.remoteDirs = map[string]string{}
+}
+
+// prepareRestore uploads and extracts the archive into a new directory only accessible by the ssh user
+func prepareRestore(executor types.CommandExecutor, node types.Node, archive string) {
+	sshOut := restoreUserCmd(executor, node, "mktemp -d /tmp/etcd-restore.XXXXXX", "Error creating restore directory")
+	dir := strings.TrimSpace(sshOut.Stdout)
+	if dir == "" {
+		restoreFailed("Error creating restore directory on node %s: mktemp returned no directory", util.ToNodeLabel(node))
+	}
+	restoreState.remoteDirs[node.Host] = dir
+
+	remoteArchive := path.Join(dir, remoteRestoreArchiveName)
+	if err := executor.UploadFile(remoteArchive, archive); err != nil {
+		restoreFailed("Error uploading archive to node %s: %s", util.ToNodeLabel(node), err)
+	}
+
+	restoreUserCmd(executor, node, fmt.Sprintf("tar -xzf %s -C %s", remoteArchive, dir), "Error extracting archive")
+	restoreUserCmd(executor, node, fmt.Sprintf("test -f %s", path.Join(dir, etcdSnapshotName)),
+		"Archive does not contain a v3 snapshot")
+
+	printer.PrintOk("Archive uploaded to node %s", util.ToNodeLabel(node))
+}
+
+func stopEtcd(executor types.CommandExecutor, node types.Node, backupSuffix string) {
+	if etcdRestoreOpts.StaticPodManifest != "" {
+		// kubelet stops the static pod as soon as its manifest is gone
+		manifestBackup := restoreManifestBackup(backupSuffix)
+		restoreCmd(executor, node, fmt.Sprintf("mv %s %s", etcdRestoreOpts.StaticPodManifest, manifestBackup),
+			"Error moving etcd manifest")
+		recordRestoreUndo(node, fmt.Sprintf("mv %s %s", manifestBackup, etcdRestoreOpts.StaticPodManifest))
+	} else {
+		restoreCmd(executor, node, fmt.Sprintf("systemctl stop %s", etcdRestoreOpts.Service), "Error stopping etcd")
+		recordRestoreUndo(node, fmt.Sprintf("systemctl start %s", etcdRestoreOpts.Service))
+	}
+}
+
+// waitEtcdStopped polls until no etcd process is left on the node, which covers etcd running in a container
+// as well since kubelet removes the pod asynchronously
+func waitEtcdStopped(executor types.CommandExecutor, node types.Node) {
+	deadline := time.Now().Add(restoreTimeout())
+	for {
+		sshOut := restoreUserCmd(executor, node, "pgrep -x etcd || true", "Error checking for etcd processes")
+		if strings.TrimSpace(sshOut.Stdout) == "" {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			restoreFailed("Etcd still running on node %s after %s: pid %s", util.ToNodeLabel(node), restoreTimeout(),
+				strings.Join(strings.Fields(sshOut.Stdout), ", "))
+		}
+		time.Sleep(restorePollInterval)
+	}
+
+	printer.PrintOk("Etcd stopped on node %s", util.ToNodeLabel(node))
+}
+
+func restoreSnapshot(executor types.CommandExecutor, node types.Node, initialCluster string, backupSuffix string) {
+	dataDir := etcdRestoreOpts.DataDir
+	restoredDir := dataDir + ".restore"
+	oldDir := fmt.Sprintf("%s.bak-%s", dataDir, backupSuffix)
+
+	restoreCmd(executor, node, fmt.Sprintf("rm -rf %s", restoredDir), "Error cleaning restore data directory")
+	restoreCmd(executor, node, fmt.Sprintf("env ETCDCTL_API=3 etcdctl snapshot restore %s --name %s --initial-cluster %s "+
+		"--initial-cluster-token %s --initial-advertise-peer-urls %s --data-dir %s",
+		path.Join(restoreState.remoteDirs[node.Host], etcdSnapshotName), node.Host, initialCluster, etcdRestoreOpts.ClusterToken,
+		etcdPeerUrl(node), restoredDir),
+		"Error restoring snapshot")
+	// etcdctl creates the files as the user running the restore, etcd may run as a different one
+	restoreCmd(executor, node, fmt.Sprintf("chown -R --reference=%s %s", dataDir, restoredDir), "Error setting owner of restored data directory")
+
+	restoreCmd(executor, node, fmt.Sprintf("mv %s %s", dataDir, oldDir), "Error moving old data directory")
+	recordRestoreUndo(node, fmt.Sprintf("mv %s %s", oldDir, dataDir))
+	restoreCmd(executor, node, fmt.Sprintf("mv %s %s", restoredDir, dataDir), "Error moving restored data directory")
+	recordRestoreUndo(node, fmt.Sprintf("mv %s %s", dataDir, restoredDir))
+
+	printer.PrintOk("Snapshot restored on node %s, old data is at %s", util.ToNodeLabel(node), oldDir)
+}
+
+// startEtcd starts etcd without waiting for it, a member blocks until a quorum of its peers is up
+func startEtcd(executor types.CommandExecutor, node types.Node, backupSuffix string) {
+	if etcdRestoreOpts.StaticPodManifest != "" {
+		manifestBackup := restoreManifestBackup(backupSuffix)
+		restoreCmd(executor, node, fmt.Sprintf("mv %s %s", manifestBackup, etcdRestoreOpts.StaticPodManifest),
+			"Error restoring etcd manifest")
+		recordRestoreUndo(node, fmt.Sprintf("mv %s %s", etcdRestoreOpts.StaticPodManifest, manifestBackup))
+	} else {
+		restoreCmd(executor, node, fmt.Sprintf("systemctl start --no-block %s", etcdRestoreOpts.Service), "Error starting etcd")
+		recordRestoreUndo(node, fmt.Sprintf("systemctl stop %s", etcdRestoreOpts.Service))
+	}
+
+	printer.PrintInfo("Etcd starting on node %s", util.ToNodeLabel(node))
+}
+
+// waitEtcdHealthy polls the client endpoint of the member until it reports healthy
+func waitEtcdHealthy(executor types.CommandExecutor, node types.Node) {
+	opts := etcdNodeOpts(etcdRestoreOpts.EtcdOpts, etcdRestoreOpts.ClientPort, node)
+	cmd := fmt.Sprintf("env ETCDCTL_API=3 etcdctl --endpoints=%s%s endpoint health", opts.Endpoint, etcdTLSArgs(opts))
+
+	deadline := time.Now().Add(restoreTimeout())
+	for {
+		_, err := executor.PerformCmd(cmd, etcdRestoreOpts.Sudo)
+		if err == nil {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			restoreFailed("Etcd on node %s not healthy after %s: %s", util.ToNodeLabel(node), restoreTimeout(), err)
+		}
+		time.Sleep(restorePollInterval)
+	}
+
+	printer.PrintOk("Etcd started on node %s and healthy", util.ToNodeLabel(node))
+}
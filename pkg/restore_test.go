@@ -0,0 +1,228 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "github.com/bouk/monkey"
+    "os"
+    "strings"
+    "io/ioutil"
+    "bytes"
+    "errors"
+    "regexp"
+    "time"
+    "filippo.io/age"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
+)
+
+func restoreContext(t *testing.T, responses func(node types.Node, command string) (*types.SSHOutput, error)) (*bytes.Buffer, *types.CommandContext, *[]string, string) {
+    mockExecutor, outBuffer, context := defaultContext()
+    context.Config.ClusterGroups = append(context.Config.ClusterGroups, types.ClusterGroup{
+        Name: types.ETCD_GROUPNAME,
+        Nodes: []types.Node{
+            {Host: "etcd1", IP: "10.0.0.1"},
+            {Host: "etcd2", IP: "10.0.0.2"},
+        },
+    })
+
+    archive, err := ioutil.TempFile("", "etcd-backup")
+    assert.Nil(t, err)
+    archive.Close()
+
+    commands := &[]string{}
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                *commands = append(*commands, node.Host+": "+command)
+                if responses != nil {
+                    if sshOut, err := responses(node, command); sshOut != nil || err != nil {
+                        return sshOut, err
+                    }
+                }
+                if strings.HasPrefix(command, "mktemp") {
+                    return &types.SSHOutput{Stdout: "/tmp/etcd-restore.a1b2c3\n"}, nil
+                }
+                return &types.SSHOutput{}, nil
+            },
+            MockUploadFile: func(remotePath string, localPath string) error {
+                *commands = append(*commands, node.Host+": upload "+localPath+" "+remotePath)
+                return nil
+            },
+        }
+    }
+
+    return outBuffer, context, commands, archive.Name()
+}
+
+func TestRestore_RequiresConfirmation(t *testing.T) {
+    _, context, commands, archive := restoreContext(t, nil)
+    defer os.Remove(archive)
+    context.Opts = &types.EtcdRestoreOpts{Archive: archive, DataDir: "/var/lib/etcd", PeerScheme: "https", PeerPort: 2380}
+
+    osExitCalled := false
+    patch := monkey.Patch(os.Exit, func(int) {
+        osExitCalled = true
+        panic("exit")
+    })
+    defer patch.Unpatch()
+
+    assert.Panics(t, func() { Restore(context) })
+    assert.True(t, osExitCalled)
+    assert.Empty(t, *commands)
+}
+
+func TestRestore_Members(t *testing.T) {
+    _, context, commands, archive := restoreContext(t, nil)
+    defer os.Remove(archive)
+    context.Opts = &types.EtcdRestoreOpts{
+        Archive:      archive,
+        DataDir:      "/var/lib/etcd",
+        Service:      "etcd",
+        PeerScheme:   "https",
+        PeerPort:     2380,
+        ClusterToken: "etcd-cluster",
+        Yes:          true,
+    }
+
+    Restore(context)
+
+    all := strings.Join(*commands, "\n")
+    assert.Contains(t, all, "etcd1: mktemp -d /tmp/etcd-restore.XXXXXX")
+    assert.Contains(t, all, "etcd1: upload "+archive+" /tmp/etcd-restore.a1b2c3/etcd-restore.tar.gz")
+    assert.Contains(t, all, "etcd2: env ETCDCTL_API=3 etcdctl snapshot restore /tmp/etcd-restore.a1b2c3/snapshot.db --name etcd2 "+
+        "--initial-cluster etcd1=https://10.0.0.1:2380,etcd2=https://10.0.0.2:2380 --initial-cluster-token etcd-cluster "+
+        "--initial-advertise-peer-urls https://10.0.0.2:2380 --data-dir /var/lib/etcd.restore")
+    assert.Contains(t, all, "etcd2: chown -R --reference=/var/lib/etcd /var/lib/etcd.restore")
+
+    // all members are stopped before the first one is restored and started after all are restored
+    assert.True(t, strings.Index(all, "etcd2: systemctl stop etcd") < strings.Index(all, "etcd1: pgrep -x etcd || true"))
+    assert.True(t, strings.Index(all, "etcd2: pgrep -x etcd || true") < strings.Index(all, "etcd1: env ETCDCTL_API=3 etcdctl snapshot restore"))
+    assert.True(t, strings.Index(all, "etcd2: mv /var/lib/etcd.restore /var/lib/etcd") < strings.Index(all, "etcd1: systemctl start --no-block etcd"))
+    // the members are started together before waiting for the first one to become healthy
+    assert.True(t, strings.Index(all, "etcd2: systemctl start --no-block etcd") <
+        strings.Index(all, "etcd1: env ETCDCTL_API=3 etcdctl --endpoints=http://10.0.0.1:2379 endpoint health"))
+    assert.Contains(t, all, "etcd2: env ETCDCTL_API=3 etcdctl --endpoints=http://10.0.0.2:2379 endpoint health")
+    assert.Contains(t, all, "etcd2: rm -rf /tmp/etcd-restore.a1b2c3")
+}
+
+func TestRestore_HealthCheckUsesTLSFlags(t *testing.T) {
+    _, context, commands, archive := restoreContext(t, nil)
+    defer os.Remove(archive)
+    context.Opts = &types.EtcdRestoreOpts{
+        Archive:    archive,
+        DataDir:    "/var/lib/etcd",
+        Service:    "etcd",
+        PeerScheme: "https",
+        PeerPort:   2380,
+        Yes:        true,
+        EtcdOpts: types.EtcdOpts{
+            CaFile:         "/etc/etcd/ca.crt",
+            ClientCertFile: "/etc/etcd/{{.Host}}.crt",
+            ClientKeyFile:  "/etc/etcd/{{.Host}}.key",
+        },
+        ClientPort: 4001,
+    }
+
+    Restore(context)
+
+    all := strings.Join(*commands, "\n")
+    assert.Contains(t, all, "etcd2: env ETCDCTL_API=3 etcdctl --endpoints=https://10.0.0.2:4001 --cacert=/etc/etcd/ca.crt "+
+        "--cert=/etc/etcd/etcd2.crt --key=/etc/etcd/etcd2.key endpoint health")
+}
+
+func TestRestore_StaticPodWaitsForEtcdToStop(t *testing.T) {
+    interval := restorePollInterval
+    restorePollInterval = time.Millisecond
+    defer func() { restorePollInterval = interval }()
+
+    running := map[string]int{"etcd1": 2}
+    _, context, commands, archive := restoreContext(t, func(node types.Node, command string) (*types.SSHOutput, error) {
+        if command == "pgrep -x etcd || true" && running[node.Host] > 0 {
+            running[node.Host]--
+            return &types.SSHOutput{Stdout: "4242\n"}, nil
+        }
+        return nil, nil
+    })
+    defer os.Remove(archive)
+    context.Opts = &types.EtcdRestoreOpts{
+        Archive:           archive,
+        DataDir:           "/var/lib/etcd",
+        StaticPodManifest: "/etc/kubernetes/manifests/etcd.yaml",
+        PeerScheme:        "https",
+        PeerPort:          2380,
+        Yes:               true,
+    }
+
+    Restore(context)
+
+    all := strings.Join(*commands, "\n")
+    assert.Regexp(t, `etcd1: mv /etc/kubernetes/manifests/etcd.yaml /etc/kubernetes/manifests/\.etcd\.yaml\.restore-\S+\n`+
+        `etcd2: mv /etc/kubernetes/manifests/etcd.yaml /etc/kubernetes/manifests/\.etcd\.yaml\.restore-\S+\n`+
+        `(etcd1: pgrep -x etcd \|\| true\n){3}etcd2: pgrep -x etcd \|\| true\n`+
+        `etcd1: rm -rf /var/lib/etcd.restore`, all)
+    assert.Regexp(t, `etcd2: mv /etc/kubernetes/manifests/\.etcd\.yaml\.restore-\S+ /etc/kubernetes/manifests/etcd.yaml`, all)
+}
+
+func TestRestore_FailurePrintsUndo(t *testing.T) {
+    interval := restorePollInterval
+    restorePollInterval = time.Millisecond
+    workFactor := ageScryptWorkFactor
+    ageScryptWorkFactor = 10
+    defer func() {
+        restorePollInterval = interval
+        ageScryptWorkFactor = workFactor
+    }()
+
+    patch := monkey.Patch(os.Exit, func(int) {
+        panic("exit")
+    })
+    defer patch.Unpatch()
+
+    outBuffer, context, commands, archive := restoreContext(t, func(node types.Node, command string) (*types.SSHOutput, error) {
+        if node.Host == "etcd2" && strings.HasSuffix(command, "endpoint health") {
+            return &types.SSHOutput{}, errors.New("connection refused")
+        }
+        return nil, nil
+    })
+    context.Config.Backup.Passphrase = "pass"
+    initParams(context)
+    encrypted := archive + etcdBackupEncryptedSuffix
+    assert.Nil(t, encryptBackup(archive, encrypted, mustBackupRecipients(t)))
+    defer os.Remove(encrypted)
+
+    context.Opts = &types.EtcdRestoreOpts{
+        Archive:    encrypted,
+        DataDir:    "/var/lib/etcd",
+        Service:    "etcd",
+        PeerScheme: "https",
+        PeerPort:   2380,
+        Yes:        true,
+        Sudo:       true,
+        Timeout:    10 * time.Millisecond,
+    }
+
+    assert.Panics(t, func() { Restore(context) })
+
+    out := outBuffer.String()
+    assert.Contains(t, out, "Etcd on node etcd2 (10.0.0.2) not healthy after 10ms: connection refused")
+    assert.Regexp(t, `etcd2 \(10.0.0.2\): sudo systemctl stop etcd\n.*etcd1 \(10.0.0.1\): sudo systemctl stop etcd\n`+
+        `.*etcd2 \(10.0.0.2\): sudo mv /var/lib/etcd /var/lib/etcd.restore\n.*etcd2 \(10.0.0.2\): sudo mv /var/lib/etcd.bak-\S+ /var/lib/etcd\n`+
+        `.*etcd1 \(10.0.0.1\): sudo mv /var/lib/etcd /var/lib/etcd.restore\n.*etcd1 \(10.0.0.1\): sudo mv /var/lib/etcd.bak-\S+ /var/lib/etcd\n`+
+        `.*etcd2 \(10.0.0.2\): sudo systemctl start etcd\n.*etcd1 \(10.0.0.1\): sudo systemctl start etcd\n`, out)
+
+    // the decrypted archive and the upload directories are removed although the restore exits
+    all := strings.Join(*commands, "\n")
+    assert.Contains(t, all, "etcd1: rm -rf /tmp/etcd-restore.a1b2c3")
+    uploaded := regexp.MustCompile(`etcd1: upload (\S+) `).FindStringSubmatch(all)
+    assert.NotNil(t, uploaded)
+    _, err := os.Stat(uploaded[1])
+    assert.True(t, os.IsNotExist(err))
+}
+
+func mustBackupRecipients(t *testing.T) []age.Recipient {
+    recipients, err := backupRecipients(nil)
+    assert.Nil(t, err)
+    return recipients
+}
@@ -32,12 +32,12 @@ func defaultContext() (*sshTest.MockExecutor, *bytes.Buffer, *types.CommandConte
                         {Host: "host3", IP:"2"},
                         {Host: "host2", IP:"1"},
                     },
-                    Certificates: []string{"/etc/kubernetes/certs/ca.pem"},
+                    Certificates: []types.Certificate{{Path: "/etc/kubernetes/certs/ca.pem"}},
                     Containers:   []string{"k8s_kube-apiserver", "k8s_kube-controller-manager", "k8s_kube-scheduler"},
                     Services:     []string{"docker"},
                     DiskUsage: types.DiskUsage{
-                        DirectoryUsage:  []string{"/var/log"},
-                        FileSystemUsage: []string{"/dev/sda1"},
+                        DirectoryUsage:  []types.DirectoryUsage{{Path: "/var/log"}},
+                        FileSystemUsage: []types.FileSystemUsage{{Path: "/dev/sda1"}},
                     },
                     Kubernetes: types.Kubernetes{
                         Resources: []types.KubernetesResource {
@@ -3,6 +3,7 @@ package pkg
 import (
 	"fmt"
 
+	"github.com/mrahbar/kubernetes-inspector/integration"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/mrahbar/kubernetes-inspector/util"
 	"os"
@@ -25,7 +26,7 @@ func Scp(cmdParams *types.CommandContext) {
 	runGeneric(config, &scpOpts.GenericOpts, initializeScp, scp)
 }
 
-func initializeScp(target string, node string) {
+func initializeScp(target string, node string, printer integration.LogWriter) {
 	if !regexp.MustCompile("^(up|u|down|d){1}$").MatchString(target) {
 		printer.PrintCritical("Direction must either be 'up' or 'down' resp. first letter. Provided: '%s'", target)
 	}
@@ -34,19 +35,21 @@ func initializeScp(target string, node string) {
 	printer.PrintNewLine()
 }
 
-func scp(target string) {
+func scp(target string, executor types.CommandExecutor, printer integration.LogWriter) {
 	var scpErr error
 	direction := ""
+	localPath := scpOpts.LocalPath
+	remotePath := scpOpts.RemotePath
 
-	remoteType, err := typeOfRemotePath()
+	remoteType, err := typeOfRemotePath(remotePath, executor)
 	if err != nil {
-		printer.PrintErr("Remote path %s is unprocessable: %s", scpOpts.RemotePath, err)
+		printer.PrintErr("Remote path %s is unprocessable: %s", remotePath, err)
 		return
 	}
 
-	localType, err := typeOfLocalPath()
+	localType, err := typeOfLocalPath(localPath)
 	if err != nil {
-		printer.PrintErr("Local path %s is unprocessable: %s", scpOpts.LocalPath, err)
+		printer.PrintErr("Local path %s is unprocessable: %s", localPath, err)
 		return
 	}
 
@@ -55,53 +58,53 @@ func scp(target string) {
 
 		if dirType == localType {
 			if fileType == remoteType {
-				printer.PrintCritical("Can not upload directory %s to remote file %s. Please choose a remote directory.", scpOpts.LocalPath, scpOpts.RemotePath)
+				printer.PrintCritical("Can not upload directory %s to remote file %s. Please choose a remote directory.", localPath, remotePath)
 			}
 
-			scpErr = cmdExecutor.UploadDirectory(scpOpts.RemotePath, scpOpts.LocalPath)
+			scpErr = executor.UploadDirectory(remotePath, localPath)
 		} else {
 			if fileType == remoteType {
-				printer.PrintCritical("Can not upload local file %s to existing remote file %s. Please choose a remote directory or a new remote filename.", scpOpts.LocalPath, scpOpts.RemotePath)
+				printer.PrintCritical("Can not upload local file %s to existing remote file %s. Please choose a remote directory or a new remote filename.", localPath, remotePath)
 			} else if dirType == remoteType {
-				scpOpts.RemotePath = path.Join(scpOpts.RemotePath, filepath.Base(scpOpts.LocalPath))
+				remotePath = path.Join(remotePath, filepath.Base(localPath))
 			} //noneType means remote file name was specified but file does not exists, ssh.UploadFile will create it
 
-			scpErr = cmdExecutor.UploadFile(scpOpts.RemotePath, scpOpts.LocalPath)
+			scpErr = executor.UploadFile(remotePath, localPath)
 		}
 	} else if regexp.MustCompile("^(down|d){1}$").MatchString(target) {
 		direction = "<-"
 
 		if dirType == remoteType {
 			if fileType == localType {
-				printer.PrintCritical("Can not download remote folder %s to local file %s. Please choose a local directory.", scpOpts.RemotePath, scpOpts.LocalPath)
+				printer.PrintCritical("Can not download remote folder %s to local file %s. Please choose a local directory.", remotePath, localPath)
 			}
 
-			scpErr = cmdExecutor.DownloadDirectory(scpOpts.RemotePath, scpOpts.LocalPath)
+			scpErr = executor.DownloadDirectory(remotePath, localPath)
 		} else if fileType == remoteType {
 			if dirType == localType {
-				scpOpts.LocalPath = filepath.Join(scpOpts.LocalPath, filepath.Base(scpOpts.RemotePath))
+				localPath = filepath.Join(localPath, filepath.Base(remotePath))
 			} else {
-				printer.PrintCritical("Can not download remote file %s to existing local file %s. Please choose a local directory or a new local filename.", scpOpts.RemotePath, scpOpts.LocalPath)
+				printer.PrintCritical("Can not download remote file %s to existing local file %s. Please choose a local directory or a new local filename.", remotePath, localPath)
 			} //noneType means local file name was specified but file does not exists, ssh.DownloadFile will create it
 
-			scpErr = cmdExecutor.DownloadFile(scpOpts.RemotePath, scpOpts.LocalPath)
+			scpErr = executor.DownloadFile(remotePath, localPath)
 		}
 	}
 
-	printer.Print(fmt.Sprintf("Result on node %s:", util.ToNodeLabel(cmdExecutor.GetNode())))
+	printer.Print(fmt.Sprintf("Result on node %s:", util.ToNodeLabel(executor.GetNode())))
 	if scpErr != nil {
-		printer.PrintErr("Scp failed %s %s %s: %s", scpOpts.LocalPath, direction, scpOpts.RemotePath, scpErr)
+		printer.PrintErr("Scp failed %s %s %s: %s", localPath, direction, remotePath, scpErr)
 	} else if direction != "" {
-		printer.PrintOk("Scp %s %s %s finished", scpOpts.LocalPath, direction, scpOpts.RemotePath)
+		printer.PrintOk("Scp %s %s %s finished", localPath, direction, remotePath)
 	}
 
 	printer.PrintNewLine()
 }
 
-func typeOfRemotePath() (string, error) {
+func typeOfRemotePath(remotePath string, executor types.CommandExecutor) (string, error) {
 	command := fmt.Sprintf(`if [ -d %s ] ; then echo "%s" ; elif [ -f %s ] ; then echo "%s"; else echo "%s"; fi;`,
-		scpOpts.RemotePath, dirType, scpOpts.RemotePath, fileType, noneType)
-	sshOut, err := cmdExecutor.PerformCmd(command, false)
+		remotePath, dirType, remotePath, fileType, noneType)
+	sshOut, err := executor.PerformCmd(command, false)
 
 	if err != nil {
 		return "", err
@@ -110,8 +113,8 @@ func typeOfRemotePath() (string, error) {
 	return sshOut.Stdout, nil
 }
 
-func typeOfLocalPath() (string, error) {
-	localStat, err := os.Stat(scpOpts.LocalPath)
+func typeOfLocalPath(localPath string) (string, error) {
+	localStat, err := os.Stat(localPath)
 	if err != nil {
 		return noneType, err
 	}
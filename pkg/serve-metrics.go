@@ -0,0 +1,185 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type metricSample struct {
+	labels []string
+	value  float64
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	samples []metricSample
+}
+
+// metricSet collects gauges in the Prometheus text exposition format, families are written in order of their first sample
+type metricSet struct {
+	families []*metricFamily
+	index    map[string]*metricFamily
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{index: map[string]*metricFamily{}}
+}
+
+// add records a sample, labels are given as alternating names and values
+func (m *metricSet) add(name string, help string, value float64, labels ...string) {
+	family, ok := m.index[name]
+	if !ok {
+		family = &metricFamily{name: name, help: help}
+		m.index[name] = family
+		m.families = append(m.families, family)
+	}
+	family.samples = append(family.samples, metricSample{labels: labels, value: value})
+}
+
+func (m *metricSet) write(w io.Writer) {
+	for _, family := range m.families {
+		fmt.Fprintf(w, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", family.name)
+
+		for _, sample := range family.samples {
+			labels := []string{}
+			for i := 0; i+1 < len(sample.labels); i += 2 {
+				labels = append(labels, fmt.Sprintf(`%s="%s"`, sample.labels[i], labelEscaper.Replace(sample.labels[i+1])))
+			}
+
+			if len(labels) > 0 {
+				fmt.Fprintf(w, "%s{%s} %s\n", family.name, strings.Join(labels, ","), strconv.FormatFloat(sample.value, 'g', -1, 64))
+			} else {
+				fmt.Fprintf(w, "%s %s\n", family.name, strconv.FormatFloat(sample.value, 'g', -1, 64))
+			}
+		}
+	}
+}
+
+func writeMetrics(w io.Writer, report *types.ClusterStatusReport, duration time.Duration, now time.Time) {
+	m := newMetricSet()
+
+	if report == nil {
+		m.add("kubespector_up", "Whether results of a completed check run are available", 0)
+		m.write(w)
+		return
+	}
+
+	m.add("kubespector_up", "Whether results of a completed check run are available", 1)
+	m.add("kubespector_last_run_timestamp_seconds", "Start time of the latest check run", float64(report.Time.Unix()))
+	m.add("kubespector_last_run_duration_seconds", "Duration of the latest check run", duration.Seconds())
+	m.add("kubespector_status", "Overall verdict of the latest check run: 0 OK, 1 WARN, 2 ERROR, 3 UNKNOWN",
+		float64(exitCode(report.Summary.Status)))
+
+	for _, status := range []struct {
+		name  string
+		count int
+	}{
+		{types.STATUS_OK, report.Summary.Ok},
+		{types.STATUS_WARN, report.Summary.Warn},
+		{types.STATUS_ERROR, report.Summary.Error},
+		{types.STATUS_UNKNOWN, report.Summary.Unknown},
+		{types.STATUS_IGNORED, report.Summary.Ignored},
+		{types.STATUS_SKIPPED, report.Summary.Skipped},
+	} {
+		m.add("kubespector_results", "Number of check results by status", float64(status.count), "status", status.name)
+	}
+
+	for _, stats := range report.NodeStats {
+		up := 1.0
+		if stats.Error != "" {
+			up = 0
+		}
+		m.add("kubespector_node_stats_up", "Whether uptime and load could be retrieved from the node", up, "node", stats.Node)
+		if up == 0 {
+			continue
+		}
+
+		m.add("kubespector_node_uptime_seconds", "Uptime of the node", stats.UptimeSeconds, "node", stats.Node)
+		m.add("kubespector_node_load1", "1m load average of the node", stats.Load1, "node", stats.Node)
+		m.add("kubespector_node_load5", "5m load average of the node", stats.Load5, "node", stats.Node)
+		m.add("kubespector_node_load15", "15m load average of the node", stats.Load15, "node", stats.Node)
+		m.add("kubespector_node_processes", "Number of processes on the node", float64(stats.Processes), "node", stats.Node)
+	}
+
+	for _, group := range report.Groups {
+		for _, check := range group.Checks {
+			for _, node := range check.Nodes {
+				for _, result := range node.Results {
+					addResultMetrics(m, group.Name, check.Name, node.Node, result, now)
+				}
+			}
+		}
+	}
+
+	m.write(w)
+}
+
+func addResultMetrics(m *metricSet, group string, check string, node string, result types.CheckResult, now time.Time) {
+	if result.Status != types.STATUS_IGNORED && result.Status != types.STATUS_SKIPPED {
+		m.add("kubespector_check_status", "Status of a check result: 0 OK, 1 WARN, 2 ERROR, 3 UNKNOWN", float64(exitCode(result.Status)),
+			"group", group, "check", check, "node", node, "target", result.Target)
+	}
+
+	switch check {
+	case types.SERVICES_CHECKNAME:
+		if state, ok := result.Values["state"]; ok {
+			m.add("kubespector_service_active", "Whether the systemd service is active", boolToFloat(state == "active"),
+				"group", group, "node", node, "service", result.Target)
+		}
+	case types.CONTAINERS_CHECKNAME:
+		if state, ok := result.Values["state"]; ok {
+			m.add("kubespector_container_running", "Whether the container is running", boolToFloat(state == "running"),
+				"group", group, "node", node, "container", result.Target)
+		}
+	case types.DISKUSAGE_CHECKNAME:
+		if used, ok := toFloat(result.Values["usedPercent"]); ok {
+			m.add("kubespector_filesystem_used_percent", "Used space of the file system in percent", used,
+				"group", group, "node", node, "filesystem", result.Target)
+		}
+		if used, ok := toFloat(result.Values["inodesUsedPercent"]); ok {
+			m.add("kubespector_filesystem_inodes_used_percent", "Used inodes of the file system in percent", used,
+				"group", group, "node", node, "filesystem", strings.TrimSuffix(result.Target, " (inodes)"))
+		}
+		if size, ok := result.Values["size"].(string); ok {
+			if bytes, err := util.ParseSize(size); err == nil {
+				m.add("kubespector_directory_size_bytes", "Size of the directory", float64(bytes),
+					"group", group, "node", node, "directory", result.Target)
+			}
+		}
+	case types.CERTIFICATES_CHECKNAME:
+		if notAfter, ok := result.Values["notAfter"].(time.Time); ok {
+			m.add("kubespector_certificate_expiry_seconds", "Seconds until the certificate expires, negative when expired",
+				notAfter.Sub(now).Seconds(), "group", group, "node", node, "path", result.Target)
+		}
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
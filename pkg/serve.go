@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/integration"
+	"github.com/mrahbar/kubernetes-inspector/types"
+)
+
+// metricsState holds the report of the latest check run which is rendered on every scrape
+type metricsState struct {
+	sync.RWMutex
+	report   *types.ClusterStatusReport
+	duration time.Duration
+}
+
+func Serve(cmdParams *types.CommandContext) {
+	initParams(cmdParams)
+	opts := cmdParams.Opts.(*types.ServeOpts)
+	clusterStatusOpts = &opts.ClusterStatusOpts
+
+	if opts.Interval <= 0 {
+		printer.PrintCritical("Interval must be greater than zero")
+	}
+
+	groups, err := clusterStatusGroups()
+	if err != nil {
+		printer.PrintCritical("%s", err)
+	}
+	// printer is swapped by the collector, keep the real one for the server
+	out := printer
+	state := &metricsState{}
+	go collectMetrics(state, groups, opts.Interval, out)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", state)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<html><head><title>kubespector</title></head><body><a href="/metrics">Metrics</a></body></html>`))
+	})
+
+	out.PrintInfo("Serving metrics on %s/metrics, checks run every %s", opts.Listen, opts.Interval)
+	if err := http.ListenAndServe(opts.Listen, mux); err != nil {
+		out.PrintCritical("Failed to serve metrics: %s", err)
+	}
+}
+
+func collectMetrics(state *metricsState, groups []string, interval time.Duration, out integration.LogWriter) {
+	for {
+		start := time.Now()
+		// The output of a single run is not of interest, results are only exposed as metrics
+		printer = integration.NewDiscardLogWriter(out)
+		report := runClusterStatus(groups)
+		printer = out
+
+		duration := time.Since(start)
+		state.Lock()
+		state.report = report
+		state.duration = duration
+		state.Unlock()
+
+		out.PrintDebug("Check run finished in %s with status %s", duration, report.Summary.Status)
+
+		if duration < interval {
+			time.Sleep(interval - duration)
+		}
+	}
+}
+
+func (s *metricsState) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.RLock()
+	report, duration := s.report, s.duration
+	s.RUnlock()
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, report, duration, time.Now())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,67 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "net/http/httptest"
+    "strings"
+    "time"
+)
+
+func TestServe_MetricsBeforeFirstRun(t *testing.T) {
+    state := &metricsState{}
+    recorder := httptest.NewRecorder()
+
+    state.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+    assert.Equal(t, 200, recorder.Code)
+    assert.Contains(t, recorder.Body.String(), "kubespector_up 0\n")
+}
+
+func TestServe_Metrics(t *testing.T) {
+    mockExecutor, _, context := defaultContext()
+    context.Opts = &types.ServeOpts{
+        ClusterStatusOpts: types.ClusterStatusOpts{
+            Groups: types.MASTER_GROUPNAME,
+            Checks: types.SERVICES_CHECKNAME + "," + types.CONTAINERS_CHECKNAME + "," + types.DISKUSAGE_CHECKNAME,
+        },
+    }
+    initParams(context)
+    clusterStatusOpts = &context.Opts.(*types.ServeOpts).ClusterStatusOpts
+
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        switch {
+        case command == "/bin/cat /proc/uptime":
+            return &types.SSHOutput{Stdout: "3600.5 100.0"}, nil
+        case command == "/bin/cat /proc/loadavg":
+            return &types.SSHOutput{Stdout: "0.50 0.25 0.10 1/120 4321"}, nil
+        case strings.HasPrefix(command, "systemctl"):
+            return &types.SSHOutput{Stdout: "failed"}, nil
+        case strings.HasPrefix(command, "docker"):
+            return &types.SSHOutput{Stdout: "running"}, nil
+        case strings.HasPrefix(command, "df"):
+            return &types.SSHOutput{Stdout: "/dev/sda1 20G 14G 6G 70% /"}, nil
+        default:
+            return &types.SSHOutput{Stdout: "2K /var/log"}, nil
+        }
+    }
+
+    state := &metricsState{report: runClusterStatus(clusterGroups(t)), duration: 2 * time.Second}
+    recorder := httptest.NewRecorder()
+    state.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+    body := recorder.Body.String()
+    assert.Contains(t, recorder.Header().Get("Content-Type"), "text/plain")
+    assert.Contains(t, body, "# TYPE kubespector_up gauge\nkubespector_up 1\n")
+    assert.Contains(t, body, "kubespector_last_run_duration_seconds 2\n")
+    assert.Contains(t, body, "kubespector_status 2\n")
+    assert.Contains(t, body, `kubespector_node_uptime_seconds{node="host1"} 3600.5`)
+    assert.Contains(t, body, `kubespector_node_load5{node="host2"} 0.25`)
+    assert.Contains(t, body, `kubespector_service_active{group="Master",node="host3",service="docker"} 0`)
+    assert.Contains(t, body, `kubespector_check_status{group="Master",check="Services",node="host3",target="docker"} 2`)
+    assert.Contains(t, body, `kubespector_container_running{group="Master",node="host1",container="k8s_kube-apiserver"} 1`)
+    assert.Contains(t, body, `kubespector_filesystem_used_percent{group="Master",node="host1",filesystem="/dev/sda1"} 70`)
+    assert.Contains(t, body, `kubespector_directory_size_bytes{group="Master",node="host1",directory="/var/log"} 2048`)
+    assert.Equal(t, 1, strings.Count(body, "# HELP kubespector_service_active "))
+}
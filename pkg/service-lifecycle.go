@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/mrahbar/kubernetes-inspector/integration"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+// serviceAction is a systemctl verb applied to a service on every selected node
+type serviceAction struct {
+	verb string
+	// title, progress and done describe the action in messages, e.g. Starting, starting and started
+	title    string
+	progress string
+	done     string
+}
+
+var serviceActions = map[string]serviceAction{
+	"start":   {"start", "Starting", "starting", "started"},
+	"enable":  {"enable", "Enabling", "enabling", "enabled"},
+	"disable": {"disable", "Disabling", "disabling", "disabled"},
+	"mask":    {"mask", "Masking", "masking", "masked"},
+	"unmask":  {"unmask", "Unmasking", "unmasking", "unmasked"},
+}
+
+// daemonReloadTarget stands in for the service since daemon-reload applies to the systemd manager itself
+const daemonReloadTarget = "systemd"
+
+// ServiceAction runs one of the serviceActions like start or enable for the service on the selected nodes
+func ServiceAction(name string, cmdParams *types.CommandContext) {
+	initParams(cmdParams)
+	opts := cmdParams.Opts.(*types.GenericOpts)
+	action, ok := serviceActions[name]
+	if !ok {
+		printer.PrintCritical("Unknown service action %s", name)
+	}
+
+	initializer := func(service string, node string, printer integration.LogWriter) {
+		printer.PrintHeader(fmt.Sprintf("%s service %v on node %s", action.title, service, node), '=')
+		printer.PrintNewLine()
+	}
+	processor := func(service string, executor types.CommandExecutor, printer integration.LogWriter) {
+		_, err := executor.PerformCmd(fmt.Sprintf("systemctl %s %s", action.verb, service), opts.Sudo)
+
+		printer.Print("Result on node %s:", util.ToNodeLabel(executor.GetNode()))
+		if err != nil {
+			printer.PrintErr("Error %s service %s: %s", action.progress, service, err)
+		} else {
+			printer.PrintOk("Service %s %s.", service, action.done)
+		}
+		printer.PrintNewLine()
+	}
+
+	runGeneric(config, opts, initializer, processor)
+}
+
+// DaemonReload reloads the unit files of systemd on the selected nodes
+func DaemonReload(cmdParams *types.CommandContext) {
+	initParams(cmdParams)
+	opts := cmdParams.Opts.(*types.GenericOpts)
+	opts.TargetArg = daemonReloadTarget
+
+	initializer := func(_ string, node string, printer integration.LogWriter) {
+		printer.PrintHeader(fmt.Sprintf("Reloading systemd manager configuration on node %s", node), '=')
+		printer.PrintNewLine()
+	}
+	processor := func(_ string, executor types.CommandExecutor, printer integration.LogWriter) {
+		_, err := executor.PerformCmd("systemctl daemon-reload", opts.Sudo)
+
+		printer.Print("Result on node %s:", util.ToNodeLabel(executor.GetNode()))
+		if err != nil {
+			printer.PrintErr("Error reloading systemd manager configuration: %s", err)
+		} else {
+			printer.PrintOk("Systemd manager configuration reloaded.")
+		}
+		printer.PrintNewLine()
+	}
+
+	runGeneric(config, opts, initializer, processor)
+}
@@ -0,0 +1,111 @@
+package pkg
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "fmt"
+    "strings"
+    sshTest "github.com/mrahbar/kubernetes-inspector/ssh/test"
+)
+
+func TestServiceAction_Ok(t *testing.T) {
+    for action, expected := range map[string]string{
+        "start":   "Service docker started.",
+        "enable":  "Service docker enabled.",
+        "disable": "Service docker disabled.",
+        "mask":    "Service docker masked.",
+        "unmask":  "Service docker unmasked.",
+    } {
+        mockExecutor, outBuffer, cmdContext := defaultContext()
+        cmdContext.Opts = &types.GenericOpts{
+            TargetArg: "docker",
+            NodeArg: "host1",
+        }
+
+        called := false
+        mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+            called = true
+            assert.Equal(t, "systemctl "+action+" docker", command)
+            return &types.SSHOutput{}, nil
+        }
+
+        ServiceAction(action, cmdContext)
+        assert.True(t, called)
+        assert.Contains(t, outBuffer.String(), expected)
+    }
+}
+
+func TestServiceAction_Error(t *testing.T) {
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.GenericOpts{
+        TargetArg: "docker",
+        NodeArg: "host1",
+    }
+
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        return &types.SSHOutput{}, fmt.Errorf("Unit docker.service is masked")
+    }
+
+    ServiceAction("start", cmdContext)
+
+    out := outBuffer.String()
+    assert.Contains(t, out, "Error starting service docker: Unit docker.service is masked")
+}
+
+func TestDaemonReload(t *testing.T) {
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.GenericOpts{
+        GroupArg: types.MASTER_GROUPNAME,
+    }
+
+    calls := 0
+    mockExecutor.MockPerformCmd = func(command string, sudo bool) (*types.SSHOutput, error) {
+        calls++
+        assert.Equal(t, "systemctl daemon-reload", command)
+        return &types.SSHOutput{}, nil
+    }
+
+    DaemonReload(cmdContext)
+    assert.Equal(t, 3, calls)
+    assert.Contains(t, outBuffer.String(), "Systemd manager configuration reloaded.")
+}
+
+func TestServiceShow_Drift(t *testing.T) {
+    mockExecutor, outBuffer, cmdContext := defaultContext()
+    cmdContext.Opts = &types.GenericOpts{
+        TargetArg: "kubelet",
+        GroupArg: types.MASTER_GROUPNAME,
+    }
+
+    execStart := "ExecStart={ path=/usr/bin/kubelet ; argv[]=/usr/bin/kubelet %s ; ignore_errors=no ; " +
+        "start_time=[Mon 2018-03-19 08:00:00 UTC] ; stop_time=[n/a] ; pid=%d ; code=(null) ; status=0/0 }"
+    mockExecutor.MockForNode = func(node types.Node) types.CommandExecutor {
+        return &sshTest.MockExecutor{
+            Node: node,
+            MockPerformCmd: func(command string, sudo bool) (*types.SSHOutput, error) {
+                assert.Equal(t, "systemctl show kubelet --property=ActiveEnterTimestamp,NRestarts,MainPID,MemoryCurrent,ExecStart", command)
+                switch node.Host {
+                case "host3":
+                    return &types.SSHOutput{}, fmt.Errorf("SSH failed")
+                case "host2":
+                    return &types.SSHOutput{Stdout: strings.Join([]string{
+                        "ActiveEnterTimestamp=Mon 2018-03-19 09:00:00 UTC", "NRestarts=4", "MainPID=812",
+                        "MemoryCurrent=18446744073709551615", fmt.Sprintf(execStart, "--max-pods=250", 812)}, "\n")}, nil
+                }
+                return &types.SSHOutput{Stdout: strings.Join([]string{
+                    "ActiveEnterTimestamp=Mon 2018-03-19 08:00:00 UTC", "NRestarts=0", "MainPID=1042",
+                    "MemoryCurrent=52428800", fmt.Sprintf(execStart, "--max-pods=110", 1042)}, "\n")}, nil
+            },
+        }
+    }
+    // host2 and host1 disagree, the tie is won by host1 which comes first
+    ServiceShow(cmdContext)
+
+    out := outBuffer.String()
+    assert.Contains(t, out, "NODE       ACTIVE SINCE                 RESTARTS  MAIN PID  MEMORY    EXEC START")
+    assert.Contains(t, out, "host1 (3)  Mon 2018-03-19 08:00:00 UTC  0         1042      50.0 MiB  /usr/bin/kubelet --max-pods=110")
+    assert.Contains(t, out, "host2 (1)  Mon 2018-03-19 09:00:00 UTC  4         812       -         /usr/bin/kubelet --max-pods=250")
+    assert.Contains(t, out, "ExecStart on node host2 (1) differs from the other nodes: /usr/bin/kubelet --max-pods=250")
+    assert.Contains(t, out, "Error reading properties of service kubelet on node host3 (2): SSH failed")
+}
@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+// serviceShowProperties are the systemctl show properties printed for every node in this order
+var serviceShowProperties = []string{"ActiveEnterTimestamp", "NRestarts", "MainPID", "MemoryCurrent", "ExecStart"}
+
+// serviceDriftProperties are expected to be equal on all nodes, a node deviating from the majority is reported
+var serviceDriftProperties = []string{"ExecStart"}
+
+var execStartArgvRegex = regexp.MustCompile(`argv\[\]=([^;]*)`)
+
+// ServiceShow compares the key properties of the service across the selected nodes in one table
+func ServiceShow(cmdParams *types.CommandContext) {
+	initParams(cmdParams)
+	opts := cmdParams.Opts.(*types.GenericOpts)
+	nodes := selectNodes(config, opts)
+	service := opts.TargetArg
+
+	printer.PrintHeader(fmt.Sprintf("Properties of service %s on %d nodes", service, len(nodes)), '=')
+	printer.PrintNewLine()
+
+	command := fmt.Sprintf("systemctl show %s --property=%s", service, strings.Join(serviceShowProperties, ","))
+	properties := make([]map[string]string, len(nodes))
+	problems := []string{}
+	statuses := make([]string, len(nodes))
+	for i, node := range nodes {
+		statuses[i] = types.STATUS_OK
+		sshOut, err := cmdExecutor.ForNode(node).PerformCmd(command, opts.Sudo)
+		if err != nil {
+			statuses[i] = types.STATUS_ERROR
+			problems = append(problems, fmt.Sprintf("Error reading properties of service %s on node %s: %s", service, util.ToNodeLabel(node), err))
+			continue
+		}
+		properties[i] = parseServiceProperties(sshOut.Stdout)
+	}
+
+	for _, property := range serviceDriftProperties {
+		expected := majorityValue(properties, property)
+		for i, values := range properties {
+			if values != nil && values[property] != expected {
+				statuses[i] = worseStatus(statuses[i], types.STATUS_WARN)
+				problems = append(problems, fmt.Sprintf("%s on node %s differs from the other nodes: %s",
+					property, util.ToNodeLabel(nodes[i]), values[property]))
+			}
+		}
+	}
+
+	rows := [][]string{}
+	for i, node := range nodes {
+		row := []string{util.ToNodeLabel(node)}
+		for _, property := range serviceShowProperties {
+			row = append(row, formatServiceProperty(property, properties[i][property]))
+		}
+		rows = append(rows, row)
+	}
+	printTable([]string{"NODE", "ACTIVE SINCE", "RESTARTS", "MAIN PID", "MEMORY", "EXEC START"}, rows, statuses)
+	printer.PrintNewLine()
+
+	for _, problem := range problems {
+		printer.PrintWarn("%s", problem)
+	}
+	if len(problems) == 0 {
+		printer.PrintOk("Service %s is configured alike on all nodes", service)
+	}
+}
+
+// parseServiceProperties parses the Key=Value lines of systemctl show, the command line of ExecStart is kept without
+// the runtime details like pid and start time which differ between nodes anyway
+func parseServiceProperties(output string) map[string]string {
+	properties := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key, value := parts[0], parts[1]
+		if key == "ExecStart" {
+			if match := execStartArgvRegex.FindStringSubmatch(value); match != nil {
+				value = strings.TrimSpace(match[1])
+			}
+		}
+		if previous, ok := properties[key]; ok && previous != "" {
+			value = previous + "; " + value
+		}
+		properties[key] = value
+	}
+
+	return properties
+}
+
+// majorityValue returns the value of property on most nodes, ties are won by the value seen first
+func majorityValue(properties []map[string]string, property string) string {
+	counts := map[string]int{}
+	majority := ""
+	for _, values := range properties {
+		if values == nil {
+			continue
+		}
+		value := values[property]
+		counts[value]++
+		if _, ok := counts[majority]; !ok || counts[value] > counts[majority] {
+			majority = value
+		}
+	}
+
+	return majority
+}
+
+func formatServiceProperty(property string, value string) string {
+	if value == "" || value == "[not set]" {
+		return "-"
+	}
+	if property == "MemoryCurrent" {
+		bytes, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || bytes == math.MaxUint64 {
+			return "-"
+		}
+		return formatBytes(int64(bytes))
+	}
+
+	return value
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"fmt"
 
+	"github.com/mrahbar/kubernetes-inspector/integration"
 	"github.com/mrahbar/kubernetes-inspector/ssh"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/mrahbar/kubernetes-inspector/util"
@@ -16,16 +17,16 @@ func Status(cmdParams *types.CommandContext) {
 	runGeneric(config, statusOpts, initializeStatusService, statusService)
 }
 
-func initializeStatusService(service string, node string) {
+func initializeStatusService(service string, node string, printer integration.LogWriter) {
     printer.PrintHeader(fmt.Sprintf("Checking status of service %v on node %s",
         service, node), '=')
     printer.PrintNewLine()
 }
 
-func statusService(service string) {
-    sshOut, err := cmdExecutor.PerformCmd(fmt.Sprintf("systemctl status %s -l", service), statusOpts.Sudo)
+func statusService(service string, executor types.CommandExecutor, printer integration.LogWriter) {
+    sshOut, err := executor.PerformCmd(fmt.Sprintf("systemctl status %s -l", service), statusOpts.Sudo)
 
-    printer.Print(fmt.Sprintf("Result on node %s:", util.ToNodeLabel(cmdExecutor.GetNode())))
+    printer.Print(fmt.Sprintf("Result on node %s:", util.ToNodeLabel(executor.GetNode())))
 	if err != nil {
         printer.PrintErr("Error checking status of service %s: %s", service, err)
 	} else {
@@ -3,6 +3,7 @@ package pkg
 import (
 	"fmt"
 
+	"github.com/mrahbar/kubernetes-inspector/integration"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/mrahbar/kubernetes-inspector/util"
 )
@@ -15,15 +16,15 @@ func Stop(cmdParams *types.CommandContext) {
 	runGeneric(config, stopOpts, initializeStopService, stopService)
 }
 
-func initializeStopService(service string, node string) {
+func initializeStopService(service string, node string, printer integration.LogWriter) {
     printer.PrintHeader(fmt.Sprintf("Stopping service %v on node %s", service, node), '=')
     printer.PrintNewLine()
 }
 
-func stopService(service string) {
-    _, err := cmdExecutor.PerformCmd(fmt.Sprintf("systemctl stop %s", service), stopOpts.Sudo)
+func stopService(service string, executor types.CommandExecutor, printer integration.LogWriter) {
+    _, err := executor.PerformCmd(fmt.Sprintf("systemctl stop %s", service), stopOpts.Sudo)
 
-    printer.Print(fmt.Sprintf("Result on node %s:", util.ToNodeLabel(cmdExecutor.GetNode())))
+    printer.Print(fmt.Sprintf("Result on node %s:", util.ToNodeLabel(executor.GetNode())))
 	if err != nil {
         printer.PrintErr("Error stopping service %s: %s", service, err)
 	} else {
@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mrahbar/kubernetes-inspector/integration"
+	"github.com/mrahbar/kubernetes-inspector/ssh"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+// lineWriter passes every complete line written to it to print, a trailing partial line is passed on Flush.
+// Writers of the same command share mu since stdout and stderr are written concurrently.
+type lineWriter struct {
+	mu    *sync.Mutex
+	print func(line string)
+	buf   []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.print(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.print(strings.TrimSuffix(string(w.buf), "\r"))
+		w.buf = nil
+	}
+}
+
+// lockedWriter serializes the writes of stdout and stderr into the same writer
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// resultSource names where a result comes from, like node host1 or pod kube-system/kube-dns
+type resultSource struct {
+	kind string
+	name string
+}
+
+func nodeSource(node types.Node) resultSource {
+	return resultSource{kind: "node", name: util.ToNodeLabel(node)}
+}
+
+func (s resultSource) String() string {
+	return fmt.Sprintf("%s %s", s.kind, s.name)
+}
+
+// streamCmd runs command and prints its output line by line prefixed with label while it arrives
+func streamCmd(label string, command string, sudo bool, executor types.CommandExecutor, printer integration.LogWriter) (*types.SSHOutput, error) {
+	mu := &sync.Mutex{}
+	stdout := &lineWriter{mu: mu, print: func(line string) { printer.Print("%s | %s", label, line) }}
+	stderr := &lineWriter{mu: mu, print: func(line string) { printer.PrintWarn("%s | %s", label, line) }}
+
+	sshOut, err := executor.PerformCmdStreaming(context.Background(), command, sudo, stdout, stderr)
+	stdout.Flush()
+	stderr.Flush()
+	return sshOut, err
+}
+
+// printResult runs command and prints its output, which is appended to fileOutput if given.
+// Unless buffered the output is streamed instead of being collected until the command exited.
+func printResult(source resultSource, command string, sudo bool, buffered bool, fileOutput string, executor types.CommandExecutor, printer integration.LogWriter) {
+	printer.Print(fmt.Sprintf("Result on %s:", source))
+
+	if buffered {
+		printBufferedResult(source, command, sudo, fileOutput, executor, printer)
+		printer.PrintNewLine()
+		return
+	}
+
+	if fileOutput != "" {
+		streamResultToFile(source, command, sudo, fileOutput, executor, printer)
+		printer.PrintNewLine()
+		return
+	}
+
+	if _, err := streamCmd(source.name, command, sudo, executor, printer); err != nil {
+		printer.PrintErr("Error executing command: %s", err)
+	}
+	printer.PrintNewLine()
+}
+
+// streamResultToFile streams the output into a temporary file of its own, which is appended to fileOutput once the
+// output of the node is flushed. Concurrently processed nodes thereby never interleave in fileOutput.
+func streamResultToFile(source resultSource, command string, sudo bool, fileOutput string, executor types.CommandExecutor, printer integration.LogWriter) {
+	tmp, err := ioutil.TempFile("", "kubespector-result")
+	if err != nil {
+		printer.PrintWarn("Failed to write to output file %s forwarding to screen: %s", fileOutput, err)
+		if _, err := streamCmd(source.name, command, sudo, executor, printer); err != nil {
+			printer.PrintErr("Error executing command: %s", err)
+		}
+		return
+	}
+
+	fmt.Fprintf(tmp, "Result of '%s' on %s:\n\n", command, source)
+	out := &lockedWriter{w: tmp}
+	_, cmdErr := executor.PerformCmdStreaming(context.Background(), command, sudo, out, out)
+	fmt.Fprint(tmp, "\n\n")
+	tmp.Close()
+
+	atFlush(printer, func(printer integration.LogWriter) {
+		if err := appendFile(fileOutput, tmp.Name()); err != nil {
+			printer.PrintWarn("Failed to write to output file %s, the result is kept in %s: %s", fileOutput, tmp.Name(), err)
+			return
+		}
+		os.Remove(tmp.Name())
+		if cmdErr != nil {
+			printer.PrintErr("Error executing command: %s", cmdErr)
+		} else {
+			printer.PrintOk("Result written to file")
+		}
+	})
+}
+
+// appendFile appends the content of src to the existing file dst
+func appendFile(dst string, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func printBufferedResult(source resultSource, command string, sudo bool, fileOutput string, executor types.CommandExecutor, printer integration.LogWriter) {
+	sshOut, err := executor.PerformCmd(command, sudo)
+	if err != nil {
+		printer.PrintErr("Error executing command: %s", err)
+		return
+	}
+
+	result := ssh.CombineOutput(sshOut)
+	if fileOutput != "" {
+		out := fmt.Sprintf("Result of '%s' on %s:\n\n%s\n\n", command, source, result)
+		atFlush(printer, func(printer integration.LogWriter) {
+			err := util.WriteOutputFile(fileOutput, out)
+			if err != nil {
+				printer.PrintWarn("Failed to write to output file %s forwarding to screen: %s", fileOutput, err)
+				printer.PrintOk(result)
+			} else {
+				printer.PrintOk("Result written to file")
+			}
+		})
+	} else {
+		printer.PrintOk(result)
+	}
+}
+
+// atFlush runs call once the output recorded so far by printer is printed, at once unless printer is buffered
+func atFlush(printer integration.LogWriter, call func(integration.LogWriter)) {
+	if buffered, ok := printer.(*integration.BufferedLogWriter); ok {
+		buffered.Run(call)
+		return
+	}
+	call(printer)
+}
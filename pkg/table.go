@@ -0,0 +1,29 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// printTable prints rows aligned in columns. When statuses are given, each row is printed with the status at the same index.
+func printTable(header []string, rows [][]string, statuses []string) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	printer.Print("%s", lines[0])
+	for i, line := range lines[1:] {
+		if statuses != nil {
+			printStatus(statuses[i], line)
+		} else {
+			printer.Print("%s", line)
+		}
+	}
+}
@@ -27,6 +27,11 @@ type RemoteCmd struct {
 	Stdout io.Writer
 	Stderr io.Writer
 
+	// StdinUntilAbort, if true, keeps the stdin of the remote command open
+	// until the command is aborted instead of passing Stdin. Commands which
+	// watch their stdin can end themselves on servers ignoring signals.
+	StdinUntilAbort bool
+
 	// This will be set to true when the remote command has exited. It
 	// shouldn't be set manually by the user, but there is no harm in
 	// doing so.
@@ -37,6 +42,7 @@ type RemoteCmd struct {
 
 	// Internal fields
 	exitCh chan struct{}
+	abort  func()
 
 	// This thing is a mutex, lock when making modifications concurrently
 	sync.Mutex
@@ -69,3 +75,21 @@ func (r *RemoteCmd) Wait() {
 
 	<-r.exitCh
 }
+
+func (r *RemoteCmd) setAbort(abort func()) {
+	r.Lock()
+	defer r.Unlock()
+	r.abort = abort
+}
+
+// Abort terminates a started remote command and closes its session. The exit
+// status is set once the session is closed, Wait has to be called nonetheless.
+func (r *RemoteCmd) Abort() {
+	r.Lock()
+	abort := r.abort
+	r.Unlock()
+
+	if abort != nil {
+		abort()
+	}
+}
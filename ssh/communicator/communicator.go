@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/sftp"
@@ -24,11 +25,22 @@ import (
 // out period is 1 minute. You can change it with Config.HandshakeTimeout.
 var ErrHandshakeTimeout = fmt.Errorf("Timeout during SSH handshake")
 
+// ErrKeepAliveTimeout is returned whenever a keepalive request is not answered
+// within the handshake timeout. The connection is closed in that case.
+var ErrKeepAliveTimeout = fmt.Errorf("Timeout during SSH keepalive")
+
 type Comm struct {
 	client  *ssh.Client
 	config  *Config
 	conn    net.Conn
 	address string
+
+	// mu guards client and conn since a Comm might be shared by concurrent commands
+	mu sync.Mutex
+
+	// logPrinter is replaced whenever another command picks up the Comm, logMu guards it
+	logPrinter func(msg string, a ...interface{})
+	logMu      sync.RWMutex
 }
 
 // Config is the structure used to configure the SSH communicator.
@@ -55,20 +67,17 @@ type Config struct {
 	UseSftp bool
 }
 
-var logPrinter func(msg string, a ...interface{})
-
 // Creates a new Communicator implementation over SSH. This takes
 // an already existing TCP connection and SSH configuration.
 func New(address string, config *Config, lp func(msg string, a ...interface{})) (result *Comm, err error) {
-	logPrinter = lp
-
 	// Establish an initial connection and connect
 	result = &Comm{
-		config:  config,
-		address: address,
+		config:     config,
+		address:    address,
+		logPrinter: lp,
 	}
 
-    logPrinter("Attempting SSH connection to %s ...", address)
+    result.log("Attempting SSH connection to %s ...", address)
 	if err = result.reconnect(); err != nil {
 		result = nil
 		return
@@ -77,6 +86,23 @@ func New(address string, config *Config, lp func(msg string, a ...interface{}))
 	return
 }
 
+// SetLogPrinter replaces the printer of debug messages, e.g. by the one of the command which uses the Comm next
+func (c *Comm) SetLogPrinter(lp func(msg string, a ...interface{})) {
+	c.logMu.Lock()
+	defer c.logMu.Unlock()
+	c.logPrinter = lp
+}
+
+func (c *Comm) log(msg string, a ...interface{}) {
+	c.logMu.RLock()
+	lp := c.logPrinter
+	c.logMu.RUnlock()
+
+	if lp != nil {
+		lp(msg, a...)
+	}
+}
+
 func (c *Comm) Start(cmd *RemoteCmd) (err error) {
 	session, err := c.newSession()
 	if err != nil {
@@ -84,7 +110,14 @@ func (c *Comm) Start(cmd *RemoteCmd) (err error) {
 	}
 
 	// Setup our session
-	session.Stdin = cmd.Stdin
+	var stdinW io.WriteCloser
+	if cmd.StdinUntilAbort {
+		if stdinW, err = session.StdinPipe(); err != nil {
+			return
+		}
+	} else {
+		session.Stdin = cmd.Stdin
+	}
 	session.Stdout = cmd.Stdout
 	session.Stderr = cmd.Stderr
 
@@ -101,12 +134,21 @@ func (c *Comm) Start(cmd *RemoteCmd) (err error) {
 		}
 	}
 
-    logPrinter("Starting remote command: %s", cmd.Command)
+    c.log("Starting remote command: %s", cmd.Command)
 	err = session.Start(cmd.Command + "\n")
 	if err != nil {
 		return
 	}
 
+	cmd.setAbort(func() {
+		// Signals are ignored by servers without pty, commands watching their stdin end once it is closed
+		if stdinW != nil {
+			stdinW.Close()
+		}
+		session.Signal(ssh.SIGTERM)
+		session.Close()
+	})
+
 	// Start a goroutine to wait for the session to end and set the
 	// exit boolean and status.
 	go func() {
@@ -119,14 +161,14 @@ func (c *Comm) Start(cmd *RemoteCmd) (err error) {
 			case *ssh.ExitError:
 				exitStatus = err.(*ssh.ExitError).ExitStatus()
 
-                logPrinter("Remote command '%s' exited with '%d': %s", cmd.Command, exitStatus, err)
+                c.log("Remote command '%s' exited with '%d': %s", cmd.Command, exitStatus, err)
 			case *ssh.ExitMissingError:
 
-                logPrinter("Remote command exited without exit status or exit signal.")
+                c.log("Remote command exited without exit status or exit signal.")
 				exitStatus = CmdDisconnect
 			default:
 
-                logPrinter("Error occurred waiting for ssh session: %s", err.Error())
+                c.log("Error occurred waiting for ssh session: %s", err.Error())
 			}
 		}
 		cmd.SetExited(exitStatus)
@@ -144,7 +186,7 @@ func (c *Comm) Upload(path string, input io.Reader, fi *os.FileInfo) error {
 
 func (c *Comm) UploadDir(dst string, src string, excl []string) error {
 
-    logPrinter("Upload dir '%s' to '%s'", src, dst)
+    c.log("Upload dir '%s' to '%s'", src, dst)
 	if c.config.UseSftp {
 		return c.sftpUploadDirSession(dst, src, excl)
 	} else {
@@ -154,7 +196,7 @@ func (c *Comm) UploadDir(dst string, src string, excl []string) error {
 
 func (c *Comm) DownloadDir(src string, dst string, excl []string) error {
 
-    logPrinter("Download dir '%s' to '%s'", src, dst)
+    c.log("Download dir '%s' to '%s'", src, dst)
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
 		dirStack := []string{dst}
 		for {
@@ -190,7 +232,7 @@ func (c *Comm) DownloadDir(src string, dst string, excl []string) error {
 			var size int64
 			var name string
 
-            logPrinter("Download dir str:%s", fi)
+            c.log("Download dir str:%s", fi)
 			n, err := fmt.Sscanf(fi[1:], "%o %d %s", &mode, &size, &name)
 			if err != nil || n != 3 {
 				return fmt.Errorf("can't parse server response (%s)", fi)
@@ -199,7 +241,7 @@ func (c *Comm) DownloadDir(src string, dst string, excl []string) error {
 				return fmt.Errorf("negative file size")
 			}
 
-            logPrinter("Download dir mode:%0o size:%d name:%s", mode, size, name)
+            c.log("Download dir mode:%0o size:%d name:%s", mode, size, name)
 
 			dst = filepath.Join(dirStack...)
 			switch fi[0] {
@@ -233,8 +275,70 @@ func (c *Comm) Download(path string, output io.Writer) error {
 	return c.scpDownloadSession(path, output)
 }
 
+// KeepAlive sends a keepalive request over the current client to verify that the connection is still usable
+func (c *Comm) KeepAlive() error {
+	c.mu.Lock()
+	client := c.client
+	c.mu.Unlock()
+
+	if client == nil {
+		return errors.New("Client not available")
+	}
+
+	return c.keepAlive(client)
+}
+
+// keepAlive sends a keepalive request over client. A half-dead connection never answers, the client is thus closed
+// once the handshake timeout has passed without an answer.
+func (c *Comm) keepAlive(client *ssh.Client) error {
+	answered := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		answered <- err
+	}()
+
+	select {
+	case err := <-answered:
+		return err
+	case <-time.After(c.handshakeTimeout()):
+		client.Close()
+		return ErrKeepAliveTimeout
+	}
+}
+
+// Reconnect closes the current connection and establishes a new one
+func (c *Comm) Reconnect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.reconnect()
+}
+
+// Close closes the ssh client and the underlying connection
+func (c *Comm) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	if c.client != nil {
+		err = c.client.Close()
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+
+	c.client = nil
+	c.conn = nil
+	return err
+}
+
+// newSession opens a session on the current connection. The connection is only replaced when it is dead, other
+// sessions might still use it, e.g. when the server refuses further sessions.
 func (c *Comm) newSession() (session *ssh.Session, err error) {
-    logPrinter("Opening new ssh session")
+    c.log("Opening new ssh session")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	if c.client == nil {
 		err = errors.New("Client not available")
@@ -243,7 +347,14 @@ func (c *Comm) newSession() (session *ssh.Session, err error) {
 	}
 
 	if err != nil {
-        logPrinter("Ssh session open error: '%s', attempting reconnect", err)
+		if c.client != nil {
+			if keepAliveErr := c.keepAlive(c.client); keepAliveErr == nil {
+                c.log("Ssh session open error: '%s', connection is alive", err)
+				return nil, err
+			}
+		}
+
+        c.log("Ssh session open error: '%s', attempting reconnect", err)
 		if err := c.reconnect(); err != nil {
 			return nil, err
 		}
@@ -268,7 +379,7 @@ func (c *Comm) reconnect() (err error) {
 	c.conn = nil
 	c.client = nil
 
-    logPrinter("Reconnecting to TCP connection for SSH")
+    c.log("Reconnecting to TCP connection for SSH")
 	c.conn, err = c.config.Connection()
 	if err != nil {
 		// Explicitly set this to the REAL nil. Connection() can return
@@ -279,20 +390,12 @@ func (c *Comm) reconnect() (err error) {
 		// http://golang.org/doc/faq#nil_error
 		c.conn = nil
 
-        logPrinter("Reconnection error: %s", err)
+        c.log("Reconnection error: %s", err)
 		return
 	}
 
-    logPrinter("Handshaking with SSH")
-	// Default timeout to 1 minute if it wasn't specified (zero value). For
-	// when you need to handshake from low orbit.
-	var duration time.Duration
-	if c.config.HandshakeTimeout == 0 {
-		duration = 1 * time.Minute
-	} else {
-		duration = c.config.HandshakeTimeout
-	}
-
+    c.log("Handshaking with SSH")
+	duration := c.handshakeTimeout()
 	connectionEstablished := make(chan struct{}, 1)
 
 	var sshConn ssh.Conn
@@ -320,11 +423,11 @@ func (c *Comm) reconnect() (err error) {
 
 	if err != nil {
 
-        logPrinter("Handshake error: %s", err)
+        c.log("Handshake error: %s", err)
 		return
 	}
 
-    logPrinter("Handshake complete!")
+    c.log("Handshake complete!")
 	if sshConn != nil {
 		c.client = ssh.NewClient(sshConn, sshChan, req)
 	}
@@ -333,6 +436,15 @@ func (c *Comm) reconnect() (err error) {
 	return
 }
 
+// handshakeTimeout defaults to 1 minute if it wasn't specified (zero value). For
+// when you need to handshake from low orbit.
+func (c *Comm) handshakeTimeout() time.Duration {
+	if c.config.HandshakeTimeout == 0 {
+		return 1 * time.Minute
+	}
+	return c.config.HandshakeTimeout
+}
+
 func (c *Comm) connectToAgent() {
 	if c.client == nil {
 		return
@@ -340,7 +452,7 @@ func (c *Comm) connectToAgent() {
 
 	if c.config.DisableAgent {
 
-        logPrinter("SSH agent forwarding is disabled.")
+        c.log("SSH agent forwarding is disabled.")
 		return
 	}
 
@@ -348,13 +460,13 @@ func (c *Comm) connectToAgent() {
 	socketLocation := os.Getenv("SSH_AUTH_SOCK")
 	if socketLocation == "" {
 
-        logPrinter("No local agent socket, will not connect agent")
+        c.log("No local agent socket, will not connect agent")
 		return
 	}
 	agentConn, err := net.Dial("unix", socketLocation)
 	if err != nil {
 
-        logPrinter("Could not connect to local agent socket: %s", socketLocation)
+        c.log("Could not connect to local agent socket: %s", socketLocation)
 		return
 	}
 
@@ -362,7 +474,7 @@ func (c *Comm) connectToAgent() {
 	forwardingAgent := agent.NewClient(agentConn)
 	if forwardingAgent == nil {
 
-        logPrinter("Could not create agent client")
+        c.log("Could not create agent client")
 		agentConn.Close()
 		return
 	}
@@ -373,8 +485,9 @@ func (c *Comm) connectToAgent() {
 	c.config.SSHConfig.Auth = append(c.config.SSHConfig.Auth, auth)
 	agent.ForwardToAgent(c.client, forwardingAgent)
 
-	// Setup a session to request agent forwarding
-	session, err := c.newSession()
+	// Setup a session to request agent forwarding. The client is used directly since
+	// reconnect is already called with the lock held.
+	session, err := c.client.NewSession()
 	if err != nil {
 		return
 	}
@@ -383,24 +496,24 @@ func (c *Comm) connectToAgent() {
 	err = agent.RequestAgentForwarding(session)
 	if err != nil {
 
-        logPrinter("RequestAgentForwarding: %#v", err)
+        c.log("RequestAgentForwarding: %#v", err)
 		return
 	}
 
-    logPrinter("Agent forwarding enabled")
+    c.log("Agent forwarding enabled")
 	return
 }
 
 func (c *Comm) sftpUploadSession(path string, input io.Reader, fi *os.FileInfo) error {
 	sftpFunc := func(client *sftp.Client) error {
-        return sftpUploadFile(path, input, client, fi)
+        return c.sftpUploadFile(path, input, client, fi)
 	}
 
 	return c.sftpSession(sftpFunc)
 }
 
-func sftpUploadFile(path string, input io.Reader, client *sftp.Client, fi *os.FileInfo) error {
-    logPrinter("SFTP: uploading %s", path)
+func (c *Comm) sftpUploadFile(path string, input io.Reader, client *sftp.Client, fi *os.FileInfo) error {
+    c.log("SFTP: uploading %s", path)
 
 	f, err := client.Create(path)
 	if err != nil {
@@ -428,7 +541,7 @@ func (c *Comm) sftpUploadDirSession(dst string, src string, excl []string) error
 		rootDst := dst
 		if src[len(src)-1] != '/' {
 
-            logPrinter("No trailing slash, creating the source directory name")
+            c.log("No trailing slash, creating the source directory name")
 			rootDst = filepath.Join(dst, filepath.Base(src))
 		}
 		walkFunc := func(path string, info os.FileInfo, err error) error {
@@ -453,7 +566,7 @@ func (c *Comm) sftpUploadDirSession(dst string, src string, excl []string) error
 				return nil
 			}
 
-            return sftpVisitFile(finalDst, path, info, client)
+            return c.sftpVisitFile(finalDst, path, info, client)
 		}
 
 		return filepath.Walk(src, walkFunc)
@@ -462,9 +575,9 @@ func (c *Comm) sftpUploadDirSession(dst string, src string, excl []string) error
 	return c.sftpSession(sftpFunc)
 }
 
-func sftpMkdir(path string, client *sftp.Client, fi os.FileInfo) error {
+func (c *Comm) sftpMkdir(path string, client *sftp.Client, fi os.FileInfo) error {
 
-    logPrinter("SFTP: creating dir %s", path)
+    c.log("SFTP: creating dir %s", path)
 
 	if err := client.Mkdir(path); err != nil {
 		// Do not consider it an error if the directory existed
@@ -481,16 +594,16 @@ func sftpMkdir(path string, client *sftp.Client, fi os.FileInfo) error {
 	return nil
 }
 
-func sftpVisitFile(dst string, src string, fi os.FileInfo, client *sftp.Client) error {
+func (c *Comm) sftpVisitFile(dst string, src string, fi os.FileInfo, client *sftp.Client) error {
 	if !fi.IsDir() {
 		f, err := os.Open(src)
 		if err != nil {
 			return err
 		}
 		defer f.Close()
-        return sftpUploadFile(dst, f, client, &fi)
+        return c.sftpUploadFile(dst, f, client, &fi)
 	} else {
-        err := sftpMkdir(dst, client, fi)
+        err := c.sftpMkdir(dst, client, fi)
 		return err
 	}
 }
@@ -557,7 +670,7 @@ func (c *Comm) scpUploadSession(path string, input io.Reader, fi *os.FileInfo) e
 	target_dir = filepath.ToSlash(target_dir)
 
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
-        return scpUploadFile(target_file, input, w, stdoutR, fi)
+        return c.scpUploadFile(target_file, input, w, stdoutR, fi)
 	}
 
 	return c.scpSession("scp -vt "+target_dir, scpFunc)
@@ -577,16 +690,16 @@ func (c *Comm) scpUploadDirSession(dst string, src string, excl []string) error
 				return err
 			}
 
-            return scpUploadDir(src, entries, w, r)
+            return c.scpUploadDir(src, entries, w, r)
 		}
 
 		if src[len(src)-1] != '/' {
-            logPrinter("No trailing slash, creating the source directory name")
+            c.log("No trailing slash, creating the source directory name")
 			fi, err := os.Stat(src)
 			if err != nil {
 				return err
 			}
-            return scpUploadDirProtocol(filepath.Base(src), w, r, uploadEntries, fi)
+            return c.scpUploadDirProtocol(filepath.Base(src), w, r, uploadEntries, fi)
 		} else {
 			// Trailing slash, so only upload the contents
 			return uploadEntries()
@@ -681,7 +794,7 @@ func (c *Comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) er
 	session.Stderr = stderr
 
 	// Start the sink mode on the other side
-    logPrinter("Starting remote scp process: %s", scpCommand)
+    c.log("Starting remote scp process: %s", scpCommand)
 	if err := session.Start(scpCommand); err != nil {
 		return err
 	}
@@ -689,7 +802,7 @@ func (c *Comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) er
 	// Call our callback that executes in the context of SCP. We ignore
 	// EOF errors if they occur because it usually means that SCP prematurely
 	// ended on the other side.
-    logPrinter("Started SCP session, beginning transfers...")
+    c.log("Started SCP session, beginning transfers...")
 	if err := f(stdinW, stdoutR); err != nil && err != io.EOF {
 		return err
 	}
@@ -697,26 +810,26 @@ func (c *Comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) er
 	// Close the stdin, which sends an EOF, and then set w to nil so that
 	// our defer func doesn't close it again since that is unsafe with
 	// the Go SSH package.
-    logPrinter("SCP session complete, closing stdin pipe.")
+    c.log("SCP session complete, closing stdin pipe.")
 	stdinW.Close()
 	stdinW = nil
 
 	// Wait for the SCP connection to close, meaning it has consumed all
 	// our data and has completed. Or has errored.
-    logPrinter("Waiting for SSH session to complete.")
+    c.log("Waiting for SSH session to complete.")
 	err = session.Wait()
 	if err != nil {
 		if exitErr, ok := err.(*ssh.ExitError); ok {
 			// Otherwise, we have an ExitErorr, meaning we can just read
 			// the exit status
 
-            logPrinter("Non-zero exit status: %d", exitErr.ExitStatus())
+            c.log("Non-zero exit status: %d", exitErr.ExitStatus())
 			stdoutB, err := ioutil.ReadAll(stdoutR)
 			if err != nil {
 				return err
 			}
 
-            logPrinter("SCP output: %s", stdoutB)
+            c.log("SCP output: %s", stdoutB)
 
 			// If we exited with status 127, it means SCP isn't available.
 			// Return a more descriptive error for that.
@@ -730,7 +843,7 @@ func (c *Comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) er
 		return err
 	}
 
-    logPrinter("SCP stderr (length %d): %s", stderr.Len(), stderr.String())
+    c.log("SCP stderr (length %d): %s", stderr.Len(), stderr.String())
 	return nil
 }
 
@@ -768,7 +881,7 @@ func scpDownloadFile(dst string, src io.Reader, size int64, mode os.FileMode) er
 	return nil
 }
 
-func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *os.FileInfo) error {
+func (c *Comm) scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *os.FileInfo) error {
 	var mode os.FileMode
 	var size int64
 
@@ -787,7 +900,7 @@ func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *
 
 		mode = 0644
 
-        logPrinter("Copying input data into temporary file so we can read the length")
+        c.log("Copying input data into temporary file so we can read the length")
 		if _, err := io.Copy(tf, src); err != nil {
 			return err
 		}
@@ -815,7 +928,7 @@ func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *
 	// Start the protocol
 	perms := fmt.Sprintf("C%04o", mode)
 
-    logPrinter("SCP: Uploading %s: perms=%s size=%d", dst, perms, size)
+    c.log("SCP: Uploading %s: perms=%s size=%d", dst, perms, size)
 
 	fmt.Fprintln(w, perms, size, dst)
 	if err := checkSCPStatus(r); err != nil {
@@ -830,8 +943,8 @@ func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *
 	return checkSCPStatus(r)
 }
 
-func scpUploadDirProtocol(name string, w io.Writer, r *bufio.Reader, f func() error, fi os.FileInfo) error {
-    logPrinter("SCP: starting directory upload: %s", name)
+func (c *Comm) scpUploadDirProtocol(name string, w io.Writer, r *bufio.Reader, f func() error, fi os.FileInfo) error {
+    c.log("SCP: starting directory upload: %s", name)
 
 	mode := fi.Mode().Perm()
 
@@ -851,7 +964,7 @@ func scpUploadDirProtocol(name string, w io.Writer, r *bufio.Reader, f func() er
 	return err
 }
 
-func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) error {
+func (c *Comm) scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) error {
 	for _, fi := range fs {
 		realPath := filepath.Join(root, fi.Name())
 
@@ -883,7 +996,7 @@ func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) e
 
 			err = func() error {
 				defer f.Close()
-                return scpUploadFile(fi.Name(), f, w, r, &fi)
+                return c.scpUploadFile(fi.Name(), f, w, r, &fi)
 			}()
 
 			if err != nil {
@@ -894,7 +1007,7 @@ func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) e
 		}
 
 		// It is a directory, recursively upload
-		err := scpUploadDirProtocol(fi.Name(), w, r, func() error {
+		err := c.scpUploadDirProtocol(fi.Name(), w, r, func() error {
 			f, err := os.Open(realPath)
 			if err != nil {
 				return err
@@ -906,7 +1019,7 @@ func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) e
 				return err
 			}
 
-            return scpUploadDir(realPath, entries, w, r)
+            return c.scpUploadDir(realPath, entries, w, r)
         }, fi)
 		if err != nil {
 			return err
@@ -0,0 +1,215 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	HostKeyCheckingStrict    = "strict"
+	HostKeyCheckingAcceptNew = "accept-new"
+	HostKeyCheckingInsecure  = "insecure"
+)
+
+// knownHostsLock serializes writes of newly accepted host keys
+var knownHostsLock sync.Mutex
+
+// hostKeyError is returned by the host key callback when the key of a node is rejected. Retrying the handshake
+// does not change the outcome.
+type hostKeyError struct {
+	msg string
+}
+
+func (e *hostKeyError) Error() string {
+	return e.msg
+}
+
+func newHostKeyError(msg string, a ...interface{}) error {
+	return &hostKeyError{msg: fmt.Sprintf(msg, a...)}
+}
+
+func validateHostKeyChecking(mode string) error {
+	switch mode {
+	case "", HostKeyCheckingStrict, HostKeyCheckingAcceptNew, HostKeyCheckingInsecure:
+		return nil
+	default:
+		return fmt.Errorf("ssh_host_key_checking ('%s') is invalid, valid modes: %s, %s, %s",
+			mode, HostKeyCheckingStrict, HostKeyCheckingAcceptNew, HostKeyCheckingInsecure)
+	}
+}
+
+func knownHostsPath(conn types.SSHConnection) (string, error) {
+	if conn.KnownHostsFile != "" {
+		return conn.KnownHostsFile, nil
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("Could not determine home directory for known_hosts: %s", err)
+	}
+
+	return filepath.Join(u.HomeDir, ".ssh", "known_hosts"), nil
+}
+
+// hostKeyCallback verifies the host key of node at address against the known_hosts file configured in conn.
+// The algorithms of the keys known for address are returned as well, they are empty for unknown hosts.
+func hostKeyCallback(conn types.SSHConnection, node types.Node, address string) (ssh.HostKeyCallback, []string, error) {
+	mode := conn.HostKeyChecking
+	if mode == "" {
+		mode = HostKeyCheckingStrict
+	}
+
+	if err := validateHostKeyChecking(mode); err != nil {
+		return nil, nil, err
+	}
+
+	if mode == HostKeyCheckingInsecure {
+		return ssh.InsecureIgnoreHostKey(), nil, nil
+	}
+
+	file, err := knownHostsPath(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if mode == HostKeyCheckingAcceptNew {
+		if err := ensureKnownHostsFile(file); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not read known_hosts file %s: %s", file, err)
+	}
+
+	algorithms, err := knownHostKeyAlgorithms(callback, address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	label := util.ToNodeLabel(node)
+	var accepted ssh.PublicKey
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		keyErr, ok := err.(*knownhosts.KeyError)
+		if !ok {
+			return newHostKeyError("Host key verification failed for node %s: %s", label, err)
+		}
+
+		if len(keyErr.Want) > 0 {
+			return newHostKeyError("Host key of node %s at %s has changed and does not match %s:%d. "+
+				"This could be a man-in-the-middle attack, remove the entry only if the key was changed on purpose",
+				label, hostname, keyErr.Want[0].Filename, keyErr.Want[0].Line)
+		}
+
+		if mode != HostKeyCheckingAcceptNew {
+			return newHostKeyError("Host key of node %s at %s is unknown. Add it to %s e.g. via ssh-keyscan "+
+				"or set HostKeyChecking to %s", label, hostname, file, HostKeyCheckingAcceptNew)
+		}
+
+		// Reconnects reuse this callback, so a key accepted before must not be appended again
+		if accepted != nil {
+			if bytes.Equal(accepted.Marshal(), key.Marshal()) {
+				return nil
+			}
+			return newHostKeyError("Host key of node %s at %s changed during the session", label, hostname)
+		}
+
+		if err := appendKnownHost(file, hostname, key); err != nil {
+			return fmt.Errorf("Could not add host key of node %s to %s: %s", label, file, err)
+		}
+
+		accepted = key
+		return nil
+	}, algorithms, nil
+}
+
+// knownHostKeyAlgorithms returns the algorithms of the keys known_hosts holds for address or nil if there are none. The server has to
+// offer one of these, otherwise it might present a key of another type which is then rejected as changed.
+func knownHostKeyAlgorithms(callback ssh.HostKeyCallback, address string) ([]string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, err
+	}
+
+	// A key which is not in known_hosts makes the callback list the keys known for address
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	probe, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+
+	keyErr, ok := callback(address, &net.TCPAddr{IP: net.ParseIP(host), Port: portNumber}, probe).(*knownhosts.KeyError)
+	if !ok {
+		return nil, nil
+	}
+
+	// nil keeps the default algorithms of the client for unknown hosts
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		keyAlgorithms := []string{known.Key.Type()}
+		if known.Key.Type() == ssh.KeyAlgoRSA {
+			// RSA keys are also used with SHA-2 signatures, servers disable the SHA-1 ssh-rsa algorithm by now
+			keyAlgorithms = []string{"rsa-sha2-512", "rsa-sha2-256", ssh.KeyAlgoRSA}
+		}
+		for _, algorithm := range keyAlgorithms {
+			if !util.ElementInArray(algorithms, algorithm) {
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+	return algorithms, nil
+}
+
+func ensureKnownHostsFile(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	fd, err := os.OpenFile(file, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	return fd.Close()
+}
+
+func appendKnownHost(file string, hostname string, key ssh.PublicKey) error {
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+
+	fd, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	_, err = fmt.Fprintln(fd, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
@@ -0,0 +1,215 @@
+package ssh
+
+import (
+    "testing"
+    "github.com/stretchr/testify/assert"
+    "golang.org/x/crypto/ssh"
+    "golang.org/x/crypto/ssh/knownhosts"
+    "golang.org/x/crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "errors"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync/atomic"
+    "time"
+)
+
+func newSigners(t *testing.T) (ssh.Signer, ssh.Signer) {
+    _, edKey, err := ed25519.GenerateKey(rand.Reader)
+    assert.Nil(t, err)
+    edSigner, err := ssh.NewSignerFromKey(edKey)
+    assert.Nil(t, err)
+
+    rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+    assert.Nil(t, err)
+    rsaSigner, err := ssh.NewSignerFromKey(rsaKey)
+    assert.Nil(t, err)
+    return edSigner, rsaSigner
+}
+
+func writeKnownHosts(t *testing.T, address string, keys ...ssh.PublicKey) (string, func()) {
+    dir, err := ioutil.TempDir("", "known-hosts")
+    assert.Nil(t, err)
+
+    lines := []string{}
+    for _, key := range keys {
+        lines = append(lines, knownhosts.Line([]string{knownhosts.Normalize(address)}, key))
+    }
+    file := filepath.Join(dir, "known_hosts")
+    assert.Nil(t, ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+    return file, func() { os.RemoveAll(dir) }
+}
+
+func TestKnownHostKeyAlgorithms(t *testing.T) {
+    edSigner, rsaSigner := newSigners(t)
+    file, cleanup := writeKnownHosts(t, "10.0.0.1:22", rsaSigner.PublicKey(), edSigner.PublicKey())
+    defer cleanup()
+
+    callback, err := knownhosts.New(file)
+    assert.Nil(t, err)
+
+    algorithms, err := knownHostKeyAlgorithms(callback, "10.0.0.1:22")
+    assert.Nil(t, err)
+    assert.Equal(t, []string{"rsa-sha2-512", "rsa-sha2-256", ssh.KeyAlgoRSA, ssh.KeyAlgoED25519}, algorithms)
+
+    algorithms, err = knownHostKeyAlgorithms(callback, "10.0.0.2:22")
+    assert.Nil(t, err)
+    assert.Nil(t, algorithms)
+}
+
+func TestHostKey_NegotiatesKnownKeyType(t *testing.T) {
+    edSigner, rsaSigner := newSigners(t)
+    // the client prefers the rsa key of the server by default while known_hosts only holds the ed25519 key
+    server := newTestServer(t, edSigner, rsaSigner)
+    defer server.Close()
+    defer CloseConnections()
+
+    file, cleanup := writeKnownHosts(t, server.address(), edSigner.PublicKey())
+    defer cleanup()
+
+    executor := server.executor(ioutil.Discard)
+    executor.SshOpts.Connection.HostKeyChecking = HostKeyCheckingStrict
+    executor.SshOpts.Connection.KnownHostsFile = file
+
+    sshOut, err := executor.PerformCmd("echo connected", false)
+    assert.Nil(t, err)
+    assert.Equal(t, "connected", sshOut.Stdout)
+}
+
+func TestHostKey_RejectedKeyIsNotRetried(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    file, cleanup := writeKnownHosts(t, "10.0.0.1:22")
+    defer cleanup()
+
+    executor := server.executor(ioutil.Discard)
+    executor.SshOpts.Connection.HostKeyChecking = HostKeyCheckingStrict
+    executor.SshOpts.Connection.KnownHostsFile = file
+
+    start := time.Now()
+    _, err := executor.PerformCmd("echo connected", false)
+
+    var keyErr *hostKeyError
+    assert.True(t, errors.As(err, &keyErr), "%v", err)
+    assert.Contains(t, err.Error(), "is unknown")
+    assert.True(t, time.Since(start) < 2*time.Second, "handshake was retried")
+}
+
+func knownHostsLines(t *testing.T, file string) []string {
+    content, err := ioutil.ReadFile(file)
+    assert.Nil(t, err)
+    return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func TestHostKey_StrictRejectsUnknownHost(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    file, cleanup := writeKnownHosts(t, "10.0.0.1:22", server.hostKey)
+    defer cleanup()
+
+    executor := server.executor(ioutil.Discard)
+    executor.SshOpts.Connection.HostKeyChecking = HostKeyCheckingStrict
+    executor.SshOpts.Connection.KnownHostsFile = file
+
+    _, err := executor.PerformCmd("echo connected", false)
+    assert.NotNil(t, err)
+    assert.Contains(t, err.Error(), "is unknown")
+    assert.Len(t, knownHostsLines(t, file), 1)
+}
+
+func TestHostKey_StrictRejectsChangedKey(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    edSigner, _ := newSigners(t)
+    file, cleanup := writeKnownHosts(t, server.address(), edSigner.PublicKey())
+    defer cleanup()
+
+    executor := server.executor(ioutil.Discard)
+    executor.SshOpts.Connection.HostKeyChecking = HostKeyCheckingStrict
+    executor.SshOpts.Connection.KnownHostsFile = file
+
+    _, err := executor.PerformCmd("echo connected", false)
+    assert.NotNil(t, err)
+    assert.Contains(t, err.Error(), "has changed")
+}
+
+func TestHostKey_AcceptNewAppendsOnce(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    dir, err := ioutil.TempDir("", "known-hosts")
+    assert.Nil(t, err)
+    defer os.RemoveAll(dir)
+    file := filepath.Join(dir, ".ssh", "known_hosts")
+
+    executor := server.executor(ioutil.Discard)
+    executor.SshOpts.Connection.HostKeyChecking = HostKeyCheckingAcceptNew
+    executor.SshOpts.Connection.KnownHostsFile = file
+
+    _, err = executor.PerformCmd("true", false)
+    assert.Nil(t, err)
+    assert.Equal(t, []string{knownhosts.Line([]string{knownhosts.Normalize(server.address())}, server.hostKey)},
+        knownHostsLines(t, file))
+
+    // a reconnect reuses the accepted key
+    server.dropConnections()
+    idle()
+    _, err = executor.PerformCmd("true", false)
+    assert.Nil(t, err)
+    assert.Equal(t, int32(2), atomic.LoadInt32(&server.connections))
+
+    // a new connection finds the key in known_hosts
+    CloseConnections()
+    _, err = executor.PerformCmd("true", false)
+    assert.Nil(t, err)
+    assert.Equal(t, int32(3), atomic.LoadInt32(&server.connections))
+    assert.Len(t, knownHostsLines(t, file), 1)
+}
+
+func TestHostKey_AcceptNewRejectsChangedKey(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    edSigner, _ := newSigners(t)
+    file, cleanup := writeKnownHosts(t, server.address(), edSigner.PublicKey())
+    defer cleanup()
+
+    executor := server.executor(ioutil.Discard)
+    executor.SshOpts.Connection.HostKeyChecking = HostKeyCheckingAcceptNew
+    executor.SshOpts.Connection.KnownHostsFile = file
+
+    _, err := executor.PerformCmd("echo connected", false)
+    assert.NotNil(t, err)
+    assert.Contains(t, err.Error(), "has changed")
+    assert.Len(t, knownHostsLines(t, file), 1)
+}
+
+func TestHostKey_InsecureAcceptsAnyKey(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    edSigner, _ := newSigners(t)
+    file, cleanup := writeKnownHosts(t, server.address(), edSigner.PublicKey())
+    defer cleanup()
+
+    executor := server.executor(ioutil.Discard)
+    executor.SshOpts.Connection.HostKeyChecking = HostKeyCheckingInsecure
+    executor.SshOpts.Connection.KnownHostsFile = file
+
+    sshOut, err := executor.PerformCmd("echo connected", false)
+    assert.Nil(t, err)
+    assert.Equal(t, "connected", sshOut.Stdout)
+    assert.Len(t, knownHostsLines(t, file), 1)
+}
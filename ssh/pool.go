@@ -0,0 +1,90 @@
+package ssh
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mrahbar/kubernetes-inspector/integration"
+	"github.com/mrahbar/kubernetes-inspector/ssh/communicator"
+	"github.com/mrahbar/kubernetes-inspector/types"
+	"github.com/mrahbar/kubernetes-inspector/util"
+)
+
+// healthCheckInterval is the idle time after which a pooled connection is probed before it is reused
+const healthCheckInterval = 10 * time.Second
+
+type pooledComm struct {
+	sync.Mutex
+	comm     *communicator.Comm
+	lastUsed time.Time
+}
+
+// pool holds one communicator per node address for the lifetime of the process
+var pool = struct {
+	sync.Mutex
+	comms map[string]*pooledComm
+}{comms: map[string]*pooledComm{}}
+
+func getCommunicator(sshOpts types.SSHConfig, node types.Node, printer integration.LogWriter) (*communicator.Comm, error) {
+	address := util.GetNodeAddress(node)
+
+	pool.Lock()
+	entry, ok := pool.comms[address]
+	if !ok {
+		entry = &pooledComm{}
+		pool.comms[address] = entry
+	}
+	pool.Unlock()
+
+	// Lock per address so concurrent commands on the same node share a single handshake
+	entry.Lock()
+	defer entry.Unlock()
+
+	if entry.comm != nil {
+		// Messages of the connection belong to the command which uses it now
+		entry.comm.SetLogPrinter(printer.PrintTrace)
+	}
+
+	if entry.comm != nil && time.Since(entry.lastUsed) > healthCheckInterval {
+		if err := entry.comm.KeepAlive(); err != nil {
+			printer.PrintDebug("Pooled connection to %s is unhealthy, reconnecting: %s", address, err)
+
+			if err := entry.comm.Reconnect(); err != nil {
+				printer.PrintDebug("Reconnecting to %s failed: %s", address, err)
+				entry.comm.Close()
+				entry.comm = nil
+			}
+		}
+	}
+
+	if entry.comm == nil {
+		comm, err := establishSSHCommunication(sshOpts, node, printer)
+		if err != nil {
+			return comm, err
+		}
+
+		entry.comm = comm
+	} else {
+		printer.PrintTrace("Reusing pooled connection to %s", address)
+	}
+
+	entry.lastUsed = time.Now()
+	return entry.comm, nil
+}
+
+// CloseConnections closes all pooled connections. It should be called once before the program exits.
+func CloseConnections() {
+	pool.Lock()
+	defer pool.Unlock()
+
+	for address, entry := range pool.comms {
+		entry.Lock()
+		if entry.comm != nil {
+			entry.comm.Close()
+			entry.comm = nil
+		}
+		entry.Unlock()
+
+		delete(pool.comms, address)
+	}
+}
@@ -0,0 +1,152 @@
+package ssh
+
+import (
+    "testing"
+    "github.com/stretchr/testify/assert"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "bytes"
+    "context"
+    "io/ioutil"
+    "sync/atomic"
+    "time"
+)
+
+// idle marks the pooled connection to the test server as unused for longer than the health check interval
+func idle() {
+    pool.Lock()
+    defer pool.Unlock()
+    pool.comms["127.0.0.1"].lastUsed = time.Now().Add(-2 * healthCheckInterval)
+}
+
+func TestPool_ReusesConnection(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    executor := server.executor(ioutil.Discard)
+    for i := 0; i < 3; i++ {
+        sshOut, err := executor.ForNode(executor.Node).PerformCmd("echo hello", false)
+        assert.Nil(t, err)
+        assert.Equal(t, "hello", sshOut.Stdout)
+    }
+
+    assert.Equal(t, int32(1), atomic.LoadInt32(&server.connections))
+    assert.Equal(t, int32(0), atomic.LoadInt32(&server.keepAlives))
+}
+
+func TestPool_ProbesIdleConnection(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    executor := server.executor(ioutil.Discard)
+    _, err := executor.PerformCmd("true", false)
+    assert.Nil(t, err)
+
+    idle()
+    _, err = executor.PerformCmd("true", false)
+    assert.Nil(t, err)
+    assert.Equal(t, int32(1), atomic.LoadInt32(&server.keepAlives))
+    assert.Equal(t, int32(1), atomic.LoadInt32(&server.connections))
+
+    // a connection which died while idle is replaced before it is used
+    server.dropConnections()
+    idle()
+    sshOut, err := executor.PerformCmd("echo hello", false)
+    assert.Nil(t, err)
+    assert.Equal(t, "hello", sshOut.Stdout)
+    assert.Equal(t, int32(2), atomic.LoadInt32(&server.connections))
+}
+
+func TestPool_UnansweredKeepAliveReplacesConnection(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    executor := server.executor(ioutil.Discard)
+    executor.SshOpts.Connection.Timeout = 200 * time.Millisecond
+    _, err := executor.PerformCmd("true", false)
+    assert.Nil(t, err)
+
+    // a connection which never answers must not block the node forever
+    atomic.StoreInt32(&server.ignoreKeepAlives, 1)
+    idle()
+    done := make(chan error)
+    go func() {
+        _, err := executor.PerformCmd("true", false)
+        done <- err
+    }()
+
+    select {
+    case err := <-done:
+        assert.Nil(t, err)
+    case <-time.After(5 * time.Second):
+        server.Close()
+        t.Fatal("command is blocked by the unanswered keepalive")
+    }
+    assert.Equal(t, int32(1), atomic.LoadInt32(&server.keepAlives))
+    assert.Equal(t, int32(2), atomic.LoadInt32(&server.connections))
+}
+
+func TestPool_LogsToCurrentPrinter(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    first, second := &bytes.Buffer{}, &bytes.Buffer{}
+    _, err := server.executor(first).PerformCmd("echo first", false)
+    assert.Nil(t, err)
+    _, err = server.executor(second).PerformCmd("echo second", false)
+    assert.Nil(t, err)
+
+    assert.Equal(t, int32(1), atomic.LoadInt32(&server.connections))
+    assert.Contains(t, first.String(), "Starting remote command: echo first")
+    assert.NotContains(t, first.String(), "echo second")
+    assert.Contains(t, second.String(), "Starting remote command: echo second")
+}
+
+func TestPool_CloseConnections(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    executor := server.executor(ioutil.Discard)
+    _, err := executor.PerformCmd("true", false)
+    assert.Nil(t, err)
+
+    CloseConnections()
+    pool.Lock()
+    assert.Empty(t, pool.comms)
+    pool.Unlock()
+
+    _, err = executor.PerformCmd("true", false)
+    assert.Nil(t, err)
+    assert.Equal(t, int32(2), atomic.LoadInt32(&server.connections))
+}
+
+func TestPool_RefusedSessionKeepsConnection(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+    atomic.StoreInt32(&server.maxSessions, 1)
+
+    executor := server.executor(ioutil.Discard)
+    stdout := &bytes.Buffer{}
+    streamed := make(chan error)
+    go func() {
+        _, err := executor.PerformCmdStreaming(context.Background(), "sleep 0.5; echo done", false, stdout, ioutil.Discard)
+        streamed <- err
+    }()
+
+    for atomic.LoadInt32(&server.sessions) == 0 {
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    // the server refuses a second session, the connection of the running command must stay open
+    _, err := executor.ForNode(types.Node{Host: "testhost", IP: "127.0.0.1"}).PerformCmd("echo hello", false)
+    assert.NotNil(t, err)
+
+    assert.Nil(t, <-streamed)
+    assert.Equal(t, "done\n", stdout.String())
+    assert.Equal(t, int32(1), atomic.LoadInt32(&server.connections))
+}
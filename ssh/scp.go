@@ -16,7 +16,7 @@ func (c *Executor) DownloadFile(remotePath string, localPath string) error {
 		return copyFile(remotePath, localPath)
 	}
 
-    comm, err := establishSSHCommunication(c.SshOpts, util.GetNodeAddress(c.Node), c.Printer)
+    comm, err := getCommunicator(c.SshOpts, c.Node, c.Printer)
 	if err != nil {
         c.Printer.PrintDebug("Creating communicator failed: %s", err)
 		return err
@@ -46,7 +46,7 @@ func (c *Executor) DownloadDirectory(remotePath string, localPath string) error
 		return fmt.Errorf("Local scp ist not supported")
 	}
 
-    comm, err := establishSSHCommunication(c.SshOpts, util.GetNodeAddress(c.Node), c.Printer)
+    comm, err := getCommunicator(c.SshOpts, c.Node, c.Printer)
 	if err != nil {
         c.Printer.PrintDebug("Creating communicator failed: %s", err)
 		return err
@@ -81,7 +81,7 @@ func (c *Executor) UploadFile(remotePath string, localPath string) error {
 		return err
 	}
 
-    comm, err := establishSSHCommunication(c.SshOpts, util.GetNodeAddress(c.Node), c.Printer)
+    comm, err := getCommunicator(c.SshOpts, c.Node, c.Printer)
 	if err != nil {
         c.Printer.PrintDebug("Creating communicator failed: %s", err)
 		return err
@@ -105,7 +105,7 @@ func (c *Executor) UploadDirectory(remotePath string, localPath string) error {
 		return fmt.Errorf("Local scp ist not supported")
 	}
 
-    comm, err := establishSSHCommunication(c.SshOpts, util.GetNodeAddress(c.Node), c.Printer)
+    comm, err := getCommunicator(c.SshOpts, c.Node, c.Printer)
 	if err != nil {
         c.Printer.PrintDebug("Creating communicator failed: %s", err)
 		return err
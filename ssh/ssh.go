@@ -1,17 +1,19 @@
 package ssh
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
 
 	"bytes"
     "github.com/mrahbar/kubernetes-inspector/integration"
 	"github.com/mrahbar/kubernetes-inspector/ssh/communicator"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/mrahbar/kubernetes-inspector/util"
-	"golang.org/x/crypto/ssh"
 )
 
 type Executor struct {
@@ -28,6 +30,22 @@ func (c *Executor) SetNode(node types.Node) {
     c.Node = node
 }
 
+func (c *Executor) ForNode(node types.Node) types.CommandExecutor {
+    return &Executor{
+        SshOpts: c.SshOpts,
+        Node:    node,
+        Printer: c.Printer,
+    }
+}
+
+func (c *Executor) WithPrinter(printer integration.LogWriter) types.CommandExecutor {
+    return &Executor{
+        SshOpts: c.SshOpts,
+        Node:    c.Node,
+        Printer: printer,
+    }
+}
+
 func (c *Executor) PerformCmd(cmd string, sudo bool) (*types.SSHOutput, error) {
     if util.NodeEquals(c.SshOpts.LocalOn, c.Node) {
         return shell(cmd, c.Printer)
@@ -37,7 +55,7 @@ func (c *Executor) PerformCmd(cmd string, sudo bool) (*types.SSHOutput, error) {
 		cmd = fmt.Sprintf("sudo %s", cmd)
 	}
 
-    comm, err := establishSSHCommunication(c.SshOpts, util.GetNodeAddress(c.Node), c.Printer)
+    comm, err := getCommunicator(c.SshOpts, c.Node, c.Printer)
 	if err != nil {
         c.Printer.PrintDebug("Creating communicator failed: %s", err)
 		return &types.SSHOutput{}, err
@@ -59,7 +77,7 @@ func (c *Executor) PerformCmd(cmd string, sudo bool) (*types.SSHOutput, error) {
 	remoteCmd.Wait()
 	output := strings.TrimSpace(stdout.String())
 	outErr := strings.TrimSpace(stderr.String())
-	o := &types.SSHOutput{Stdout: output, Stderr: outErr}
+	o := &types.SSHOutput{Stdout: output, Stderr: outErr, ExitStatus: remoteCmd.ExitStatus}
 
     if remoteCmd.ExitStatus != 0 {
         err = fmt.Errorf("%s", outErr)
@@ -75,18 +93,96 @@ func (c *Executor) PerformCmd(cmd string, sudo bool) (*types.SSHOutput, error) {
 	return o, err
 }
 
-func shell(cmd string, printer integration.LogWriter) (*types.SSHOutput, error) {
+// PerformCmdStreaming does not forward stdin since the command might run on several nodes at the same time
+func (c *Executor) PerformCmdStreaming(ctx context.Context, cmd string, sudo bool, stdout io.Writer, stderr io.Writer) (*types.SSHOutput, error) {
+    if util.NodeEquals(c.SshOpts.LocalOn, c.Node) {
+        return shellStreaming(ctx, cmd, stdout, stderr, c.Printer)
+    }
+
+	cmd = terminateOnStdinClose(cmd)
+	if sudo {
+		cmd = fmt.Sprintf("sudo %s", cmd)
+	}
+
+    comm, err := getCommunicator(c.SshOpts, c.Node, c.Printer)
+	if err != nil {
+        c.Printer.PrintDebug("Creating communicator failed: %s", err)
+		return &types.SSHOutput{}, err
+	}
+
+	remoteCmd := &communicator.RemoteCmd{
+		Command:         cmd,
+		Stdout:          stdout,
+		Stderr:          stderr,
+		StdinUntilAbort: true,
+	}
+
+	err = comm.Start(remoteCmd)
+	if err != nil {
+        c.Printer.PrintDebug("Starting remote command failed: %s", err)
+		return &types.SSHOutput{}, err
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		remoteCmd.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+	case <-ctx.Done():
+        c.Printer.PrintDebug("Aborting streamed command '%s': %s", cmd, ctx.Err())
+		remoteCmd.Abort()
+		<-exited
+		return &types.SSHOutput{ExitStatus: remoteCmd.ExitStatus}, ctx.Err()
+	}
+
+    c.Printer.PrintDebug("Streamed command '%s' exited with status %d", cmd, remoteCmd.ExitStatus)
+    if remoteCmd.ExitStatus != 0 {
+        err = fmt.Errorf("command exited with status %d", remoteCmd.ExitStatus)
+    }
+	return &types.SSHOutput{ExitStatus: remoteCmd.ExitStatus}, err
+}
+
+// terminateOnStdinClose wraps cmd so that it is killed once its stdin is closed. Without a pty sshd ignores the
+// signal sent on abort and closing the session leaves commands like journalctl --follow running until they write
+// again, but it closes their stdin. cmd itself reads from /dev/null.
+func terminateOnStdinClose(cmd string) string {
+	script := "exec 3<&0; { " + cmd + "\n} </dev/null 3<&- & pid=$!; " +
+		"{ cat >/dev/null; pkill -TERM -P $pid; kill -TERM $pid; } <&3 >/dev/null 2>&1 & watcher=$!; " +
+		"exec 3<&-; wait $pid; status=$?; kill $watcher 2>/dev/null; exit $status"
+	return "sh -c '" + strings.Replace(script, "'", `'\''`, -1) + "'"
+}
+
+func shellCommand(ctx context.Context, cmd string) (*exec.Cmd, error) {
 	shell := "/bin/bash"
     err := findExecutable(shell)
 	if err != nil {
 		shell = "/bin/sh"
         err := findExecutable(shell)
 		if err != nil {
-			return &types.SSHOutput{}, err
+			return nil, err
 		}
 	}
 
-	execCmd := exec.Command(shell, "-c", cmd)
+	return exec.CommandContext(ctx, shell, "-c", cmd), nil
+}
+
+func exitStatus(err error) int {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus()
+		}
+	}
+	return 0
+}
+
+func shell(cmd string, printer integration.LogWriter) (*types.SSHOutput, error) {
+	execCmd, err := shellCommand(context.Background(), cmd)
+	if err != nil {
+		return &types.SSHOutput{}, err
+	}
 
     printer.PrintDebug("Executing command: %s %s\n", execCmd.Path, execCmd.Args)
 
@@ -99,23 +195,39 @@ func shell(cmd string, printer integration.LogWriter) (*types.SSHOutput, error)
 	outErr := strings.TrimSpace(stderr.String())
 	o := &types.SSHOutput{Stdout: output, Stderr: outErr}
 
-	exitStatus := 0
-	if err != nil {
-		if _, ok := err.(*exec.ExitError); ok {
-			exitStatus = err.(*ssh.ExitError).ExitStatus()
-		}
-	}
+	o.ExitStatus = exitStatus(err)
 
     errFormatted := ""
     if err != nil {
         errFormatted = fmt.Sprintf("%s", err)
     }
     printer.PrintDebug("Result of command\n- Stdout: %s\n- Stderr: %s\n- ExitStatus: %d\n- Err: %s\n",
-        output, outErr, exitStatus, errFormatted)
+        output, outErr, o.ExitStatus, errFormatted)
 
 	return o, err
 }
 
+func shellStreaming(ctx context.Context, cmd string, stdout io.Writer, stderr io.Writer, printer integration.LogWriter) (*types.SSHOutput, error) {
+	execCmd, err := shellCommand(ctx, cmd)
+	if err != nil {
+		return &types.SSHOutput{}, err
+	}
+
+    printer.PrintDebug("Executing streamed command: %s %s\n", execCmd.Path, execCmd.Args)
+
+	execCmd.Stdout = stdout
+	execCmd.Stderr = stderr
+
+	err = execCmd.Run()
+	o := &types.SSHOutput{ExitStatus: exitStatus(err)}
+    printer.PrintDebug("Streamed command exited with status %d", o.ExitStatus)
+
+	if ctx.Err() != nil {
+		return o, ctx.Err()
+	}
+	return o, err
+}
+
 func findExecutable(file string) error {
 	d, err := os.Stat(file)
 	if err != nil {
@@ -0,0 +1,269 @@
+package ssh
+
+import (
+    "testing"
+    "github.com/stretchr/testify/assert"
+    "golang.org/x/crypto/ssh"
+    "golang.org/x/crypto/ed25519"
+    "crypto/rand"
+    "encoding/binary"
+    "net"
+    "io"
+    "os"
+    "os/exec"
+    "sync"
+    "sync/atomic"
+    "syscall"
+    "strings"
+    "strconv"
+    "bytes"
+    "context"
+    "io/ioutil"
+    "path/filepath"
+    "time"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    printTest "github.com/mrahbar/kubernetes-inspector/integration/test"
+)
+
+// testServer is an in-process ssh server running exec requests with the local shell. Like sshd it ignores signals
+// of sessions without pty and closes the stdin of the command once the session is closed.
+type testServer struct {
+    listener    net.Listener
+    hostKey     ssh.PublicKey
+    accepted    int32
+    connections int32
+    keepAlives  int32
+    // ignoreKeepAlives leaves keepalive requests unanswered if set, like a half-dead connection
+    ignoreKeepAlives int32
+    // maxSessions limits the concurrently open sessions if set, like MaxSessions of sshd
+    maxSessions int32
+    sessions    int32
+
+    mu    sync.Mutex
+    conns []ssh.Conn
+}
+
+// newTestServer starts a server presenting hostKeys or a new ed25519 key if none is given
+func newTestServer(t *testing.T, hostKeys ...ssh.Signer) *testServer {
+    if len(hostKeys) == 0 {
+        _, private, err := ed25519.GenerateKey(rand.Reader)
+        assert.Nil(t, err)
+        signer, err := ssh.NewSignerFromKey(private)
+        assert.Nil(t, err)
+        hostKeys = append(hostKeys, signer)
+    }
+
+    config := &ssh.ServerConfig{
+        PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+            if conn.User() == "testuser" && string(password) == "secret" {
+                return nil, nil
+            }
+            return nil, assert.AnError
+        },
+    }
+    for _, signer := range hostKeys {
+        config.AddHostKey(signer)
+    }
+
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    assert.Nil(t, err)
+
+    server := &testServer{listener: listener, hostKey: hostKeys[0].PublicKey()}
+    go func() {
+        for {
+            conn, err := listener.Accept()
+            if err != nil {
+                return
+            }
+            atomic.AddInt32(&server.accepted, 1)
+            go server.serve(conn, config)
+        }
+    }()
+    return server
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+    sshConn, channels, requests, err := ssh.NewServerConn(conn, config)
+    if err != nil {
+        conn.Close()
+        return
+    }
+    atomic.AddInt32(&s.connections, 1)
+    s.mu.Lock()
+    s.conns = append(s.conns, sshConn)
+    s.mu.Unlock()
+
+    go func() {
+        for request := range requests {
+            if request.Type == "keepalive@openssh.com" {
+                atomic.AddInt32(&s.keepAlives, 1)
+                if atomic.LoadInt32(&s.ignoreKeepAlives) > 0 {
+                    continue
+                }
+            }
+            if request.WantReply {
+                request.Reply(request.Type == "keepalive@openssh.com", nil)
+            }
+        }
+    }()
+
+    for newChannel := range channels {
+        if newChannel.ChannelType() != "session" {
+            newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+            continue
+        }
+        if max := atomic.LoadInt32(&s.maxSessions); max > 0 && atomic.LoadInt32(&s.sessions) >= max {
+            newChannel.Reject(ssh.Prohibited, "too many sessions")
+            continue
+        }
+        channel, requests, err := newChannel.Accept()
+        if err != nil {
+            continue
+        }
+        atomic.AddInt32(&s.sessions, 1)
+        go s.serveSession(channel, requests)
+    }
+}
+
+func (s *testServer) serveSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+    defer atomic.AddInt32(&s.sessions, -1)
+    for request := range requests {
+        if request.Type != "exec" {
+            // signals are ignored like sshd does without pty
+            if request.WantReply {
+                request.Reply(false, nil)
+            }
+            continue
+        }
+
+        length := binary.BigEndian.Uint32(request.Payload)
+        command := exec.Command("/bin/sh", "-c", string(request.Payload[4:4+length]))
+        command.Stdout = channel
+        command.Stderr = channel.Stderr()
+        stdin, _ := command.StdinPipe()
+        if err := command.Start(); err != nil {
+            request.Reply(false, nil)
+            channel.Close()
+            return
+        }
+        request.Reply(true, nil)
+
+        go func() {
+            io.Copy(stdin, channel)
+            stdin.Close()
+        }()
+        go func() {
+            status := 0
+            if err := command.Wait(); err != nil {
+                status = 255
+                if exitErr, ok := err.(*exec.ExitError); ok {
+                    status = exitErr.Sys().(syscall.WaitStatus).ExitStatus()
+                }
+            }
+            channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+            channel.Close()
+        }()
+    }
+}
+
+// dropConnections closes all connections on the server side
+func (s *testServer) dropConnections() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, conn := range s.conns {
+        conn.Close()
+    }
+    s.conns = nil
+}
+
+func (s *testServer) Close() {
+    s.listener.Close()
+    s.dropConnections()
+}
+
+func (s *testServer) address() string {
+    return s.listener.Addr().String()
+}
+
+func (s *testServer) executor(out io.Writer) *Executor {
+    return &Executor{
+        SshOpts: types.SSHConfig{
+            Connection: types.SSHConnection{
+                Username:        "testuser",
+                Password:        "secret",
+                Port:            s.listener.Addr().(*net.TCPAddr).Port,
+                HostKeyChecking: HostKeyCheckingInsecure,
+            },
+        },
+        Node:    types.Node{Host: "testhost", IP: "127.0.0.1"},
+        Printer: &printTest.MockLogWriter{Out: out},
+    }
+}
+
+func TestPerformCmd(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    sshOut, err := server.executor(ioutil.Discard).PerformCmd("echo hello; echo failed >&2; exit 3", false)
+    assert.NotNil(t, err)
+    assert.Equal(t, "hello", sshOut.Stdout)
+    assert.Equal(t, "failed", sshOut.Stderr)
+    assert.Equal(t, 3, sshOut.ExitStatus)
+}
+
+func TestPerformCmdStreaming_ExitStatus(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+    sshOut, err := server.executor(ioutil.Discard).PerformCmdStreaming(context.Background(),
+        "echo 'quoted output'; echo failed >&2; exit 3", false, stdout, stderr)
+    assert.EqualError(t, err, "command exited with status 3")
+    assert.Equal(t, 3, sshOut.ExitStatus)
+    assert.Equal(t, "quoted output\n", stdout.String())
+    assert.Equal(t, "failed\n", stderr.String())
+}
+
+func TestPerformCmdStreaming_AbortTerminatesRemoteCommand(t *testing.T) {
+    server := newTestServer(t)
+    defer server.Close()
+    defer CloseConnections()
+
+    dir, err := ioutil.TempDir("", "ssh-test")
+    assert.Nil(t, err)
+    defer os.RemoveAll(dir)
+    pidFile := filepath.Join(dir, "pid")
+
+    // a quiet follow command which never notices the closed session by itself
+    command := "sh -c 'echo $$ > " + pidFile + "; exec sleep 1000'"
+    ctx, cancel := context.WithCancel(context.Background())
+    go func() {
+        for {
+            if content, _ := ioutil.ReadFile(pidFile); strings.HasSuffix(string(content), "\n") {
+                cancel()
+                return
+            }
+            time.Sleep(10 * time.Millisecond)
+        }
+    }()
+
+    _, err = server.executor(ioutil.Discard).PerformCmdStreaming(ctx, command, false, ioutil.Discard, ioutil.Discard)
+    assert.Equal(t, context.Canceled, err)
+
+    content, err := ioutil.ReadFile(pidFile)
+    assert.Nil(t, err)
+    pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+    assert.Nil(t, err)
+
+    terminated := false
+    for i := 0; i < 100 && !terminated; i++ {
+        terminated = syscall.Kill(pid, 0) == syscall.ESRCH
+        time.Sleep(50 * time.Millisecond)
+    }
+    if !terminated {
+        syscall.Kill(pid, syscall.SIGKILL)
+    }
+    assert.True(t, terminated, "remote command is still running")
+}
@@ -1,6 +1,11 @@
 package test
 
 import (
+    "context"
+    "fmt"
+    "io"
+
+    "github.com/mrahbar/kubernetes-inspector/integration"
     "github.com/mrahbar/kubernetes-inspector/types"
 )
 
@@ -9,7 +14,10 @@ type MockExecutor struct {
     Node types.Node
     MockSetNode    func(node types.Node)
     MockGetNode    func() types.Node
+    MockForNode    func(node types.Node) types.CommandExecutor
+    MockWithPrinter func(printer integration.LogWriter) types.CommandExecutor
     MockPerformCmd func(command string, sudo bool) (*types.SSHOutput, error)
+    MockPerformCmdStreaming func(ctx context.Context, command string, sudo bool, stdout io.Writer, stderr io.Writer) (*types.SSHOutput, error)
 
     MockDownloadFile      func(remotePath string, localPath string) error
     MockDownloadDirectory func(remotePath string, localPath string) error
@@ -45,6 +53,23 @@ func (e *MockExecutor) SetNode(node types.Node) {
     }
 }
 
+func (e *MockExecutor) ForNode(node types.Node) types.CommandExecutor {
+    if e.MockForNode != nil {
+        return e.MockForNode(node)
+    }
+
+    clone := *e
+    clone.Node = node
+    return &clone
+}
+
+func (e *MockExecutor) WithPrinter(printer integration.LogWriter) types.CommandExecutor {
+    if e.MockWithPrinter != nil {
+        return e.MockWithPrinter(printer)
+    }
+    return e
+}
+
 func (e *MockExecutor) PerformCmd(command string, sudo bool) (*types.SSHOutput, error) {
     if e.MockPerformCmd != nil {
         return e.MockPerformCmd(command, sudo)
@@ -53,6 +78,24 @@ func (e *MockExecutor) PerformCmd(command string, sudo bool) (*types.SSHOutput,
     return &types.SSHOutput{}, nil
 }
 
+// PerformCmdStreaming falls back to PerformCmd and writes its collected output to the writers
+func (e *MockExecutor) PerformCmdStreaming(ctx context.Context, command string, sudo bool, stdout io.Writer, stderr io.Writer) (*types.SSHOutput, error) {
+    if e.MockPerformCmdStreaming != nil {
+        return e.MockPerformCmdStreaming(ctx, command, sudo, stdout, stderr)
+    }
+
+    sshOut, err := e.PerformCmd(command, sudo)
+    if sshOut != nil {
+        if sshOut.Stdout != "" {
+            fmt.Fprintln(stdout, sshOut.Stdout)
+        }
+        if sshOut.Stderr != "" {
+            fmt.Fprintln(stderr, sshOut.Stderr)
+        }
+    }
+    return sshOut, err
+}
+
 func (e *MockExecutor) DownloadFile(remotePath string, localPath string) error {
     if e.MockDownloadFile != nil {
         return e.MockDownloadFile(remotePath, localPath)
@@ -97,17 +97,27 @@ func prepareSSHConfig(sshConfig *types.SSHConfig) []error {
 			c.FileTransferMethod))
 	}
 
+	if err := validateHostKeyChecking(c.HostKeyChecking); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateHostKeyChecking(sshConfig.Bastion.Connection.HostKeyChecking); err != nil {
+		errs = append(errs, err)
+	}
+
 	return errs
 }
 
-func establishSSHCommunication(sshOpts types.SSHConfig, address string, printer integration.LogWriter) (*communicator.Comm, error) {
-    commConfig, err := createCommunicationConfig(sshOpts, address, printer)
+func establishSSHCommunication(sshOpts types.SSHConfig, node types.Node, printer integration.LogWriter) (*communicator.Comm, error) {
+    commConfig, err := createCommunicationConfig(sshOpts, node, printer)
 	if err != nil {
 		return &communicator.Comm{}, err
 	}
 
 	var comm *communicator.Comm
 	handshakeAttempts := 0
+	// The port is needed by the host key callback to look up the node in known_hosts
+	address := fmt.Sprintf("%s:%d", util.GetNodeAddress(node), sshOpts.Connection.Port)
 
 	for {
         comm, err = communicator.New(address, commConfig,
@@ -118,6 +128,12 @@ func establishSSHCommunication(sshOpts types.SSHConfig, address string, printer
 		if err != nil {
             printer.PrintDebug("SSH handshake err: %s", err)
 
+			// A rejected host key will not change by retrying
+			var keyErr *hostKeyError
+			if errors.As(err, &keyErr) {
+				return &communicator.Comm{}, err
+			}
+
 			// Only count this as an attempt if we were able to attempt
 			// to authenticate. Note this is very brittle since it depends
 			// on the string of the error... but I don't see any other way.
@@ -142,19 +158,27 @@ func establishSSHCommunication(sshOpts types.SSHConfig, address string, printer
 	return comm, nil
 }
 
-func createCommunicationConfig(sshOpts types.SSHConfig, nodeAddress string, printer integration.LogWriter) (*communicator.Config, error) {
+func createCommunicationConfig(sshOpts types.SSHConfig, node types.Node, printer integration.LogWriter) (*communicator.Config, error) {
     errs := prepareSSHConfig(&sshOpts)
 	if len(errs) > 0 {
 		return &communicator.Config{}, flattenMultiError(errs)
 	}
 
 	var connFunc func() (net.Conn, error)
-	address := fmt.Sprintf("%s:%d", nodeAddress, sshOpts.Connection.Port)
+	address := fmt.Sprintf("%s:%d", util.GetNodeAddress(node), sshOpts.Connection.Port)
 
 	if util.IsNodeAddressValid(sshOpts.Bastion.Node) {
 		// We're using a bastion host, so use the bastion connfunc
 		bAddr := fmt.Sprintf("%s:%d", util.GetNodeAddress(sshOpts.Bastion.Node), sshOpts.Bastion.Connection.Port)
-		bConf, err := sshBastionConfig(&sshOpts.Bastion)
+		bastion := sshOpts.Bastion
+		if bastion.Connection.KnownHostsFile == "" {
+			bastion.Connection.KnownHostsFile = sshOpts.Connection.KnownHostsFile
+		}
+		if bastion.Connection.HostKeyChecking == "" {
+			bastion.Connection.HostKeyChecking = sshOpts.Connection.HostKeyChecking
+		}
+
+		bConf, err := sshBastionConfig(&bastion)
 		if err != nil {
             printer.PrintDebug("BastionConfig failed: %s", err)
 			return &communicator.Config{}, err
@@ -173,7 +197,7 @@ func createCommunicationConfig(sshOpts types.SSHConfig, nodeAddress string, prin
 	}
 	nc.Close()
 
-	sshConfig, err := sshConfigFunc(&sshOpts.Connection)
+	sshConfig, err := sshConfigFunc(&sshOpts.Connection, node)
 	if err != nil {
         printer.PrintDebug("SSHConfig failed: %s", err)
 		return &communicator.Config{}, err
@@ -192,7 +216,7 @@ func createCommunicationConfig(sshOpts types.SSHConfig, nodeAddress string, prin
 	return commConfig, nil
 }
 
-func sshConfigFunc(config *types.SSHConnection) (*ssh.ClientConfig, error) {
+func sshConfigFunc(config *types.SSHConnection, node types.Node) (*ssh.ClientConfig, error) {
 	auth := []ssh.AuthMethod{
 		ssh.Password(config.Password),
 		ssh.KeyboardInteractive(
@@ -217,10 +241,17 @@ func sshConfigFunc(config *types.SSHConnection) (*ssh.ClientConfig, error) {
 		auth = append(auth, agentAuthMethod)
 	}
 
+	address := fmt.Sprintf("%s:%d", util.GetNodeAddress(node), config.Port)
+	hostKeyCallback, hostKeyAlgorithms, err := hostKeyCallback(*config, node, address)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ssh.ClientConfig{
-		User:            config.Username,
-		Auth:            auth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:              config.Username,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
 	}, nil
 }
 
@@ -251,10 +282,17 @@ func sshBastionConfig(config *types.BastionSSHConnection) (*ssh.ClientConfig, er
 		auth = append(auth, agentAuthMethod)
 	}
 
+	address := fmt.Sprintf("%s:%d", util.GetNodeAddress(config.Node), config.Connection.Port)
+	hostKeyCallback, hostKeyAlgorithms, err := hostKeyCallback(config.Connection, config.Node, address)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ssh.ClientConfig{
-		User:            config.Connection.Username,
-		Auth:            auth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:              config.Connection.Username,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
 	}, nil
 }
 
@@ -2,6 +2,7 @@ package types
 
 import (
     "github.com/mrahbar/kubernetes-inspector/integration"
+    "time"
 )
 
 type CommandContext struct {
@@ -16,6 +17,22 @@ type ClusterStatusOpts struct {
     Checks string
     Sudo       bool
     SkipStats       bool
+    Output     string
+    Watch      time.Duration
+}
+
+type ServeOpts struct {
+    ClusterStatusOpts
+    Listen   string
+    Interval time.Duration
+}
+
+type BundleOpts struct {
+    Groups        string
+    Output        string
+    Sudo          bool
+    Tail          int
+    KubeletConfig string
 }
 
 type GenericOpts struct {
@@ -23,11 +40,26 @@ type GenericOpts struct {
     NodeArg   string
     TargetArg string
     Sudo       bool
+    Parallel   int
 }
 
 type ExecOpts struct {
     GenericOpts
     FileOutput string
+    Buffered   bool
+}
+
+type RestartOpts struct {
+    GenericOpts
+    Rolling   bool
+    BatchSize int
+    Pause     time.Duration
+    // Maximum time to wait for a node to become healthy after its restart
+    Timeout   time.Duration
+    HealthCmd string
+    WaitReady bool
+    // Time a node has to stay healthy after passing the checks before the next batch is restarted
+    Settle    time.Duration
 }
 
 type ScpOpts struct {
@@ -43,6 +75,14 @@ type LogsOpts struct {
     Since      string
     Tail       int
     ExtraArgs  []string
+    Buffered   bool
+    Follow     bool
+    // Only used for pods
+    Namespace     string
+    Container     string
+    Selector      string
+    Previous      bool
+    AllContainers bool
 }
 
 type KubectlOpts struct {
@@ -10,9 +10,36 @@ const CERTIFICATES_CHECKNAME = "Certificates"
 const DISKUSAGE_CHECKNAME = "DiskUsage"
 const KUBERNETES_CHECKNAME = "Kubernetes"
 
+const CONTAINER_RUNTIME_DOCKER = "docker"
+const CONTAINER_RUNTIME_CRICTL = "crictl"
+
 type Config struct {
 	Ssh           SSHConfig
 	ClusterGroups []ClusterGroup
+	Backup        BackupConfig
+}
+
+// BackupConfig enables encryption of etcd backup archives, either to age Recipients (age1...) or with a Passphrase.
+// Identities are files with the secret keys (AGE-SECRET-KEY-1...) used to decrypt archives on restore and verify.
+type BackupConfig struct {
+	Recipients []string
+	Identities []string
+	Passphrase string
+	S3         S3Config
+}
+
+// S3Config is an S3 compatible object storage to which etcd backups are uploaded after they were downloaded.
+// Endpoint is scheme and host only, e.g. http://127.0.0.1:9000, objects are stored as <bucket>/<prefix>/<archive>.
+// AccessKey and SecretKey default to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY of the environment.
+type S3Config struct {
+	Endpoint  string
+	Bucket    string
+	Prefix    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Size of the parts of multipart uploads, e.g. 16M. At least 5M as required by S3
+	PartSize string
 }
 
 type ClusterGroup struct {
@@ -20,14 +47,81 @@ type ClusterGroup struct {
 	Nodes        []Node
 	Services     []string
 	Containers   []string
-	Certificates []string
+	Certificates []Certificate
 	DiskUsage    DiskUsage
 	Kubernetes   Kubernetes
+	// Defaults for certificates which do not define their own, e.g. 30d or 720h
+	CertificateWarning  string
+	CertificateCritical string
+	CertificateCA       string
+	CustomChecks        []CustomCheck
+	// Runtime of the Containers, docker or crictl (also containerd or cri-o). Detected on every node when empty
+	ContainerRuntime string
+	// Connection settings of etcd, only used for the Etcd group
+	Etcd EtcdSettings
+}
+
+// EtcdSettings describe how etcd is reached on every node of the group. The file paths may contain templates like
+// /etc/etcd/certs/{{.Host}}.pem which are resolved per node. Flags of the etcd commands take precedence.
+type EtcdSettings struct {
+	ClientPort int
+	// http or https, defaults to https when a client certificate is configured
+	Scheme         string
+	CaFile         string
+	ClientCertFile string
+	ClientKeyFile  string
 }
 
+// CustomCheck runs Command on every node of the group. All configured expectations must be met, otherwise
+// the check fails with Severity (WARN or ERROR). Compare is applied to stdout as number, e.g. "> 100" or "== 1".
+// The exit code is only checked when ExitCode is set, or expected to be 0 when neither Stdout nor Compare is set.
+type CustomCheck struct {
+	Name     string
+	Command  string
+	Sudo     bool
+	ExitCode *int
+	Stdout   string
+	Compare  string
+	Severity string
+}
+
+// Certificate can be configured either as plain path or with a key file, a CA file and expiry thresholds
+type Certificate struct {
+	Path     string
+	Key      string
+	CA       string
+	Warning  string
+	Critical string
+}
+
+// DiskUsage thresholds apply to all entries which do not define their own.
+// Percentages are used for file systems and inodes, sizes like 10G for directories.
 type DiskUsage struct {
-	FileSystemUsage []string
-	DirectoryUsage  []string
+	FileSystemUsage   []FileSystemUsage
+	DirectoryUsage    []DirectoryUsage
+	Warning           int
+	Critical          int
+	InodeUsage        bool
+	InodeWarning      int
+	InodeCritical     int
+	DirectoryWarning  string
+	DirectoryCritical string
+}
+
+// FileSystemUsage can be configured either as plain path or with its own thresholds
+type FileSystemUsage struct {
+	Path          string
+	Warning       int
+	Critical      int
+	InodeWarning  int
+	InodeCritical int
+}
+
+// DirectoryUsage can be configured either as plain path or with its own thresholds
+type DirectoryUsage struct {
+	Path     string
+	Warning  string
+	Critical string
 }
 
 type Node struct {
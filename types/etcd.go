@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type EtcdOpts struct {
 	ClientCertAuth bool
 	Endpoint       string
@@ -12,5 +14,106 @@ type EtcdBackupOpts struct {
 	Output  string
 	DataDir string
 	Sudo       bool
+	Api     string
+	Keep       int
+	KeepWithin string
+	Recipients []string
+	EtcdOpts
+}
+
+type EtcdBackupsOpts struct {
+	Dir        string
+	Archives   []string
+	Identities []string
+}
+
+type EtcdRestoreOpts struct {
+	Archive           string
+	DataDir           string
+	Service           string
+	StaticPodManifest string
+	PeerScheme        string
+	PeerPort          int
+	ClusterToken      string
+	Sudo              bool
+	Yes               bool
+	Identities        []string
+	// Maximum time etcd may take to stop and the restored members to become healthy
+	Timeout time.Duration
+	// Connection of the health check of the restored members
 	EtcdOpts
+	ClientPort int
+}
+
+type EtcdClusterOpts struct {
+	EtcdOpts
+	ClientPort int
+	Sudo       bool
+	MaxRaftLag uint64
+	Retention  int64
+	Physical   bool
+	// Timeout of a single etcdctl defrag
+	CommandTimeout time.Duration
+}
+
+const ETCD_DEFAULT_CLIENT_PORT = 2379
+
+const ETCD_API_V2 = "v2"
+const ETCD_API_V3 = "v3"
+
+// EtcdSnapshotStatus is the result of etcdctl snapshot status
+type EtcdSnapshotStatus struct {
+	Hash      uint32 `json:"hash"`
+	Revision  int64  `json:"revision"`
+	TotalKey  int    `json:"totalKey"`
+	TotalSize int64  `json:"totalSize"`
+}
+
+// EtcdBackupMetadata is stored next to every downloaded backup archive
+type EtcdBackupMetadata struct {
+	Archive  string              `json:"archive"`
+	Time     time.Time           `json:"time"`
+	Node     string              `json:"node"`
+	Endpoint string              `json:"endpoint"`
+	Api      string              `json:"api"`
+	Version  string              `json:"version,omitempty"`
+	Revision int64               `json:"revision,omitempty"`
+	Size     int64               `json:"size"`
+	Sha256   string              `json:"sha256"`
+	Snapshot *EtcdSnapshotStatus `json:"snapshot,omitempty"`
+	// Encrypted archives are in the age format, Sha256 is the checksum of the encrypted file
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+// EtcdEndpointStatus is one entry of etcdctl endpoint status --write-out=json
+type EtcdEndpointStatus struct {
+	Endpoint string `json:"Endpoint"`
+	Status   struct {
+		Header struct {
+			MemberId uint64 `json:"member_id"`
+			Revision int64  `json:"revision"`
+		} `json:"header"`
+		Version   string `json:"version"`
+		DbSize    int64  `json:"dbSize"`
+		Leader    uint64 `json:"leader"`
+		RaftIndex uint64 `json:"raftIndex"`
+		RaftTerm  uint64 `json:"raftTerm"`
+	} `json:"Status"`
+}
+
+// EtcdMemberList is the result of etcdctl member list --write-out=json
+type EtcdMemberList struct {
+	Members []EtcdMember `json:"members"`
+}
+
+type EtcdMember struct {
+	ID         uint64   `json:"ID"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs"`
+}
+
+type EtcdAlarm struct {
+	MemberId uint64
+	Alarm    string
 }
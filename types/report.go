@@ -0,0 +1,72 @@
+package types
+
+import "time"
+
+const STATUS_OK = "OK"
+const STATUS_WARN = "WARN"
+const STATUS_ERROR = "ERROR"
+const STATUS_UNKNOWN = "UNKNOWN"
+const STATUS_IGNORED = "IGNORED"
+const STATUS_SKIPPED = "SKIPPED"
+
+const OUTPUT_JSON = "json"
+const OUTPUT_YAML = "yaml"
+
+// ClusterStatusReport is the machine-readable result of a cluster-status run
+type ClusterStatusReport struct {
+	Time      time.Time      `json:"time" yaml:"time"`
+	NodeStats []*NodeStats   `json:"nodeStats,omitempty" yaml:"nodeStats,omitempty"`
+	Groups    []*GroupReport `json:"groups" yaml:"groups"`
+	Summary   StatusSummary  `json:"summary" yaml:"summary"`
+}
+
+// StatusSummary counts the results of all checks. Status is the overall verdict.
+type StatusSummary struct {
+	Status  string `json:"status" yaml:"status"`
+	Ok      int    `json:"ok" yaml:"ok"`
+	Warn    int    `json:"warn" yaml:"warn"`
+	Error   int    `json:"error" yaml:"error"`
+	Unknown int    `json:"unknown" yaml:"unknown"`
+	Ignored int    `json:"ignored" yaml:"ignored"`
+	Skipped int    `json:"skipped" yaml:"skipped"`
+}
+
+type NodeStats struct {
+	Node          string  `json:"node" yaml:"node"`
+	IP            string  `json:"ip,omitempty" yaml:"ip,omitempty"`
+	UptimeSeconds float64 `json:"uptimeSeconds" yaml:"uptimeSeconds"`
+	Load1         float64 `json:"load1" yaml:"load1"`
+	Load5         float64 `json:"load5" yaml:"load5"`
+	Load15        float64 `json:"load15" yaml:"load15"`
+	Processes     int     `json:"processes" yaml:"processes"`
+	Error         string  `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type GroupReport struct {
+	Name   string         `json:"name" yaml:"name"`
+	Error  string         `json:"error,omitempty" yaml:"error,omitempty"`
+	Checks []*CheckReport `json:"checks,omitempty" yaml:"checks,omitempty"`
+}
+
+// CheckReport holds the results of one check within a group. Status and Message are only set when the whole check was skipped.
+type CheckReport struct {
+	Name    string             `json:"name" yaml:"name"`
+	Status  string             `json:"status,omitempty" yaml:"status,omitempty"`
+	Message string             `json:"message,omitempty" yaml:"message,omitempty"`
+	Nodes   []*NodeCheckReport `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+}
+
+type NodeCheckReport struct {
+	Node    string        `json:"node" yaml:"node"`
+	IP      string        `json:"ip,omitempty" yaml:"ip,omitempty"`
+	Results []CheckResult `json:"results" yaml:"results"`
+}
+
+// CheckResult is the outcome for a single target of a check, e.g. a service or a certificate.
+// Values contains raw data like usage percent or certificate expiry time.
+type CheckResult struct {
+	Target  string                 `json:"target" yaml:"target"`
+	Status  string                 `json:"status" yaml:"status"`
+	Message string                 `json:"message" yaml:"message"`
+	Values  map[string]interface{} `json:"values,omitempty" yaml:"values,omitempty"`
+}
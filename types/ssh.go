@@ -1,6 +1,12 @@
 package types
 
-import "time"
+import (
+    "context"
+    "io"
+    "time"
+
+    "github.com/mrahbar/kubernetes-inspector/integration"
+)
 
 //LocalOn and Bastion are mutual exclusive
 type SSHConfig struct {
@@ -18,6 +24,10 @@ type SSHConnection struct {
     Timeout            time.Duration
     HandshakeAttempts  int
     FileTransferMethod string
+    // KnownHostsFile defaults to ~/.ssh/known_hosts
+    KnownHostsFile     string
+    // HostKeyChecking is either strict (default), accept-new or insecure
+    HostKeyChecking    string
 }
 
 type BastionSSHConnection struct {
@@ -34,7 +44,15 @@ type SSHOutput struct {
 type CommandExecutor interface {
     SetNode(node Node)
     GetNode() Node
+    // ForNode returns an independent executor bound to node, safe to be used concurrently with others
+    ForNode(node Node) CommandExecutor
+    // WithPrinter returns an independent executor which prints to printer, e.g. the output buffer of a node
+    WithPrinter(printer integration.LogWriter) CommandExecutor
     PerformCmd(command string, sudo bool) (*SSHOutput, error)
+    // PerformCmdStreaming writes the output to stdout and stderr as it arrives instead of collecting it.
+    // The returned SSHOutput only carries the exit status, a non-zero exit status is returned as error.
+    // The command is terminated when ctx is done, in that case the error of ctx is returned.
+    PerformCmdStreaming(ctx context.Context, command string, sudo bool, stdout io.Writer, stderr io.Writer) (*SSHOutput, error)
 
     DownloadFile(remotePath string, localPath string) error
     DownloadDirectory(remotePath string, localPath string) error
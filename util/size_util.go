@@ -0,0 +1,39 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = map[string]float64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+	"P": 1 << 50,
+}
+
+// ParseSize converts human readable sizes as printed by du -h, e.g. 512K or 1.5G, into bytes
+func ParseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.Replace(s, ",", ".", 1)
+	s = strings.TrimSuffix(s, "B")
+	s = strings.TrimSuffix(s, "I")
+
+	unit := ""
+	if s != "" {
+		if _, ok := sizeUnits[s[len(s)-1:]]; ok {
+			unit = s[len(s)-1:]
+			s = s[:len(s)-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("Invalid size '%s'", size)
+	}
+
+	return int64(value * sizeUnits[unit]), nil
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+    "testing"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+    tests := []struct {
+        size     string
+        expected int64
+    }{
+        {"0", 0},
+        {"512", 512},
+        {"512B", 512},
+        {"4K", 4 << 10},
+        {"4k", 4 << 10},
+        {"4KB", 4 << 10},
+        {"4KiB", 4 << 10},
+        {"1.5M", 3 << 19},
+        {"1,5M", 3 << 19},
+        {"10G", 10 << 30},
+        {"10g", 10 << 30},
+        {" 10G ", 10 << 30},
+        {"2T", 2 << 40},
+        {"1P", 1 << 50},
+    }
+
+    for _, test := range tests {
+        size, err := ParseSize(test.size)
+        assert.Nil(t, err, test.size)
+        assert.Equal(t, test.expected, size, test.size)
+    }
+}
+
+func TestParseSize_Invalid(t *testing.T) {
+    for _, size := range []string{"", "G", "ten", "10X", "-1G", "1.5.5M", "10 G B"} {
+        _, err := ParseSize(size)
+        assert.EqualError(t, err, "Invalid size '"+size+"'", size)
+    }
+}
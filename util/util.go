@@ -2,18 +2,20 @@ package util
 
 import (
 	"fmt"
+    "github.com/mitchellh/mapstructure"
     "github.com/mrahbar/kubernetes-inspector/integration"
 	"github.com/mrahbar/kubernetes-inspector/types"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"os"
+	"reflect"
 	"strings"
 )
 
 func UnmarshalConfig() types.Config {
 	var config types.Config
-	err := viper.Unmarshal(&config)
+	err := decodeConfig(viper.AllSettings(), &config)
 
 	if err != nil {
         integration.PrettyPrintErr("Unable to decode config: %v", err)
@@ -23,6 +25,41 @@ func UnmarshalConfig() types.Config {
 	return config
 }
 
+// decodeConfig mirrors viper.Unmarshal but additionally allows plain paths for disk usage and certificate entries
+func decodeConfig(input interface{}, config *types.Config) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+			stringToPathHookFunc,
+		),
+		WeaklyTypedInput: true,
+		Result:           config,
+	})
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(input)
+}
+
+func stringToPathHookFunc(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String {
+		return data, nil
+	}
+
+	switch to {
+	case reflect.TypeOf(types.FileSystemUsage{}):
+		return types.FileSystemUsage{Path: data.(string)}, nil
+	case reflect.TypeOf(types.DirectoryUsage{}):
+		return types.DirectoryUsage{Path: data.(string)}, nil
+	case reflect.TypeOf(types.Certificate{}):
+		return types.Certificate{Path: data.(string)}, nil
+	default:
+		return data, nil
+	}
+}
+
 func CheckRequiredFlags(cmd *cobra.Command, _ []string) error {
 	f := cmd.Flags()
 	requiredError := false
@@ -0,0 +1,121 @@
+package util
+
+import (
+    "testing"
+    "github.com/mrahbar/kubernetes-inspector/types"
+    "github.com/stretchr/testify/assert"
+    "time"
+)
+
+func TestDecodeConfig_Paths(t *testing.T) {
+    tests := []struct {
+        name        string
+        input       interface{}
+        fileSystem  types.FileSystemUsage
+        directory   types.DirectoryUsage
+        certificate types.Certificate
+    }{
+        {
+            name:        "plain string",
+            input:       "/var/lib/etcd",
+            fileSystem:  types.FileSystemUsage{Path: "/var/lib/etcd"},
+            directory:   types.DirectoryUsage{Path: "/var/lib/etcd"},
+            certificate: types.Certificate{Path: "/var/lib/etcd"},
+        },
+        {
+            name:        "home directory is kept as is",
+            input:       "~/.kube/config",
+            fileSystem:  types.FileSystemUsage{Path: "~/.kube/config"},
+            directory:   types.DirectoryUsage{Path: "~/.kube/config"},
+            certificate: types.Certificate{Path: "~/.kube/config"},
+        },
+        {
+            name:        "relative path is kept as is",
+            input:       "certs/../apiserver.crt",
+            fileSystem:  types.FileSystemUsage{Path: "certs/../apiserver.crt"},
+            directory:   types.DirectoryUsage{Path: "certs/../apiserver.crt"},
+            certificate: types.Certificate{Path: "certs/../apiserver.crt"},
+        },
+        {
+            name:        "empty string",
+            input:       "",
+            fileSystem:  types.FileSystemUsage{},
+            directory:   types.DirectoryUsage{},
+            certificate: types.Certificate{},
+        },
+        {
+            name: "map with thresholds",
+            input: map[string]interface{}{
+                "path": "/dev/sda1", "warning": "80", "critical": 95, "key": "/etc/ssl/key.pem",
+            },
+            fileSystem:  types.FileSystemUsage{Path: "/dev/sda1", Warning: 80, Critical: 95},
+            directory:   types.DirectoryUsage{Path: "/dev/sda1", Warning: "80", Critical: "95"},
+            certificate: types.Certificate{Path: "/dev/sda1", Key: "/etc/ssl/key.pem", Warning: "80", Critical: "95"},
+        },
+    }
+
+    for _, test := range tests {
+        input := map[string]interface{}{
+            "clustergroups": []interface{}{
+                map[string]interface{}{
+                    "name":         "Master",
+                    "certificates": []interface{}{test.input},
+                    "diskusage": map[string]interface{}{
+                        "filesystemusage": []interface{}{test.input},
+                        "directoryusage":  []interface{}{test.input},
+                    },
+                },
+            },
+        }
+
+        config := types.Config{}
+        assert.Nil(t, decodeConfig(input, &config), test.name)
+
+        group := config.ClusterGroups[0]
+        assert.Equal(t, []types.FileSystemUsage{test.fileSystem}, group.DiskUsage.FileSystemUsage, test.name)
+        assert.Equal(t, []types.DirectoryUsage{test.directory}, group.DiskUsage.DirectoryUsage, test.name)
+        assert.Equal(t, []types.Certificate{test.certificate}, group.Certificates, test.name)
+    }
+}
+
+func TestDecodeConfig_Defaults(t *testing.T) {
+    input := map[string]interface{}{
+        "ssh": map[string]interface{}{
+            "connection": map[string]interface{}{"timeout": "30s"},
+        },
+        "clustergroups": []interface{}{
+            map[string]interface{}{
+                "name":       "Master",
+                "services":   "kubelet,docker",
+                "diskusage":  map[string]interface{}{"filesystemusage": []interface{}{"/dev/sda1"}},
+            },
+        },
+    }
+
+    config := types.Config{}
+    assert.Nil(t, decodeConfig(input, &config))
+
+    assert.Equal(t, 30*time.Second, config.Ssh.Connection.Timeout)
+    group := config.ClusterGroups[0]
+    assert.Equal(t, []string{"kubelet", "docker"}, group.Services)
+    // thresholds which are not configured stay unset, the checks fall back to their defaults
+    assert.Equal(t, 0, group.DiskUsage.Warning)
+    assert.Equal(t, 0, group.DiskUsage.Critical)
+    assert.Equal(t, 0, group.DiskUsage.FileSystemUsage[0].Warning)
+    assert.Equal(t, 0, group.DiskUsage.FileSystemUsage[0].Critical)
+}
+
+func TestDecodeConfig_InvalidThreshold(t *testing.T) {
+    input := map[string]interface{}{
+        "clustergroups": []interface{}{
+            map[string]interface{}{
+                "diskusage": map[string]interface{}{
+                    "filesystemusage": []interface{}{map[string]interface{}{"path": "/", "warning": "high"}},
+                },
+            },
+        },
+    }
+
+    config := types.Config{}
+    assert.NotNil(t, decodeConfig(input, &config))
+}